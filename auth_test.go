@@ -0,0 +1,158 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// withAccessConfig enables access control for the duration of a test
+func withAccessConfig(t *testing.T, config AccessConfig) {
+	t.Helper()
+	previous := accessConfig
+	accessConfig = config
+	t.Cleanup(func() { accessConfig = previous })
+}
+
+// protectedHandler stands in for the router behind authMiddleware
+var protectedHandler = authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}))
+
+func TestAuthMiddlewareRejectsMissingOrWrongCredentials(t *testing.T) {
+	withAccessConfig(t, AccessConfig{Token: "s3cret-token", Username: "admin", Password: "hunter2"})
+
+	tests := []struct {
+		name          string
+		authorization string
+		cookie        *http.Cookie
+	}{
+		{"no credentials", "", nil},
+		{"wrong token", "Bearer wrong-token", nil},
+		{"token prefix", "Bearer s3cret", nil},
+		{"token without scheme", "s3cret-token", nil},
+		{"basic scheme", "Basic s3cret-token", nil},
+		{"unknown session", "", &http.Cookie{Name: authSessionCookie, Value: "not-a-session"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/requests", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			if tt.cookie != nil {
+				req.AddCookie(tt.cookie)
+			}
+			rec := httptest.NewRecorder()
+			protectedHandler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusUnauthorized {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+			}
+			if rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 response has no WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareAcceptsBearerToken(t *testing.T) {
+	withAccessConfig(t, AccessConfig{Token: "s3cret-token"})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/requests", nil)
+	req.Header.Set("Authorization", "bearer s3cret-token")
+	rec := httptest.NewRecorder()
+	protectedHandler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestAuthMiddlewareOpenRoutes(t *testing.T) {
+	withAccessConfig(t, AccessConfig{Token: "s3cret-token"})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/health", nil)
+	rec := httptest.NewRecorder()
+	protectedHandler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("health: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	// Browsers asking for the UI are sent to the login page rather than shown a 401
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	rec = httptest.NewRecorder()
+	protectedHandler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != loginPath {
+		t.Errorf("UI: status = %d, Location = %q; want a redirect to %s", rec.Code, rec.Header().Get("Location"), loginPath)
+	}
+}
+
+func TestAuthMiddlewareDisabled(t *testing.T) {
+	withAccessConfig(t, AccessConfig{})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/requests", nil)
+	rec := httptest.NewRecorder()
+	protectedHandler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+// postLogin submits the sign-in form
+func postLogin(form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, loginPath, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	newAPI(newFileStore()).login(rec, req)
+	return rec
+}
+
+func TestLoginRejectsWrongCredentials(t *testing.T) {
+	withAccessConfig(t, AccessConfig{Token: "s3cret-token", Username: "admin", Password: "hunter2"})
+
+	for name, form := range map[string]url.Values{
+		"empty":          {},
+		"wrong password": {"username": {"admin"}, "password": {"hunter3"}},
+		"wrong username": {"username": {"root"}, "password": {"hunter2"}},
+		"wrong token":    {"token": {"s3cret"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rec := postLogin(form)
+			if rec.Code != http.StatusUnauthorized {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+			}
+			if len(rec.Result().Cookies()) != 0 {
+				t.Error("a failed sign-in set a cookie")
+			}
+		})
+	}
+}
+
+func TestLoginSessionCookie(t *testing.T) {
+	withAccessConfig(t, AccessConfig{Username: "admin", Password: "hunter2"})
+
+	rec := postLogin(url.Values{"username": {"admin"}, "password": {"hunter2"}})
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusSeeOther)
+	}
+	var session *http.Cookie
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == authSessionCookie {
+			session = cookie
+		}
+	}
+	if session == nil {
+		t.Fatal("sign-in set no session cookie")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/requests", nil)
+	req.AddCookie(session)
+	rec = httptest.NewRecorder()
+	protectedHandler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("with session: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// capturedRequest is the last request a captureRequest server received, with its body
+type capturedRequest struct {
+	*http.Request
+	body string
+}
+
+// captureRequest starts a server that records the last request it receives
+func captureRequest(t *testing.T) (*httptest.Server, *capturedRequest) {
+	t.Helper()
+	got := &capturedRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		*got = capturedRequest{Request: r, body: string(body)}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+	return server, got
+}
+
+func TestContentTypePrecedence(t *testing.T) {
+	server, sent := captureRequest(t)
+
+	bodies := []struct {
+		name        string
+		req         ProxyRequest
+		autoDefault string // Sent when the request sets no Content-Type
+	}{
+		{"json", ProxyRequest{BodyType: "json", BodyJson: []BodyField{{Key: "a", Value: "1", Type: "int", Enabled: true}}}, "application/json"},
+		{"form", ProxyRequest{BodyType: "form", BodyForm: []BodyField{{Key: "a", Value: "1", Enabled: true}}}, "application/x-www-form-urlencoded"},
+		{"binary", ProxyRequest{BodyType: "binary", BodyBase64: "AAEC"}, "application/octet-stream"},
+		{"grpcweb", ProxyRequest{BodyType: "grpcweb", BodyBase64: "CgNmb28="}, "application/grpc-web+proto"},
+		{"text", ProxyRequest{BodyType: "text", Body: "hello"}, ""},
+	}
+	explicit := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{"auto", nil, ""},
+		{"explicit", map[string]string{"Content-Type": "application/vnd.custom+json"}, "application/vnd.custom+json"},
+		{"explicit lower case", map[string]string{"content-type": "text/plain; charset=utf-8"}, "text/plain; charset=utf-8"},
+	}
+
+	for _, body := range bodies {
+		for _, header := range explicit {
+			t.Run(body.name+"/"+header.name, func(t *testing.T) {
+				req := body.req
+				req.Method = http.MethodPost
+				req.URL = server.URL
+				req.Headers = map[string]string{}
+				for k, v := range header.headers {
+					req.Headers[k] = v
+				}
+
+				resp := makeHTTPRequest(context.Background(), req)
+				if resp.Error != "" {
+					t.Fatalf("request failed: %s", resp.Error)
+				}
+
+				want := header.want
+				if want == "" {
+					want = body.autoDefault
+				}
+				got := sent.Header.Values("Content-Type")
+				switch {
+				case want == "" && len(got) != 0:
+					t.Errorf("Content-Type = %q, want none", got)
+				case want != "" && (len(got) != 1 || got[0] != want):
+					t.Errorf("Content-Type = %q, want exactly %q", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestContentTypeSurvivesTemplates(t *testing.T) {
+	server, sent := captureRequest(t)
+
+	req, err := newAPI(newMemStore(t, nil)).processTemplates(ProxyRequest{
+		Method:    http.MethodPost,
+		URL:       server.URL,
+		Headers:   map[string]string{"Content-Type": "{{ct}}"},
+		BodyType:  "json",
+		BodyJson:  []BodyField{{Key: "a", Value: "{{value}}", Enabled: true}},
+		Variables: []Variable{{Key: "ct", Value: "application/merge-patch+json"}, {Key: "value", Value: "x"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp := makeHTTPRequest(context.Background(), req); resp.Error != "" {
+		t.Fatalf("request failed: %s", resp.Error)
+	}
+	if got := sent.Header.Values("Content-Type"); len(got) != 1 || got[0] != "application/merge-patch+json" {
+		t.Errorf("Content-Type = %q, want the templated header", got)
+	}
+}
+
+func TestBodyFieldOrder(t *testing.T) {
+	server, sent := captureRequest(t)
+
+	tests := []struct {
+		name string
+		req  ProxyRequest
+		want string
+	}{
+		{
+			"form",
+			ProxyRequest{BodyType: "form", BodyForm: []BodyField{
+				{Key: "zeta", Value: "1", Enabled: true},
+				{Key: "alpha", Value: "a b", Enabled: true},
+				{Key: "skipped", Value: "x"},
+				{Key: "mid", Value: "&=", Enabled: true},
+			}},
+			"zeta=1&alpha=a+b&mid=%26%3D",
+		},
+		{
+			"json",
+			ProxyRequest{BodyType: "json", BodyJson: []BodyField{
+				{Key: "zeta", Value: "1", Type: "int", Parent: "root", Enabled: true},
+				{Key: "items", Type: "array", Parent: "root", Enabled: true},
+				{Key: "third", Value: "c", Type: "string", Parent: "items", Enabled: true},
+				{Key: "first", Value: "a", Type: "string", Parent: "items", Enabled: true},
+				{Key: "second", Value: "b", Type: "string", Parent: "items", Enabled: true},
+				{Key: "meta", Type: "object", Parent: "root", Enabled: true},
+				{Key: "y", Value: "true", Type: "boolean", Parent: "meta", Enabled: true},
+				{Key: "x", Value: "1.5", Type: "float", Parent: "meta", Enabled: true},
+				{Key: "alpha", Value: "a", Type: "string", Parent: "root", Enabled: true},
+			}},
+			`{"zeta":1,"items":["c","a","b"],"meta":{"y":true,"x":1.5},"alpha":"a"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Sent several times, since map iteration order would only show up some of the time
+			for range 20 {
+				req := tt.req
+				req.Method = http.MethodPost
+				req.URL = server.URL
+				req.Headers = map[string]string{}
+				if resp := makeHTTPRequest(context.Background(), req); resp.Error != "" {
+					t.Fatalf("request failed: %s", resp.Error)
+				}
+				if sent.body != tt.want {
+					t.Fatalf("body = %s, want %s", sent.body, tt.want)
+				}
+			}
+		})
+	}
+}
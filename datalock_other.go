@@ -0,0 +1,16 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package main
+
+import "os"
+
+// lockFile does nothing on platforms without a supported file lock; a second server
+// in the same directory goes undetected there
+func lockFile(file *os.File, shared bool) error {
+	return nil
+}
+
+// unlockFile does nothing, like lockFile
+func unlockFile(file *os.File) error {
+	return nil
+}
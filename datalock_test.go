@@ -0,0 +1,72 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestDataLockHolder is the second process for TestDataLockHeldByAnotherProcess: it
+// takes the directory lock, says so, and holds it until its stdin closes
+func TestDataLockHolder(t *testing.T) {
+	dir := os.Getenv("GO_REST_LOCK_HOLDER_DIR")
+	if dir == "" {
+		t.Skip("only runs as a helper process")
+	}
+	t.Chdir(dir)
+	if err := acquireDataLock(); err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout.WriteString("locked\n")
+	bufio.NewReader(os.Stdin).ReadString('\n')
+}
+
+func TestDataLockHeldByAnotherProcess(t *testing.T) {
+	dir := t.TempDir()
+	holder := exec.Command(os.Args[0], "-test.run=^TestDataLockHolder$")
+	holder.Env = append(os.Environ(), "GO_REST_LOCK_HOLDER_DIR="+dir)
+	stdin, err := holder.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := holder.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := holder.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		stdin.Close()
+		holder.Wait()
+	})
+	if line, _ := bufio.NewReader(stdout).ReadString('\n'); strings.TrimSpace(line) != "locked" {
+		t.Fatalf("helper didn't take the lock: %q", line)
+	}
+
+	t.Chdir(dir)
+	err = acquireDataLock()
+	if !errors.Is(err, errDataLocked) {
+		t.Fatalf("got %v, want %v", err, errDataLocked)
+	}
+	if !strings.Contains(err.Error(), "PID "+strconv.Itoa(holder.Process.Pid)) {
+		t.Errorf("error %q doesn't name the holder's PID %d", err, holder.Process.Pid)
+	}
+
+	// The lock goes with the process that held it
+	stdin.Close()
+	if err := holder.Wait(); err != nil {
+		t.Fatalf("helper failed: %v", err)
+	}
+	if err := acquireDataLock(); err != nil {
+		t.Fatalf("after the holder exited: %v", err)
+	}
+	dataLock.Close()
+	dataLock = nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCopyEnvironmentUsesTheMatchedSource(t *testing.T) {
+	t.Chdir(t.TempDir())
+	store := newMemStore(t, &SavedRequestsData{
+		Environments: []Environment{
+			{ID: "dev", Name: "Dev", Variables: []Variable{{Key: "baseUrl", Value: "http://dev.test"}}},
+			{ID: "staging", Name: "Staging", Variables: []Variable{{Key: "baseUrl", Value: "http://staging.test"}}},
+			{ID: "prod", Name: "Prod", Variables: []Variable{{Key: "baseUrl", Value: "http://prod.test"}}},
+		},
+		CurrentEnvironment: "dev",
+	})
+	api := newAPI(store)
+
+	// Neither the source nor the target is the last environment
+	rec := callHandler(api.copyEnvironment, http.MethodPost, "/api/environments/staging/copy",
+		`{"sourceEnvironmentId":"dev"}`, "id", "staging")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"dev": "http://dev.test", "staging": "http://dev.test", "prod": "http://prod.test"}
+	for _, env := range loaded.Environments {
+		if len(env.Variables) != 1 || env.Variables[0].Value != want[env.ID] {
+			t.Errorf("%s: variables %+v, want baseUrl = %s", env.ID, env.Variables, want[env.ID])
+		}
+	}
+}
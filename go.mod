@@ -2,4 +2,11 @@ module go-rest
 
 go 1.24.3
 
-require github.com/go-chi/chi/v5 v5.2.2
+require (
+	github.com/fsnotify/fsnotify v1.8.0
+	github.com/go-chi/chi/v5 v5.2.2
+	github.com/itchyny/gojq v0.12.7
+	golang.org/x/sys v0.13.0
+)
+
+require github.com/itchyny/timefmt-go v0.1.3 // indirect
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestGroupHandlersUseTheAPIStore(t *testing.T) {
+	t.Chdir(t.TempDir())
+	store := newMemStore(t, &SavedRequestsData{Requests: []SavedRequest{
+		{ID: "r1", Name: "List invoices", Method: "GET", URL: "http://example.test/invoices", Group: "default"},
+	}})
+	api := newAPI(store)
+
+	rec := callHandler(api.createGroup, http.MethodPost, "/api/groups", `{"name":"Billing","color":"#3b82f6"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("create: status = %d: %s", rec.Code, rec.Body)
+	}
+	data, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var billing *Group
+	for i := range data.Groups {
+		if data.Groups[i].Name == "Billing" {
+			billing = &data.Groups[i]
+		}
+	}
+	if billing == nil {
+		t.Fatalf("created group isn't in the store: %+v", data.Groups)
+	}
+
+	rec = callHandler(api.groups, http.MethodGet, "/api/groups?tree=true", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list: status = %d: %s", rec.Code, rec.Body)
+	}
+	var listed struct{ Groups []GroupNode }
+	if err := json.NewDecoder(rec.Body).Decode(&listed); err != nil {
+		t.Fatal(err)
+	}
+	if len(listed.Groups) != 2 {
+		t.Errorf("listed %d top-level groups, want default and Billing", len(listed.Groups))
+	}
+
+	rec = callHandler(api.deleteGroup, http.MethodDelete, "/api/groups/"+billing.ID, "", "id", billing.ID)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("delete: status = %d: %s", rec.Code, rec.Body)
+	}
+	if data, err = store.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if findGroup(data, billing.ID) != nil {
+		t.Error("deleted group is still in the store")
+	}
+
+	if _, err := os.Stat(requestsFileName); !os.IsNotExist(err) {
+		t.Errorf("handlers touched the data file (stat: %v)", err)
+	}
+}
+
+func TestLoadReassignsRequestsInDeletedGroups(t *testing.T) {
+	t.Chdir(t.TempDir())
+	// A data file edited by hand: the "Legacy" group was removed, but a request still
+	// names it
+	crafted := `{
+  "requests": [
+    {"id": "orphan", "name": "Old endpoint", "method": "GET", "url": "http://example.test/old", "group": "Legacy"},
+    {"id": "kept", "name": "Invoices", "method": "GET", "url": "http://example.test/invoices", "group": "Billing"}
+  ],
+  "groups": [
+    {"id": "g-default", "name": "default"},
+    {"id": "g-billing", "name": "Billing"}
+  ]
+}`
+	if err := os.WriteFile(requestsFileName, []byte(crafted), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := fileStore{}.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	groupOf := map[string]string{}
+	for _, req := range data.Requests {
+		groupOf[req.ID] = req.Group
+	}
+	if groupOf["orphan"] != "default" {
+		t.Errorf("orphaned request is in %q, want default", groupOf["orphan"])
+	}
+	if groupOf["kept"] != "Billing" {
+		t.Errorf("request in an existing group moved to %q", groupOf["kept"])
+	}
+
+	// The repair report names the move, and a dry run leaves the file alone
+	rec := callHandler(newAPI(newFileStore()).repairWorkspace, http.MethodPost, "/api/admin/repair?dryRun=true", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("repair: status = %d: %s", rec.Code, rec.Body)
+	}
+	var report struct{ Actions []RepairAction }
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, action := range report.Actions {
+		if action.Kind == repairReassignedRequest && action.ID == "orphan" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("repair report %+v doesn't list the orphaned request", report.Actions)
+	}
+	if contents, err := os.ReadFile(requestsFileName); err != nil || string(contents) != crafted {
+		t.Errorf("loading or a dry run rewrote the data file (%v)", err)
+	}
+}
+
+func TestLoadRepairsAreSavedOnce(t *testing.T) {
+	t.Chdir(t.TempDir())
+	crafted := `{
+  "requests": [{"id": "orphan", "name": "Old endpoint", "method": "GET", "url": "http://example.test/old", "group": "Legacy"}],
+  "groups": [{"id": "g-default", "name": "default"}]
+}`
+	if err := os.WriteFile(requestsFileName, []byte(crafted), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	newAPI(newFileStore()).saveLoadRepairs()
+
+	data, err := fileStore{}.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.repairs) != 0 {
+		t.Errorf("loading after the repair pass still repairs: %+v", data.repairs)
+	}
+	if len(data.Requests) != 1 || data.Requests[0].Group != "default" {
+		t.Errorf("requests = %+v, want the orphan saved in default", data.Requests)
+	}
+}
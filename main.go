@@ -23,28 +23,62 @@
 // - /api/variables/* - Environment variable management
 // - /api/environments/* - Environment management
 // - /api/groups/* - Request grouping
+// - /api/workspaces/* - Separate data sets, each in its own file
+// - /api/audit - Log of changes to requests, environments and groups
 // - /api/settings/* - UI preferences
 
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
-	"log"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"math"
+	"math/big"
+	"mime"
+	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
 	"regexp"
+	"runtime/debug"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/itchyny/gojq"
 )
 
 // =============================================================================
@@ -53,41 +87,200 @@ import (
 
 // ProxyRequest represents an HTTP request to be proxied to an external API
 type ProxyRequest struct {
-	URL       string            `json:"url"`
-	Method    string            `json:"method"`
-	Headers   map[string]string `json:"headers"`
-	BodyType  string            `json:"bodyType"`           // Type of body: "text", "json", "form"
-	BodyJson  []BodyField       `json:"bodyJson"`           // Typed JSON fields
-	BodyForm  []BodyField       `json:"bodyForm,omitempty"` // Form fields
-	Variables []Variable        `json:"variables"`
+	Name          string            `json:"name,omitempty"`      // Saved request name, used to detect pre-request cycles
+	RequestID     string            `json:"requestId,omitempty"` // Saved request ID, used to record status history
+	Group         string            `json:"group,omitempty"`     // Saved request group, used to inherit group auth
+	EnvironmentID string            `json:"-"`                   // Active environment, set by the server for nested runRequest steps
+	URL           string            `json:"url"`
+	PathParams    []QueryParam      `json:"pathParams,omitempty"` // Values for {name} segments in the URL path
+	Method        string            `json:"method"`
+	Headers       map[string]string `json:"headers"`
+	HeaderList    []QueryParam      `json:"headerList,omitempty"` // Ordered headers; names may repeat. Wins over headers for the names it lists
+	Body          string            `json:"body,omitempty"`       // Raw body, used when no typed fields apply
+	BodyType      string            `json:"bodyType"`             // Type of body: "text", "json", "form", "binary"
+	BodyFile      string            `json:"bodyFile,omitempty"`   // Binary body: path of a file streamed from disk
+	BodyBase64    string            `json:"bodyBase64,omitempty"` // Binary body: base64-encoded content
+	BodyJson      []BodyField       `json:"bodyJson"`             // Typed JSON fields
+	BodyForm      []BodyField       `json:"bodyForm,omitempty"`   // Form fields
+	Variables     []Variable        `json:"variables"`
+	Overrides     []Variable        `json:"overrides,omitempty"`        // Take precedence over environment variables
+	PreRequest    []PreRequestStep  `json:"preRequest,omitempty"`       // Steps executed before sending
+	Strict        bool              `json:"strictTemplates,omitempty"`  // Refuse to send with unresolved placeholders
+	Auth          *AuthConfig       `json:"auth,omitempty"`             // Authentication applied before sending
+	Expect        *Expectation      `json:"expect,omitempty"`           // Checks run against the response
+	FollowPages   bool              `json:"followPagination,omitempty"` // Follow rel="next" Link headers and combine JSON array pages
+	MaxPages      int               `json:"maxPages,omitempty"`         // Page cap when following pagination (default 10)
+	Transform     string            `json:"transform,omitempty"`        // jq expression applied to the JSON response body
+	TimeoutMs     int               `json:"timeoutMs,omitempty"`        // Overrides the environment's defaultTimeoutMs
+	NoCompression bool              `json:"noCompression,omitempty"`    // Don't add Accept-Encoding: gzip (a header you set is still sent)
+	NoUserAgent   bool              `json:"noUserAgent,omitempty"`      // Don't add the default User-Agent (a header you set is still sent)
+	HostOverrides map[string]string `json:"hostOverrides,omitempty"`    // Host or host:port -> address to connect to instead; wins over the environment's
+	HTTPVersion   string            `json:"httpVersion,omitempty"`      // "auto" (default), "http1" or "h2"
 }
 
 // ProxyResponse represents the response from a proxied HTTP request
 type ProxyResponse struct {
-	Status     string            `json:"status"`
-	StatusCode int               `json:"statusCode"`
-	Headers    map[string]string `json:"headers"`
-	Body       any               `json:"body"`
-	Error      string            `json:"error,omitempty"`
+	Status                string             `json:"status"`
+	StatusCode            int                `json:"statusCode"`
+	Headers               map[string]string  `json:"headers"`
+	Body                  any                `json:"body"`
+	RawBody               string             `json:"rawBody,omitempty"` // JSON body exactly as received, up to maxRawBodyBytes
+	Error                 string             `json:"error,omitempty"`
+	ErrorKind             string             `json:"errorKind,omitempty"`             // "none" when the upstream answered, otherwise why it couldn't be reached
+	Request               *ProxyRequest      `json:"request,omitempty"`               // Echo of the request as actually sent
+	Unresolved            []string           `json:"unresolved,omitempty"`            // Placeholders left unresolved (strict mode)
+	StartedAt             string             `json:"startedAt,omitempty"`             // When the outbound request was sent (RFC3339)
+	DurationMs            int64              `json:"durationMs,omitempty"`            // Time from sending until the body was read
+	SizeBytes             int                `json:"sizeBytes,omitempty"`             // Bytes of response body actually received
+	ReportedLength        *int64             `json:"reportedLength,omitempty"`        // Content-Length sent by the server, if any
+	TransferEncoding      []string           `json:"transferEncoding,omitempty"`      // e.g. ["chunked"] when no length was sent
+	ContentLengthMismatch bool               `json:"contentLengthMismatch,omitempty"` // Bytes received differ from the reported length
+	GrpcStatus            *int               `json:"grpcStatus,omitempty"`            // gRPC-Web status code from the trailers
+	GrpcMessage           string             `json:"grpcMessage,omitempty"`           // gRPC-Web status message
+	Expectations          *ExpectationResult `json:"expectations,omitempty"`          // Outcome of the request's expect checks
+	Pages                 int                `json:"pages,omitempty"`                 // Pages fetched when following pagination
+	PaginationNote        string             `json:"paginationNote,omitempty"`        // Why pagination stopped before the last page
+	BodyTransformed       any                `json:"bodyTransformed,omitempty"`       // Result of the request's transform expression
+	SentHeaders           []HARNameValue     `json:"sentHeaders,omitempty"`           // Header fields as written on the wire, including ones the client added
+	BodyParsed            *bool              `json:"bodyParsed,omitempty"`            // false when a JSON body was over the parse limit and is left as text
+	Connection            *ConnectionInfo    `json:"connection,omitempty"`            // Where the response came from and how the connection was secured
+	Timings               *ResponseTimings   `json:"timings,omitempty"`               // Where the time went, from httptrace
+	HTTPVersion           string             `json:"httpVersion,omitempty"`           // Protocol the response came over, e.g. "HTTP/2.0"
+	HostOverrides         map[string]string  `json:"hostOverrides,omitempty"`         // Connections a host override redirected: host:port -> address dialed
+	TransformError        string             `json:"transformError,omitempty"`        // Why the transform expression couldn't be applied
+	ExecutionID           string             `json:"executionId,omitempty"`           // ID that DELETE /api/proxy/{executionId} cancels this request by
+
+	nextPage   string        // rel="next" target from the Link headers, resolved against the request URL
+	retryAfter time.Duration // Set when the rate limiter refused the request
+}
+
+// Expectation describes what a successful response looks like; every field is optional
+type Expectation struct {
+	Status        *int     `json:"status,omitempty"`        // Exact status code
+	MaxDurationMs *int64   `json:"maxDurationMs,omitempty"` // Upper bound on the response time
+	BodyFields    []string `json:"bodyFields,omitempty"`    // Dot paths that must be present in the body
+}
+
+// ConnectionInfo describes the connection a response arrived on; after redirects it
+// describes the connection of the last hop.
+type ConnectionInfo struct {
+	RemoteAddr  string `json:"remoteAddr"`            // IP and port actually connected to
+	Reused      bool   `json:"reused,omitempty"`      // Taken from the idle pool rather than newly opened
+	TLSVersion  string `json:"tlsVersion,omitempty"`  // e.g. "TLS 1.3"; the TLS fields are omitted for plain HTTP
+	CipherSuite string `json:"cipherSuite,omitempty"` // e.g. "TLS_AES_128_GCM_SHA256"
+	Protocol    string `json:"protocol,omitempty"`    // Negotiated by ALPN, e.g. "h2"
+	ServerName  string `json:"serverName,omitempty"`  // Name sent for SNI and checked against the certificate
+
+	Certificates             []CertificateInfo `json:"certificates,omitempty"`             // Chain the server sent, its own certificate first
+	CertificateExpiresInDays *int              `json:"certificateExpiresInDays,omitempty"` // Days left on whichever certificate in the chain expires first
+	CertificateSummary       string            `json:"certificateSummary,omitempty"`       // e.g. "expires in 12 days"
+}
+
+// ResponseTimings breaks a request's time down by phase, in milliseconds
+//
+// DNS, connect and TLS cover the connection of the last hop; they are 0 when an idle
+// connection was reused, or when there was nothing to look up or no TLS.
+type ResponseTimings struct {
+	DNSMs     float64 `json:"dnsMs"`
+	ConnectMs float64 `json:"connectMs"`
+	TLSMs     float64 `json:"tlsMs"`
+	TTFBMs    float64 `json:"ttfbMs"`  // From sending until the first response byte, including the phases above
+	TotalMs   float64 `json:"totalMs"` // Until the body was read, like durationMs but with fractions
+}
+
+// CertificateInfo describes one certificate of a server's chain
+type CertificateInfo struct {
+	Subject         string   `json:"subject"`
+	Issuer          string   `json:"issuer"`
+	SANs            []string `json:"sans,omitempty"` // DNS names and IP addresses the certificate is valid for
+	NotBefore       string   `json:"notBefore"`
+	NotAfter        string   `json:"notAfter"`
+	DaysUntilExpiry int      `json:"daysUntilExpiry"` // Whole days left; negative once expired
+}
+
+// ExpectationResult reports whether a response met its expectations
+type ExpectationResult struct {
+	Passed   bool     `json:"passed"`
+	Failures []string `json:"failures,omitempty"` // One message per unmet expectation
+}
+
+// GrpcWebBody is the unframed body of a gRPC-Web response
+type GrpcWebBody struct {
+	Messages []string          `json:"messages"`           // Base64-encoded message bytes, one per data frame
+	Trailers map[string]string `json:"trailers,omitempty"` // Trailer frame contents, keys lower-cased
+}
+
+// PreviewResponse is the result of a dry run of the template pipeline
+type PreviewResponse struct {
+	Request       ProxyRequest        `json:"request"`       // Request as it would be sent
+	Substitutions []Substitution      `json:"substitutions"` // Placeholders that were replaced
+	Unresolved    []string            `json:"unresolved"`    // Placeholders left in place
+	Warnings      []string            `json:"warnings"`      // Cycles, missing requests and similar problems
+	Variables     []EffectiveVariable `json:"variables"`     // Merged variable set with the source of each value
+	AuthSource    string              `json:"authSource"`    // "request", "group:<name>" or "none"
+}
+
+// Substitution records the value a placeholder resolved to
+type Substitution struct {
+	Placeholder string `json:"placeholder"`
+	Value       string `json:"value"`
+	Dynamic     bool   `json:"dynamic,omitempty"` // Generated values differ on every send
 }
 
 // SavedRequest represents a saved API request configuration
 type SavedRequest struct {
-	ID           string            `json:"id"`
-	Name         string            `json:"name"`
-	URL          string            `json:"url"`
-	Method       string            `json:"method"`
-	Headers      map[string]string `json:"headers"`
-	BodyType     string            `json:"bodyType,omitempty"` // Current body type (text, json, form)
-	BodyText     string            `json:"bodyText,omitempty"` // Raw text body
-	BodyJson     []BodyField       `json:"bodyJson,omitempty"` // JSON key-value pairs
-	BodyForm     []BodyField       `json:"bodyForm,omitempty"` // Form data
-	Params       []QueryParam      `json:"params"`
-	Group        string            `json:"group"`
-	Description  string            `json:"description"`
-	LastResponse *ProxyResponse    `json:"lastResponse,omitempty"` // Cache last response for variable references
-	CreatedAt    string            `json:"createdAt"`
-	UpdatedAt    string            `json:"updatedAt"`
+	ID            string            `json:"id"`
+	Name          string            `json:"name"`
+	URL           string            `json:"url"`
+	Method        string            `json:"method"`
+	Headers       map[string]string `json:"headers"`
+	HeaderList    []QueryParam      `json:"headerList,omitempty"` // Ordered headers, the source of truth; headers holds the enabled ones by name
+	BodyType      string            `json:"bodyType,omitempty"`   // Current body type (text, json, form, binary)
+	BodyText      string            `json:"bodyText,omitempty"`   // Raw text body, kept as text and only parsed when sent
+	BodyFile      string            `json:"bodyFile,omitempty"`   // Binary body file path
+	BodyBase64    string            `json:"bodyBase64,omitempty"` // Binary body base64 content
+	BodyJson      []BodyField       `json:"bodyJson,omitempty"`   // JSON key-value pairs
+	BodyForm      []BodyField       `json:"bodyForm,omitempty"`   // Form data
+	Params        []QueryParam      `json:"params"`
+	PathParams    []QueryParam      `json:"pathParams,omitempty"` // Values for {name} segments in the URL path
+	Group         string            `json:"group"`
+	Description   string            `json:"description"`
+	Tags          []string          `json:"tags,omitempty"`            // Lower-case labels; "smoke" marks requests run by /api/smoke
+	Overrides     []Variable        `json:"overrides,omitempty"`       // Per-request values that take precedence over the environment
+	PreRequest    []PreRequestStep  `json:"preRequest,omitempty"`      // Declarative steps run before sending
+	Template      bool              `json:"template,omitempty"`        // Reusable scaffold, hidden from normal listing
+	Pinned        bool              `json:"pinned,omitempty"`          // Listed first within its group; copies start unpinned
+	Strict        bool              `json:"strictTemplates,omitempty"` // Refuse to send with unresolved placeholders
+	Auth          *AuthConfig       `json:"auth,omitempty"`            // Authentication applied before sending
+	ExternalID    string            `json:"externalId,omitempty"`      // Stable key set by sync tools for upserts
+	LastResponse  *ProxyResponse    `json:"lastResponse,omitempty"`    // Cache last response for variable references
+	StatusHistory []int             `json:"statusHistory,omitempty"`   // Status codes of recent sends, oldest first (0 = no response)
+	CreatedAt     string            `json:"createdAt"`
+	UpdatedAt     string            `json:"updatedAt"`
+	Version       int               `json:"version"`             // Incremented on every update for optimistic concurrency
+	DeletedAt     string            `json:"deletedAt,omitempty"` // Set when the request is moved to trash
+}
+
+// AuthConfig describes how a request authenticates with the target API
+type AuthConfig struct {
+	Type   string        `json:"type"` // "oauth2", or "none" to opt out of group auth
+	OAuth2 *OAuth2Config `json:"oauth2,omitempty"`
+}
+
+// OAuth2Config holds client-credentials grant settings; every field supports templates
+type OAuth2Config struct {
+	TokenURL     string `json:"tokenUrl"`
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// PreRequestStep is a declarative action executed before a request is sent
+type PreRequestStep struct {
+	Type    string `json:"type"`              // "setHeader", "setVariable", or "runRequest"
+	Key     string `json:"key,omitempty"`     // Header or variable name for set steps
+	Value   string `json:"value,omitempty"`   // Template resolved to produce the header/variable value
+	Request string `json:"request,omitempty"` // Saved request name for runRequest steps
 }
 
 // QueryParam represents a URL query parameter
@@ -108,35 +301,63 @@ type BodyField struct {
 
 // Variable represents an environment variable for template substitution
 type Variable struct {
-	Key   string `json:"key"`
-	Value string `json:"value"`
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Secret bool   `json:"secret,omitempty"` // Masked in API responses and logs, left out of bundle exports
 }
 
 // Environment groups variables together for different contexts (dev, prod, etc.)
 type Environment struct {
-	ID        string     `json:"id"`
-	Name      string     `json:"name"`
-	Variables []Variable `json:"variables"`
-	CreatedAt string     `json:"createdAt"`
-	UpdatedAt string     `json:"updatedAt"`
+	ID               string            `json:"id"`
+	Name             string            `json:"name"`
+	Variables        []Variable        `json:"variables"`
+	Headers          map[string]string `json:"headers,omitempty"`          // Sent with every request; request headers win on conflict
+	BaseURL          string            `json:"baseUrl,omitempty"`          // Prefixed to request URLs that start with "/"
+	ParentID         string            `json:"parentId,omitempty"`         // Environment whose variables this one inherits
+	DefaultTimeoutMs int               `json:"defaultTimeoutMs,omitempty"` // For requests without timeoutMs; 0 uses the server default
+	HostOverrides    map[string]string `json:"hostOverrides,omitempty"`    // Host or host:port -> address to connect to instead, like an /etc/hosts entry
+	CreatedAt        string            `json:"createdAt"`
+	UpdatedAt        string            `json:"updatedAt"`
+	Version          int               `json:"version"` // Incremented on every update for optimistic concurrency
 }
 
 // Group organizes saved requests into categories
 type Group struct {
-	ID        string `json:"id"`
-	Name      string `json:"name"`
-	CreatedAt string `json:"createdAt"`
-	UpdatedAt string `json:"updatedAt"`
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Color       string      `json:"color"`              // Hex color such as #3b82f6; empty for none
+	ParentID    string      `json:"parentId,omitempty"` // Enclosing group; empty for top-level groups
+	Order       int         `json:"order"`              // Display position among siblings, lowest first
+	Auth        *AuthConfig `json:"auth,omitempty"`     // Inherited by requests in the group and its subgroups
+	CreatedAt   string      `json:"createdAt"`
+	UpdatedAt   string      `json:"updatedAt"`
+}
+
+// Settings are a workspace's preferences
+type Settings struct {
+	WordWrap         bool   `json:"wordWrap"`
+	StrictTemplates  bool   `json:"strictTemplates"`     // Global strict template mode
+	DraftIdleSeconds int    `json:"draftIdleSeconds"`    // Idle time before the UI auto-saves a draft
+	UserAgent        string `json:"userAgent,omitempty"` // Default User-Agent for proxied requests; empty means go-rest/<version>
 }
 
 // SavedRequestsData is the main container for all application data
 type SavedRequestsData struct {
-	Requests           []SavedRequest `json:"requests"`
-	Variables          []Variable     `json:"variables"` // Legacy - kept for backward compatibility
-	Environments       []Environment  `json:"environments"`
-	CurrentEnvironment string         `json:"currentEnvironment"`
-	Groups             []Group        `json:"groups"`
-	WordWrap           bool           `json:"wordWrap"`
+	Requests           []SavedRequest   `json:"requests"`
+	Variables          []Variable       `json:"variables"` // Legacy - kept for backward compatibility
+	Environments       []Environment    `json:"environments"`
+	CurrentEnvironment string           `json:"currentEnvironment"`
+	Globals            []Variable       `json:"globals"` // Shared by every environment, at lower precedence
+	Groups             []Group          `json:"groups"`
+	Settings                            // Encoded inline, alongside the other fields
+	Trash              []SavedRequest   `json:"trash"`            // Soft-deleted requests awaiting restore or purge
+	Drafts             map[string]Draft `json:"drafts,omitempty"` // Unsaved edits keyed by request ID ("new" for an unsaved request)
+
+	path        string         // Data file this was loaded from; saves are written back to it
+	loaded      bool           // Read from disk by fileStore.Load, rather than built in memory
+	loadedStamp fileStamp      // Version of the file that was read, to catch edits made since
+	repairs     []RepairAction // Fixes made to the data since it was read, for /api/admin/repair
 }
 
 // =============================================================================
@@ -167,6 +388,221 @@ func parseJSON(body any) any {
 	return bodyStr // Not valid JSON, return original string
 }
 
+// isJSONContentType reports whether a media type denotes JSON (including +json suffixes)
+func isJSONContentType(mediaType string) bool {
+	return mediaType == "application/json" || mediaType == "text/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// defaultMaxParseBytes is the largest response body parsed as JSON unless -max-parse-bytes
+// says otherwise; a parsed tree takes several times the memory of its text
+const defaultMaxParseBytes = 10 << 20
+
+// maxParseBytes is set by -max-parse-bytes / MAX_PARSE_BYTES; 0 parses bodies of any size
+var maxParseBytes = defaultMaxParseBytes
+
+// parseResponseBody decodes a response body according to its Content-Type
+//
+// JSON content types are parsed into objects, while XML, HTML and plain text are kept
+// as strings so error pages aren't mis-detected. When the server sends no Content-Type
+// the body is sniffed as JSON for backward compatibility. A body over maxParseBytes that
+// would be parsed is returned as a string instead, with skipped set.
+func parseResponseBody(body []byte, contentType string) (parsed any, skipped bool) {
+	if strings.TrimSpace(contentType) != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err == nil && !isJSONContentType(mediaType) {
+			return string(body), false
+		}
+	}
+
+	if maxParseBytes > 0 && len(body) > maxParseBytes {
+		return string(body), true
+	}
+	return parseJSON(string(body)), false
+}
+
+// parseMaxParseBytes reads the -max-parse-bytes flag: a byte count, 0 for no limit
+func parseMaxParseBytes(value string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("-max-parse-bytes must be a number of bytes, 0 or more, got %q", value)
+	}
+	return n, nil
+}
+
+// maxRawBodyBytes caps the JSON text kept in rawBody; larger bodies are only kept parsed
+const maxRawBodyBytes = 1 << 20
+
+// preciseBody returns the response body for reading values out of it
+//
+// Decoding into any turns every number into a float64, so an ID like
+// 9007199254740993 loses its last digit. When the raw JSON was kept it is decoded
+// again with json.Number, which keeps numbers exactly as the server wrote them.
+func preciseBody(resp *ProxyResponse) any {
+	if resp.RawBody == "" {
+		return resp.Body
+	}
+	decoder := json.NewDecoder(strings.NewReader(resp.RawBody))
+	decoder.UseNumber()
+	var body any
+	if err := decoder.Decode(&body); err != nil {
+		return resp.Body
+	}
+	return body
+}
+
+// headerValue looks up a header in a map case-insensitively
+func headerValue(headers map[string]string, name string) string {
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+// setDefaultHeader sets a header only when the user hasn't set it under any casing,
+// so explicit headers always win over automatic ones
+func setDefaultHeader(headers map[string]string, name, value string) {
+	for k := range headers {
+		if strings.EqualFold(k, name) {
+			return
+		}
+	}
+	headers[name] = value
+}
+
+// defaultUserAgent is the User-Agent sent with proxied requests that don't set one:
+// the userAgent setting, or go-rest/<version> so targets can tell this tool's traffic
+// apart from other Go clients
+func defaultUserAgent(settings Settings) string {
+	if settings.UserAgent != "" {
+		return settings.UserAgent
+	}
+	return "go-rest/" + version
+}
+
+// applyDefaultUserAgent adds the default User-Agent unless the request sets its own or
+// asks for none with noUserAgent. It runs after pre-request steps so setHeader wins too.
+func applyDefaultUserAgent(req *ProxyRequest, data *SavedRequestsData) {
+	if req.NoUserAgent {
+		return
+	}
+	if req.Headers == nil {
+		req.Headers = make(map[string]string)
+	}
+	setDefaultHeader(req.Headers, "User-Agent", defaultUserAgent(data.Settings))
+}
+
+// headerListFromMap converts a header map into the ordered list form, sorted by name
+// since a map has no order of its own
+func headerListFromMap(headers map[string]string) []QueryParam {
+	list := make([]QueryParam, 0, len(headers))
+	for _, name := range sortedHeaderNames(headers) {
+		list = append(list, QueryParam{Key: name, Value: headers[name], Enabled: true})
+	}
+	return list
+}
+
+// headerMapFromList returns the enabled entries of a header list by name
+//
+// A repeated name keeps the spelling of its first entry and has its values joined
+// with ", ", which HTTP treats as the same field sent several times.
+func headerMapFromList(list []QueryParam) map[string]string {
+	headers := make(map[string]string, len(list))
+	keys := make(map[string]string) // Canonical name to the key used in headers
+	for _, entry := range list {
+		if !entry.Enabled || strings.TrimSpace(entry.Key) == "" {
+			continue
+		}
+		canonical := http.CanonicalHeaderKey(entry.Key)
+		if key, seen := keys[canonical]; seen {
+			headers[key] += ", " + entry.Value
+			continue
+		}
+		keys[canonical] = entry.Key
+		headers[entry.Key] = entry.Value
+	}
+	return headers
+}
+
+// repeatedHeaderValues returns the values of every enabled header the list names more
+// than once, in list order, keyed by canonical name
+func repeatedHeaderValues(list []QueryParam) map[string][]string {
+	values := make(map[string][]string)
+	for _, entry := range list {
+		if entry.Enabled && strings.TrimSpace(entry.Key) != "" {
+			canonical := http.CanonicalHeaderKey(entry.Key)
+			values[canonical] = append(values[canonical], entry.Value)
+		}
+	}
+	for name, vals := range values {
+		if len(vals) < 2 {
+			delete(values, name)
+		}
+	}
+	return values
+}
+
+// applyHeaderList makes a request's header list the source of its headers map
+//
+// Names the list mentions, enabled or not, are taken from the list; names only in the
+// map are kept, so a client sending both forms loses nothing.
+func applyHeaderList(req *ProxyRequest) {
+	if len(req.HeaderList) == 0 {
+		return
+	}
+	listed := make(map[string]bool, len(req.HeaderList))
+	for _, entry := range req.HeaderList {
+		listed[http.CanonicalHeaderKey(entry.Key)] = true
+	}
+	headers := headerMapFromList(req.HeaderList)
+	for key, value := range req.Headers {
+		if !listed[http.CanonicalHeaderKey(key)] {
+			headers[key] = value
+		}
+	}
+	req.Headers = headers
+}
+
+// savedHeaders returns both header forms for a saved request from whichever the client
+// sent; the list wins when both are present
+func savedHeaders(headers map[string]string, list []QueryParam) (map[string]string, []QueryParam) {
+	if len(list) > 0 {
+		return headerMapFromList(list), list
+	}
+	return headers, headerListFromMap(headers)
+}
+
+// isFormContentType reports whether a Content-Type header denotes a form-encoded body
+func isFormContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/x-www-form-urlencoded"
+}
+
+// parseFormBody decodes a form-encoded body into an object for field extraction
+//
+// Keys with a single value map to strings; repeated keys map to arrays of strings.
+func parseFormBody(body string) (map[string]any, error) {
+	values, err := url.ParseQuery(strings.TrimSpace(body))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]any, len(values))
+	for key, vals := range values {
+		if len(vals) == 1 {
+			result[key] = vals[0]
+			continue
+		}
+		items := make([]any, len(vals))
+		for i, v := range vals {
+			items[i] = v
+		}
+		result[key] = items
+	}
+	return result, nil
+}
+
 // generateID creates a random ID for entities
 func generateID() string {
 	bytes := make([]byte, 8)
@@ -210,6 +646,9 @@ func convertTypedValue(value, valueType string) any {
 }
 
 // buildJSONFromBodyFields converts a flat list of BodyField entries into a JSON object
+//
+// Object members and array items come out in the order the fields are stored, since
+// some APIs and signature schemes depend on the order of a body's fields.
 func buildJSONFromBodyFields(fields []BodyField) (any, error) {
 	if len(fields) == 0 {
 		return map[string]any{}, nil
@@ -224,45 +663,84 @@ func buildJSONFromBodyFields(fields []BodyField) (any, error) {
 	}
 
 	// Build the JSON structure recursively starting from root
-	return buildContainer("root", fieldMap), nil
+	return buildContainer("root", fields, fieldMap), nil
 }
 
 // buildFormEncoded builds application/x-www-form-urlencoded string from BodyForm fields
+//
+// url.Values.Encode would sort the keys, so the pairs are joined by hand to keep the
+// stored field order.
 func buildFormEncoded(fields []BodyField) string {
-	values := url.Values{}
+	var pairs []string
 	for _, f := range fields {
 		if !f.Enabled || f.Key == "" {
 			continue
 		}
-		values.Add(f.Key, f.Value)
+		pairs = append(pairs, url.QueryEscape(f.Key)+"="+url.QueryEscape(f.Value))
 	}
-	return values.Encode()
+	return strings.Join(pairs, "&")
 }
 
-// buildContainer builds a JSON container (object or array) by finding all fields with the given parent
-func buildContainer(parentKey string, fieldMap map[string]*BodyField) any {
-	result := make(map[string]any)
+// jsonMember is one key and value of an orderedObject
+type jsonMember struct {
+	Key   string
+	Value any
+}
 
-	// Find all fields that have this parent
-	for _, field := range fieldMap {
-		if field.Parent == parentKey {
-			switch field.Type {
-			case "object", "array":
-				result[field.Key] = buildContainer(field.Key, fieldMap)
-			default:
-				// Regular field with a value
-				result[field.Key] = convertTypedValue(field.Value, field.Type)
-			}
+// orderedObject is a JSON object that marshals its members in order instead of
+// sorting them by key like a map
+type orderedObject []jsonMember
+
+// MarshalJSON writes the members in slice order
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, member := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(member.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(member.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// buildContainer builds a JSON container (object or array) from the fields with the given parent
+//
+// Fields are visited in stored order; when several share a key, fieldMap holds the
+// last of them and only that one is used.
+func buildContainer(parentKey string, fields []BodyField, fieldMap map[string]*BodyField) any {
+	result := orderedObject{}
+	for i := range fields {
+		field := &fields[i]
+		if field.Parent != parentKey || fieldMap[field.Key] != field {
+			continue
+		}
+		switch field.Type {
+		case "object", "array":
+			result = append(result, jsonMember{field.Key, buildContainer(field.Key, fields, fieldMap)})
+		default:
+			// Regular field with a value
+			result = append(result, jsonMember{field.Key, convertTypedValue(field.Value, field.Type)})
 		}
 	}
 
-	// If this container is meant to be an array, convert map values to array
+	// If this container is meant to be an array, keep only the values
 	if parentKey != "root" {
-		// Check if the parent field is an array type
 		if parentField, exists := fieldMap[parentKey]; exists && parentField.Type == "array" {
 			var arrayItems []any
-			for _, value := range result {
-				arrayItems = append(arrayItems, value)
+			for _, member := range result {
+				arrayItems = append(arrayItems, member.Value)
 			}
 			return arrayItems
 		}
@@ -275,749 +753,5650 @@ func buildContainer(parentKey string, fieldMap map[string]*BodyField) any {
 // MAIN SERVER SETUP
 // =============================================================================
 
+// Build information, set with:
+//
+//	go build -ldflags "-X main.version=1.4.0 -X main.commit=$(git rev-parse --short HEAD)"
+//
+// Without ldflags the commit is taken from the VCS stamp Go adds to builds from a checkout.
+var (
+	version = "dev"
+	commit  = ""
+)
+
+// serverStartedAt is when the process started, for the health check's uptime
+var serverStartedAt = time.Now()
+
+// frontendDir holds the built Svelte app served at /
+const frontendDir = "frontend/dist"
+
 func main() {
+	addrFlag := flag.String("addr", envOrDefault("ADDR", defaultListenHost), "interface to listen on; 0.0.0.0 for all (env ADDR)")
+	portFlag := flag.String("port", envOrDefault("PORT", defaultListenPort), "port to listen on; 0 picks a free port (env PORT)")
+	tlsCertFlag := flag.String("tls-cert", os.Getenv("TLS_CERT"), "PEM certificate file; serves over HTTPS (env TLS_CERT)")
+	tlsKeyFlag := flag.String("tls-key", os.Getenv("TLS_KEY"), "PEM private key file for -tls-cert (env TLS_KEY)")
+	tlsAutoFlag := flag.Bool("tls-auto", false, "serve over HTTPS with a self-signed certificate generated on first run")
+	redirectPortFlag := flag.String("http-redirect-port", "", "with HTTPS, also listen for plain HTTP on this port and redirect it to HTTPS")
+	authTokenFlag := flag.String("auth-token", os.Getenv("AUTH_TOKEN"), "require this bearer token or a sign-in for the API and UI (env AUTH_TOKEN)")
+	authUserFlag := flag.String("auth-user", os.Getenv("AUTH_USER"), "username for signing in; needs -auth-password (env AUTH_USER)")
+	authPasswordFlag := flag.String("auth-password", os.Getenv("AUTH_PASSWORD"), "password for signing in (env AUTH_PASSWORD)")
+	allowFlag := flag.String("allow", os.Getenv("PROXY_ALLOW"), "comma-separated CIDRs, IPs and host patterns the proxy may reach; empty allows all (env PROXY_ALLOW)")
+	denyFlag := flag.String("deny", os.Getenv("PROXY_DENY"), "comma-separated CIDRs, IPs and host patterns the proxy must not reach (env PROXY_DENY)")
+	hardenFlag := flag.Bool("harden", false, "also deny link-local addresses and cloud metadata endpoints")
+	readOnlyFlag := flag.String("read-only", envOrDefault("READ_ONLY", "false"), "refuse every change to saved data; sending requests still works (env READ_ONLY)")
+	rateLimitFlag := flag.String("rate-limit", envOrDefault("RATE_LIMIT", "0"), "max proxied requests per second across all targets; 0 is unlimited (env RATE_LIMIT)")
+	hostRateLimitFlag := flag.String("rate-limit-host", envOrDefault("RATE_LIMIT_HOST", "0"), "max proxied requests per second to each target host; 0 is unlimited (env RATE_LIMIT_HOST)")
+	logFormatFlag := flag.String("log-format", envOrDefault("LOG_FORMAT", "text"), "log output format: text or json (env LOG_FORMAT)")
+	maxParseBytesFlag := flag.String("max-parse-bytes", envOrDefault("MAX_PARSE_BYTES", strconv.Itoa(defaultMaxParseBytes)), "largest JSON response body parsed; larger ones are returned as text; 0 is unlimited (env MAX_PARSE_BYTES)")
+	logLevelFlag := flag.String("log-level", envOrDefault("LOG_LEVEL", "info"), "minimum log level: debug, info, warn or error; debug adds redacted request details (env LOG_LEVEL)")
+	flag.Parse()
+	if err := setupLogging(*logFormatFlag, *logLevelFlag); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(2)
+	}
+	host, port, err := validateListenAddress(*addrFlag, *portFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(2)
+	}
+	certFile, keyFile, err := resolveTLSFiles(*tlsCertFlag, *tlsKeyFlag, *tlsAutoFlag, host)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(2)
+	}
+	redirectPort := ""
+	if *redirectPortFlag != "" {
+		if certFile == "" {
+			fmt.Fprintln(os.Stderr, "❌ -http-redirect-port needs HTTPS: add -tls-cert and -tls-key, or -tls-auto")
+			os.Exit(2)
+		}
+		if _, redirectPort, err = validateListenAddress(host, *redirectPortFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", strings.Replace(err.Error(), "-port", "-http-redirect-port", 1))
+			os.Exit(2)
+		}
+	}
+	accessConfig = AccessConfig{Token: *authTokenFlag, Username: *authUserFlag, Password: *authPasswordFlag}
+	if err := validateAccessConfig(accessConfig); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(2)
+	}
+	proxyTargetPolicy, err = parseTargetPolicy(*allowFlag, *denyFlag, *hardenFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(2)
+	}
+	if readOnly, err = strconv.ParseBool(strings.TrimSpace(*readOnlyFlag)); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ -read-only must be true or false, got %q\n", *readOnlyFlag)
+		os.Exit(2)
+	}
+	rateLimits, err := parseRateLimitFlags(*rateLimitFlag, *hostRateLimitFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(2)
+	}
+	proxyRateLimiter.Configure(rateLimits)
+	if maxParseBytes, err = parseMaxParseBytes(*maxParseBytesFlag); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(2)
+	}
+	if err := acquireDataLock(); err != nil {
+		if !errors.Is(err, errDataLocked) || !readOnly {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			if errors.Is(err, errDataLocked) {
+				fmt.Fprintln(os.Stderr, "💡 Stop the other go-rest server using this directory, or start this one with -read-only=true")
+			}
+			os.Exit(1)
+		}
+		// A read-only server writes none of the data, audit or history files, so it can
+		// share them with the server holding the lock
+		slog.Warn("continuing because this server is read-only", "error", err)
+	}
+	if !proxyTargetPolicy.Empty() {
+		// Through an HTTP proxy only the proxy's address would be checked, not the target's
+		proxyTransport.Proxy = nil
+		uncompressedTransport.Proxy = nil
+	}
+
+	api := newAPI(newFileStore())
+	api.saveLoadRepairs()
 	r := chi.NewRouter()
 
 	// Global middleware
-	r.Use(corsMiddleware, loggingMiddleware, middleware.Recoverer)
+	r.Use(corsMiddleware, loggingMiddleware, authMiddleware, sessionEnvironmentMiddleware, middleware.Recoverer)
+
+	// Sign-in for when access control is enabled
+	r.HandleFunc(loginPath, api.login)
+	r.Post(logoutPath, api.logout)
 
 	// API routes
 	r.Route("/api", func(r chi.Router) {
+		// Routes that change stored data are refused in read-only mode
+		write := r.With(readOnlyMiddleware)
+
 		// Core functionality
-		r.Post("/proxy", proxy)
-		r.Post("/json/build", buildJSON)
-		r.Post("/form/build", buildForm)
-		r.Get("/health", health)
+		r.Post("/proxy", api.proxy)
+		r.Post("/proxy/preview", api.previewProxy)
+		r.Post("/proxy/compare", api.compareProxy)
+		r.Post("/proxy/async", api.proxyAsync)
+		r.Get("/proxy/running", api.runningProxyRequests)
+		r.Delete("/proxy/{executionId}", api.cancelProxyRequest)
+		r.Get("/executions/{id}", api.asyncExecution)
+		r.Delete("/executions/{id}", api.asyncExecution)
+		r.Post("/smoke", api.smoke)
+		r.Post("/json/build", api.buildJSON)
+		r.Post("/form/build", api.buildForm)
+		r.Get("/health", api.health)
+		r.Post("/ping", api.ping)
 
 		// Request management
-		r.Get("/requests", requests)
-		r.Post("/requests/save", saveRequest)
-		r.Put("/requests/update", updateRequest)
-		r.Delete("/requests/delete", deleteRequest)
-		r.Post("/requests/duplicate", duplicateRequest)
+		r.Get("/requests", api.requests)
+		write.Post("/requests/save", api.saveRequest)
+		write.Post("/requests/bulk-save", api.bulkSaveRequests)
+		write.Put("/requests/update", api.updateRequest)
+		write.Put("/requests/upsert", api.upsertRequest)
+		write.Delete("/requests/delete", api.deleteRequest)
+		write.Post("/requests/duplicate", api.duplicateRequest)
+		write.Post("/requests/draft", api.saveDraft)
+		r.Get("/requests/{id}/draft", api.requestDraft)
+		r.Get("/requests/{id}/variables", api.requestVariables)
+		write.Delete("/requests/{id}/draft", api.discardDraft)
+		write.Post("/requests/{id}/instantiate", api.instantiateTemplate)
+		write.Post("/requests/{id}/pin", api.pinRequest)
+		r.Get("/requests/{id}/har", api.requestHAR)
+		r.Get("/requests/{id}/snippet", api.requestSnippet)
+		r.Get("/export/har", api.exportHAR)
+		r.Get("/export/bundle", api.exportBundle)
+		write.Post("/import/bundle", api.importBundle)
+		write.Post("/import/httpfile", api.importHTTPFile)
+
+		// Trash (soft-deleted requests)
+		r.Get("/trash", api.trash)
+		write.Post("/trash/{id}/restore", api.restoreTrash)
+		write.Delete("/trash/{id}", api.purgeTrash)
 
 		// Variable management
-		r.Get("/variables", variables)
-		r.Post("/variables/save", saveVariables)
+		r.Get("/variables", api.variables)
+		write.Post("/variables/save", api.saveVariables)
+		write.Post("/variables/replace", api.replaceVariables)
+		r.Get("/globals", api.globals)
+		write.Post("/globals", api.saveGlobals)
 
 		// Environment management
-		r.Get("/environments", environments)
-		r.Post("/environments", createEnvironment)
-		r.Put("/environments/{id}", updateEnvironment)
-		r.Delete("/environments/{id}", deleteEnvironment)
-		r.Post("/environments/{id}/copy", copyEnvironment)
-		r.Post("/environments/{id}/activate", activateEnvironment)
+		r.Get("/environments", api.environments)
+		write.Post("/environments", api.createEnvironment)
+		write.Post("/environments/import", api.importEnvironment)
+		r.Get("/environments/diff", api.environmentDiff)
+		write.Put("/environments/{id}", api.updateEnvironment)
+		r.Delete("/environments/session", api.clearSessionEnvironment)
+		write.Delete("/environments/{id}", api.deleteEnvironment)
+		write.Post("/environments/{id}/copy", api.copyEnvironment)
+		write.Post("/environments/{id}/clone", api.cloneEnvironment)
+		r.Get("/environments/{id}/export", api.exportEnvironment)
+		write.Post("/environments/{id}/activate", api.activateEnvironment)
+		r.Get("/environments/{id}/variables", api.environmentVariables)
+		write.Post("/environments/{id}/variables", api.addEnvironmentVariable)
+		write.Put("/environments/{id}/variables/{key}", api.updateEnvironmentVariable)
+		write.Delete("/environments/{id}/variables/{key}", api.deleteEnvironmentVariable)
 
 		// Group management
-		r.Get("/groups", groups)
-		r.Post("/groups", createGroup)
-		r.Delete("/groups/{id}", deleteGroup)
+		r.Get("/groups", api.groups)
+		write.Post("/groups", api.createGroup)
+		write.Post("/groups/reorder", api.reorderGroups)
+		write.Put("/groups/{id}", api.updateGroup)
+		write.Delete("/groups/{id}", api.deleteGroup)
+		r.Post("/groups/{id}/run", api.runGroup)
+
+		// Workspaces
+		r.Get("/workspaces", api.workspaces)
+		write.Post("/workspaces", api.createWorkspace)
+		write.Delete("/workspaces/{id}", api.deleteWorkspace)
+		write.Post("/workspaces/{id}/activate", api.activateWorkspace)
+
+		// Webhook capture; deliveries to /hooks/{slug} accept any method
+		r.Get("/hooks", api.hooks)
+		write.Post("/hooks", api.registerHook)
+		r.HandleFunc("/hooks/{slug}", api.captureHook)
+		r.Get("/hooks/{slug}/captured", api.capturedHooks)
+		write.Delete("/hooks/{slug}/captured", api.capturedHooks)
+
+		// Audit log and execution history
+		r.Get("/audit", api.audit)
+		r.Get("/history", api.history)
+		write.Delete("/history", api.history)
 
 		// Settings
-		r.Post("/settings/wordwrap", handleSaveWordWrap)
+		write.Post("/settings/wordwrap", api.handleSaveWordWrap)
+		write.Post("/settings/stricttemplates", api.handleSaveStrictTemplates)
+		write.Post("/settings/draftidle", api.handleSaveDraftIdle)
+		write.Post("/settings/useragent", api.handleSaveUserAgent)
+		r.Get("/settings/ratelimit", api.rateLimit)
+		write.Post("/settings/ratelimit", api.rateLimit)
+
+		// Maintenance
+		write.Post("/admin/repair", api.repairWorkspace)
 	})
 
 	// Serve frontend static files
-	if _, err := os.Stat("frontend/dist"); os.IsNotExist(err) {
-		log.Printf("⚠️  Warning: frontend/dist directory not found")
-		log.Printf("💡 Run 'cd frontend && npm run build' to build the frontend")
+	if _, err := os.Stat(frontendDir); os.IsNotExist(err) {
+		slog.Warn("frontend/dist directory not found")
+		slog.Info("run 'cd frontend && npm run build' to build the frontend")
 	}
-	r.Handle("/*", http.FileServer(http.Dir("frontend/dist/")))
+	r.Handle("/*", http.FileServer(http.Dir(frontendDir)))
 
 	// Start server
-	port := "8333"
-	if p := os.Getenv("PORT"); p != "" {
-		port = p
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", listenErrorMessage(err, host, port))
+		os.Exit(1)
+	}
+	// Port 0 asks the OS for a free port; report the one it picked
+	port = strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+
+	displayHost := host
+	if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+		displayHost = "localhost"
+		slog.Warn("listening on all interfaces; other machines on the network can reach this server", "host", host)
 	}
 
-	fmt.Printf("🚀 Postman-like API tester starting on http://localhost:%s\n", port)
+	scheme := "http"
+	if certFile != "" {
+		scheme = "https"
+	}
+	fmt.Printf("🚀 Postman-like API tester starting on %s://%s\n", scheme, net.JoinHostPort(displayHost, port))
 	fmt.Println("📁 Serving Svelte frontend from frontend/dist/")
 	fmt.Println("🔗 API proxy available at /api/proxy")
 	fmt.Println("⏹️  Press Ctrl+C to stop the server")
 	fmt.Println("=" + strings.Repeat("=", 50))
 
-	log.Printf("Server listening on port %s", port)
-
-	if err := http.ListenAndServe(":"+port, r); err != nil {
-		log.Printf("❌ Server failed to start: %v", err)
-		fmt.Println("\nPress Enter to exit...")
-		fmt.Scanln()
-		os.Exit(1)
+	slog.Info("server listening", "url", fmt.Sprintf("%s://%s", scheme, listener.Addr()))
+	if readOnly {
+		slog.Info("read-only mode: saved data can't be changed")
+	}
+	if !proxyTargetPolicy.Empty() {
+		slog.Info("proxy target policy", "allow", len(proxyTargetPolicy.AllowNets)+len(proxyTargetPolicy.AllowHosts),
+			"deny", len(proxyTargetPolicy.DenyNets)+len(proxyTargetPolicy.DenyHosts))
+	}
+	if accessConfig.Enabled() {
+		slog.Info("access control enabled; sign in or send an Authorization: Bearer token", "login", loginPath)
+	} else if displayHost != host {
+		slog.Warn("access control is off; anyone who can reach this server can read saved credentials (see -auth-token)")
+	}
+	if scheme == "http" && displayHost != host {
+		slog.Warn("saved credentials travel unencrypted to remote clients; consider -tls-auto or -tls-cert/-tls-key")
 	}
-}
 
-// =============================================================================
-// MIDDLEWARE
-// =============================================================================
+	// Background work (schedulers, runners) should stop when ctx is cancelled
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	watchDataFiles(ctx)
 
-// corsMiddleware handles CORS headers for frontend communication
-func corsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	server := &http.Server{Handler: r}
+	servers := []*http.Server{server}
+	serverErr := make(chan error, 2)
+	go func() {
+		if certFile != "" {
+			serverErr <- server.ServeTLS(listener, certFile, keyFile)
+		} else {
+			serverErr <- server.Serve(listener)
+		}
+	}()
 
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
+	if redirectPort != "" {
+		redirectListener, err := net.Listen("tcp", net.JoinHostPort(host, redirectPort))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s\n", listenErrorMessage(err, host, redirectPort))
+			os.Exit(1)
 		}
+		redirectServer := &http.Server{Handler: httpsRedirectHandler(port)}
+		servers = append(servers, redirectServer)
+		go func() {
+			serverErr <- redirectServer.Serve(redirectListener)
+		}()
+		slog.Info("redirecting to https", "from", "http://"+redirectListener.Addr().String(), "to", "https://"+net.JoinHostPort(displayHost, port))
+	}
 
-		next.ServeHTTP(w, r)
-	})
-}
+	select {
+	case err := <-serverErr:
+		slog.Error("server stopped unexpectedly", "error", err)
+		os.Exit(1)
+	case <-ctx.Done():
+	}
 
-// loggingMiddleware logs HTTP requests with timing
-func loggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		wrapped := &responseWrapper{ResponseWriter: w, statusCode: 200}
+	// A second Ctrl+C exits immediately
+	stop()
+	shutdown(servers...)
+}
 
-		next.ServeHTTP(wrapped, r)
+// Only this machine can connect by default; pass -addr 0.0.0.0 to listen on every interface
+const (
+	defaultListenHost = "127.0.0.1"
+	defaultListenPort = "8333"
+)
 
-		log.Printf("📥 %s %s - %d - %v", r.Method, r.URL.Path, wrapped.statusCode, time.Since(start))
-	})
+// envOrDefault returns the environment variable's value, or fallback when it is unset or empty
+func envOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
 }
 
-type responseWrapper struct {
-	http.ResponseWriter
-	statusCode int
+// validateListenAddress checks the -addr and -port values, returning them trimmed
+func validateListenAddress(host, port string) (string, string, error) {
+	host = strings.Trim(strings.TrimSpace(host), "[]")
+	if host == "" {
+		return "", "", fmt.Errorf("invalid -addr: it must not be empty (use 0.0.0.0 to listen on all interfaces)")
+	}
+	if strings.ContainsAny(host, "/ \t") || (strings.Contains(host, ":") && net.ParseIP(host) == nil) {
+		return "", "", fmt.Errorf("invalid -addr %q: expected a host name or IP address such as 127.0.0.1, without a port or scheme", host)
+	}
+	port = strings.TrimSpace(port)
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 0 || n > 65535 {
+		return "", "", fmt.Errorf("invalid -port %q: expected a number from 0 to 65535", port)
+	}
+	return host, strconv.Itoa(n), nil
 }
 
-func (rw *responseWrapper) WriteHeader(code int) {
-	rw.statusCode = code
-	rw.ResponseWriter.WriteHeader(code)
+// listenErrorMessage explains why the server couldn't listen, with a hint on how to fix it
+func listenErrorMessage(err error, host, port string) string {
+	address := net.JoinHostPort(host, port)
+	switch {
+	case errors.Is(err, syscall.EADDRINUSE):
+		return fmt.Sprintf("Port %s is already in use on %s. Stop the other process or choose another port with -port (or PORT); -port 0 picks a free one.", port, host)
+	case errors.Is(err, syscall.EACCES):
+		return fmt.Sprintf("Permission denied listening on %s. Ports below 1024 usually need elevated privileges; try -port 8333.", address)
+	case errors.Is(err, syscall.EADDRNOTAVAIL):
+		return fmt.Sprintf("Address %s is not available on this machine. Check -addr (or ADDR).", host)
+	default:
+		return fmt.Sprintf("Could not listen on %s: %v", address, err)
+	}
 }
 
-// =============================================================================
-// CORE HANDLERS
-// =============================================================================
+// selfSignedCertFile and selfSignedKeyFile cache the certificate generated by -tls-auto
+const (
+	selfSignedCertFile = "tls/selfsigned-cert.pem"
+	selfSignedKeyFile  = "tls/selfsigned-key.pem"
+)
 
-// health provides a simple health check endpoint
-func health(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"status":  "healthy",
-		"service": "postman-like-api-tester",
-	})
-}
+// selfSignedValidity is how long a generated certificate is valid; it is regenerated
+// once it is within a week of expiring
+const selfSignedValidity = 365 * 24 * time.Hour
 
-// buildJSON builds JSON from typed body fields for preview purposes
-func buildJSON(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// resolveTLSFiles returns the certificate and key to serve HTTPS with, or two empty
+// strings for plain HTTP. The pair is loaded once here so a bad file fails at startup.
+func resolveTLSFiles(certFile, keyFile string, auto bool, host string) (string, string, error) {
+	if auto && (certFile != "" || keyFile != "") {
+		return "", "", fmt.Errorf("use either -tls-auto or -tls-cert/-tls-key, not both")
+	}
+	if (certFile == "") != (keyFile == "") {
+		return "", "", fmt.Errorf("-tls-cert and -tls-key must be given together")
+	}
+	if auto {
+		if err := ensureSelfSignedCert(host); err != nil {
+			return "", "", fmt.Errorf("failed to create a self-signed certificate: %v", err)
+		}
+		certFile, keyFile = selfSignedCertFile, selfSignedKeyFile
+	}
+	if certFile == "" {
+		return "", "", nil
 	}
+	if _, err := tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+		return "", "", fmt.Errorf("failed to load TLS certificate %s and key %s: %v", certFile, keyFile, err)
+	}
+	return certFile, keyFile, nil
+}
 
-	var req struct {
-		BodyJson []BodyField `json:"bodyJson"`
+// ensureSelfSignedCert creates the -tls-auto certificate unless a cached one is still
+// valid for the listen host
+//
+// The certificate covers localhost, the loopback addresses, this machine's host name and
+// the -addr host. Browsers will warn about it until it is trusted; the SHA-256
+// fingerprint is logged so it can be checked before accepting it.
+func ensureSelfSignedCert(host string) error {
+	if cached, err := tls.LoadX509KeyPair(selfSignedCertFile, selfSignedKeyFile); err == nil {
+		leaf, err := x509.ParseCertificate(cached.Certificate[0])
+		fresh := err == nil && time.Until(leaf.NotAfter) > 7*24*time.Hour
+		if ip := net.ParseIP(host); fresh && (ip == nil || !ip.IsUnspecified()) {
+			fresh = leaf.VerifyHostname(host) == nil
+		}
+		if fresh {
+			slog.Info("using self-signed certificate", "path", selfSignedCertFile, "sha256", certFingerprint(leaf.Raw))
+			return nil
+		}
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("❌ Invalid request body for buildJSON: %v", err)
-		respondWithError(w, "Invalid request body", http.StatusBadRequest)
-		return
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
 	}
 
-	// Build JSON from typed fields
-	jsonObj, err := buildJSONFromBodyFields(req.BodyJson)
+	names := []string{"localhost"}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" && hostname != "localhost" {
+		names = append(names, hostname)
+	}
+	ips := []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+	if ip := net.ParseIP(host); ip != nil {
+		if !ip.IsUnspecified() && !ip.IsLoopback() {
+			ips = append(ips, ip)
+		}
+	} else if host != "localhost" {
+		names = append(names, host)
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"go-rest"}, CommonName: "go-rest self-signed"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              names,
+		IPAddresses:           ips,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
 	if err != nil {
-		log.Printf("❌ Failed to build JSON from body fields: %v", err)
-		respondWithError(w, fmt.Sprintf("Failed to build JSON: %v", err), http.StatusBadRequest)
-		return
+		return err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
 	}
 
-	// Return the built JSON structure
-	w.Header().Set("Content-Type", "application/json")
-	response := map[string]any{
-		"json": jsonObj,
-		"jsonString": func() string {
-			if jsonBytes, err := json.MarshalIndent(jsonObj, "", "  "); err == nil {
-				return string(jsonBytes)
-			}
-			return ""
-		}(),
+	if err := os.MkdirAll(filepath.Dir(selfSignedCertFile), 0700); err != nil {
+		return err
 	}
-
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("❌ Failed to encode buildJSON response: %v", err)
+	if err := os.WriteFile(selfSignedKeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return err
+	}
+	if err := os.WriteFile(selfSignedCertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return err
 	}
+	slog.Info("generated self-signed certificate", "path", selfSignedCertFile,
+		"names", strings.Join(names, ", "), "sha256", certFingerprint(der))
+	return nil
 }
 
-// buildForm builds x-www-form-urlencoded from form fields for preview purposes
-func buildForm(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// certFingerprint formats a certificate's SHA-256 fingerprint as colon-separated hex
+func certFingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
 	}
+	return strings.Join(parts, ":")
+}
 
-	var req struct {
-		BodyForm []BodyField `json:"bodyForm"`
-	}
+// httpsRedirectHandler sends plain HTTP requests to the same host and path over HTTPS
+//
+// A temporary redirect is used so browsers don't remember it if HTTPS is turned off later.
+func httpsRedirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		target := "https://" + net.JoinHostPort(strings.Trim(host, "[]"), httpsPort) + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusTemporaryRedirect)
+	})
+}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("❌ Invalid request body for buildForm: %v", err)
-		respondWithError(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
+// shutdownTimeout is how long in-flight requests get to finish; it is longer than
+// proxyTimeout so a proxied call that was already sent can complete
+const shutdownTimeout = proxyTimeout + 5*time.Second
 
-	encoded := buildFormEncoded(req.BodyForm)
+// backgroundCtx is the parent of work that outlives the request that started it, such
+// as asynchronous executions; shutdown cancels it once the shutdown timeout runs out
+var backgroundCtx, cancelBackground = context.WithCancel(context.Background())
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string]string{"formString": encoded}); err != nil {
-		log.Printf("❌ Failed to encode buildForm response: %v", err)
-	}
-}
+// backgroundWork counts goroutines started with goBackground, which shutdown waits for
+var backgroundWork sync.WaitGroup
 
-// proxy handles requests to external APIs with template processing
-//
-// This is the core functionality that:
-// 1. Accepts a ProxyRequest with URL, method, headers, body, and variables
-// 2. Applies template substitution using environment variables and response references
-// 3. Makes the HTTP request to the target API
-// 4. Returns the response with parsed JSON body when possible
-//
-// Template processing supports:
-// - Environment variables: {{varName}} -> resolved from current environment
-// - Response variables: {{"RequestName".field}} -> extracts field from saved response
-// - System environment variables: values starting with $ are resolved from OS env
-func proxy(w http.ResponseWriter, r *http.Request) {
-	defer func() {
-		if r := recover(); r != nil {
-			log.Printf("⚠️  Panic in handleProxy: %v", r)
-			respondWithError(w, "Internal server error", http.StatusInternalServerError)
-		}
+// goBackground runs fn in a goroutine that shutdown waits for. Goroutines must be
+// started from handlers or before the server starts, never once shutdown is waiting.
+func goBackground(fn func()) {
+	backgroundWork.Add(1)
+	go func() {
+		defer backgroundWork.Done()
+		fn()
 	}()
+}
 
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// shutdown stops accepting connections, waits for active handlers and background work
+// such as asynchronous executions to finish, and then waits for any data file or audit
+// log write still in progress. Background work still running when the timeout ends is
+// cancelled.
+func shutdown(servers ...*http.Server) {
+	slog.Info("shutting down, waiting for active requests", "timeout", shutdownTimeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, server := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := server.Shutdown(ctx); err != nil {
+				slog.Warn("requests still running, closing connections", "timeout", shutdownTimeout, "error", err)
+				server.Close()
+			}
+		}()
 	}
+	wg.Wait()
 
-	var req ProxyRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("❌ Invalid request body: %v", err)
-		respondWithError(w, "Invalid request body", http.StatusBadRequest)
-		return
+	// Handlers have returned, so nothing starts more background work
+	background := make(chan struct{})
+	go func() {
+		backgroundWork.Wait()
+		close(background)
+	}()
+	select {
+	case <-background:
+	case <-ctx.Done():
+		slog.Warn("background work still running, cancelling it", "timeout", shutdownTimeout)
+		cancelBackground()
+		<-background
 	}
+	cancelBackground()
 
-	// Validate required fields
-	if req.URL == "" {
-		respondWithError(w, "URL is required", http.StatusBadRequest)
-		return
-	}
+	// Saves hold these locks for the whole write, so taking them waits out a write in progress
+	fileAccessMutex.Lock()
+	auditMutex.Lock()
+	historyMutex.Lock()
+	workspaceMutex.Lock()
 
-	if req.Method == "" {
-		req.Method = "GET"
-	}
+	slog.Info("shutdown complete; it is safe to close this window")
+}
 
-	// Get variables from current environment for template processing
-	data, err := loadRequests()
-	if err != nil {
-		log.Printf("❌ Failed to load environment data: %v", err)
-		respondWithError(w, "Failed to load environment data", http.StatusInternalServerError)
-		return
-	}
+// =============================================================================
+// LOGGING
+// =============================================================================
 
-	currentEnv, err := getCurrentEnvironment(data)
-	if err != nil {
-		log.Printf("❌ Failed to get current environment: %v", err)
-		respondWithError(w, "Failed to get current environment", http.StatusInternalServerError)
-		return
-	}
+// logLevel is the minimum level written to the log, set by -log-level
+var logLevel = new(slog.LevelVar)
 
-	// Use environment variables instead of request variables for template processing
-	req.Variables = currentEnv.Variables
+// setupLogging installs the slog handler chosen by -log-format and -log-level and
+// sends the standard log package's output through it
+func setupLogging(format, level string) error {
+	if err := logLevel.UnmarshalText([]byte(strings.TrimSpace(level))); err != nil {
+		return fmt.Errorf("-log-level must be debug, info, warn or error, got %q", level)
+	}
 
-	// Apply template processing to substitute variables
-	processedReq := processTemplates(req)
-	log.Printf("🔄 Original URL: %s", req.URL)
-	if processedReq.URL != req.URL {
-		log.Printf("✨ Processed URL: %s", processedReq.URL)
+	options := &slog.HandlerOptions{Level: logLevel}
+	var handler slog.Handler
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, options)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, options)
+	default:
+		return fmt.Errorf("-log-format must be text or json, got %q", format)
 	}
 
-	// Debug headers and template processing
-	if len(req.Headers) > 0 {
-		log.Printf("📋 Headers: %+v", req.Headers)
-		if len(req.Variables) > 0 {
-			log.Printf("📋 After template processing: %+v", processedReq.Headers)
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// credentialHeaderWords mark header names whose values are never logged, even at debug
+var credentialHeaderWords = []string{"authorization", "cookie", "token", "secret", "password", "api-key", "apikey"}
+
+// redactHeaders returns headers safe to log: credential headers are masked entirely and
+// secret variable values are masked everywhere else
+func redactHeaders(headers map[string]string, variables []Variable) map[string]string {
+	redacted := make(map[string]string, len(headers))
+	for name, value := range headers {
+		lower := strings.ToLower(name)
+		for _, word := range credentialHeaderWords {
+			if strings.Contains(lower, word) {
+				value = secretMask
+				break
+			}
 		}
+		redacted[name] = redactSecrets(value, variables)
 	}
+	return redacted
+}
 
-	// Make the HTTP request
-	response := makeHTTPRequest(processedReq)
-
-	// Return the response to the UI (frontend)
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("❌ Failed to encode response: %v", err)
+// targetHost returns the host of a proxied URL for log attributes
+func targetHost(rawURL string) string {
+	if parsed, err := url.Parse(rawURL); err == nil && parsed.Host != "" {
+		return parsed.Host
 	}
+	return ""
 }
 
-// makeHTTPRequest performs the actual HTTP request to the target API
-func makeHTTPRequest(req ProxyRequest) ProxyResponse {
-	defer func() {
-		if r := recover(); r != nil {
-			log.Printf("⚠️  Panic in makeHTTPRequest: %v", r)
-		}
-	}()
+// =============================================================================
+// MIDDLEWARE
+// =============================================================================
 
-	var bodyReader io.Reader
-	var bodyStr string
+// corsMiddleware handles CORS headers for frontend communication
+func corsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, "+sessionEnvironmentHeader+", "+executionIDHeader)
+		w.Header().Set("Access-Control-Expose-Headers", executionIDHeader)
 
-	// Build body based on type
-	if req.BodyType == "json" && len(req.BodyJson) > 0 {
-		// Build JSON from typed fields
-		jsonObj, err := buildJSONFromBodyFields(req.BodyJson)
-		if err != nil {
-			log.Printf("❌ Failed to build JSON from body fields: %v", err)
-			return ProxyResponse{
-				Error: fmt.Sprintf("Failed to build JSON body: %v", err),
-			}
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
 		}
-		jsonBytes, err := json.Marshal(jsonObj)
-		if err != nil {
-			log.Printf("❌ Failed to marshal JSON body: %v", err)
-			return ProxyResponse{
-				Error: fmt.Sprintf("Failed to marshal JSON body: %v", err),
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Per-session environment selection: a header wins over the cookie
+const (
+	sessionEnvironmentHeader = "X-Environment-Id"
+	sessionEnvironmentCookie = "gorest_env"
+)
+
+// contextKey namespaces values stored in a request context
+type contextKey string
+
+const sessionEnvironmentKey contextKey = "sessionEnvironment"
+
+// sessionEnvironmentMiddleware stores the client's selected environment ID, if any, in
+// the request context so handlers can honor it instead of the global current environment
+func sessionEnvironmentMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		envID := strings.TrimSpace(r.Header.Get(sessionEnvironmentHeader))
+		if envID == "" {
+			if cookie, err := r.Cookie(sessionEnvironmentCookie); err == nil {
+				envID = cookie.Value
 			}
 		}
-		bodyStr = string(jsonBytes)
-		log.Printf("🔧 Built JSON body from %d typed fields: %s", len(req.BodyJson), bodyStr)
-		// Ensure Content-Type if not set
-		if _, ok := req.Headers["Content-Type"]; !ok {
-			req.Headers["Content-Type"] = "application/json"
-		}
-	} else if req.BodyType == "form" && len(req.BodyForm) > 0 {
-		bodyStr = buildFormEncoded(req.BodyForm)
-		log.Printf("🔧 Built form body from %d fields: %s", len(req.BodyForm), bodyStr)
-		// Ensure Content-Type if not set
-		if _, ok := req.Headers["Content-Type"]; !ok {
-			req.Headers["Content-Type"] = "application/x-www-form-urlencoded"
+		if envID != "" {
+			r = r.WithContext(context.WithValue(r.Context(), sessionEnvironmentKey, envID))
 		}
-	}
+		next.ServeHTTP(w, r)
+	})
+}
 
-	if bodyStr != "" {
-		bodyReader = strings.NewReader(bodyStr)
-	}
+// readOnly is set by -read-only / READ_ONLY for shared or demo deployments
+var readOnly bool
 
-	// Create HTTP request
-	httpReq, err := http.NewRequest(req.Method, req.URL, bodyReader)
-	if err != nil {
-		log.Printf("❌ Failed to create request: %v", err)
-		return ProxyResponse{
-			Error: fmt.Sprintf("Failed to create request: %v", err),
-		}
-	}
+// errReadOnly is returned by saves on a read-only server
+var errReadOnly = errors.New("this server is read-only; changes are disabled")
 
-	// Add headers
-	for key, value := range req.Headers {
-		httpReq.Header.Set(key, value)
-	}
-	if len(req.Headers) > 0 {
-		log.Printf("📋 Set %d headers on HTTP request", len(req.Headers))
-	}
+// readOnlyResponses holds the responses of runRequest steps on a read-only server, keyed
+// by request ID. They can't be saved, so response variables read them from here instead.
+var readOnlyResponses sync.Map
 
-	// Make the request with timeout
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+// withReadOnlyResponse replaces a request's saved response with the one it got on this
+// read-only server, if it has run since the server started
+func withReadOnlyResponse(req *SavedRequest) {
+	if resp, ok := readOnlyResponses.Load(req.ID); ok {
+		req.LastResponse = resp.(*ProxyResponse)
 	}
+}
 
-	log.Printf("🔄 Making request to: %s %s", req.Method, req.URL)
-	resp, err := client.Do(httpReq)
-	if err != nil {
-		log.Printf("❌ Request failed: %v", err)
-		return ProxyResponse{
-			Error: fmt.Sprintf("Request failed: %v", err),
+// readOnlyMiddleware refuses requests to routes that change stored data when the
+// server is read-only. It is attached to the write routes only, so reads and sending
+// requests keep working.
+func readOnlyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if readOnly {
+			slog.Info("refused in read-only mode", "method", r.Method, "path", r.URL.Path)
+			respondWithError(w, "This server is read-only; changes are disabled", http.StatusForbidden)
+			return
 		}
-	}
-	defer resp.Body.Close()
+		next.ServeHTTP(w, r)
+	})
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("❌ Failed to read response body: %v", err)
-		return ProxyResponse{
-			Status:     resp.Status,
-			StatusCode: resp.StatusCode,
-			Error:      fmt.Sprintf("Failed to read response body: %v", err),
-		}
-	}
+// loggingMiddleware logs HTTP requests with timing
+func loggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		wrapped := &responseWrapper{ResponseWriter: w, statusCode: 200}
 
-	// Convert response headers to map
-	headers := make(map[string]string)
-	for key, values := range resp.Header {
-		if len(values) > 0 {
-			headers[key] = values[0] // Take first value if multiple
-		}
-	}
+		next.ServeHTTP(wrapped, r)
+
+		slog.Info("http request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", wrapped.statusCode,
+			"duration", time.Since(start),
+			"bytes", wrapped.bytes)
+	})
+}
 
-	log.Printf("✅ Request completed: %d %s (%d bytes)", resp.StatusCode, resp.Status, len(body))
+type responseWrapper struct {
+	http.ResponseWriter
+	statusCode int
+	bytes      int
+}
 
-	// Parse response body as JSON if possible
-	responseBody := parseJSON(string(body))
+func (rw *responseWrapper) WriteHeader(code int) {
+	rw.statusCode = code
+	rw.ResponseWriter.WriteHeader(code)
+}
 
-	return ProxyResponse{
-		Status:     resp.Status,
-		StatusCode: resp.StatusCode,
-		Headers:    headers,
-		Body:       responseBody,
-	}
+func (rw *responseWrapper) Write(b []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += n
+	return n, err
 }
 
 // =============================================================================
-// DATA PERSISTENCE
+// ACCESS CONTROL
 // =============================================================================
 
-const requestsFileName = "saved_requests.json"
+// Access control is off unless a token or a username and password are configured
+const (
+	authSessionCookie = "gorest_session"
+	authSessionTTL    = 12 * time.Hour
+	loginPath         = "/login"
+	logoutPath        = "/logout"
+)
 
-// Mutex to prevent concurrent file access
-var fileAccessMutex sync.RWMutex
+// AccessConfig holds the credentials that unlock the API and UI
+type AccessConfig struct {
+	Token    string
+	Username string
+	Password string
+}
 
-// uniqueName creates a unique name by appending a counter if needed
-func uniqueName(baseName string, requests []SavedRequest) string {
-	candidateName := baseName
-	counter := 1
+// Enabled reports whether any credential is configured
+func (c AccessConfig) Enabled() bool {
+	return c.Token != "" || c.Password != ""
+}
 
-	for {
-		isUnique := true
-		for _, req := range requests {
-			if req.Name == candidateName {
-				isUnique = false
-				break
-			}
-		}
+var (
+	accessConfig AccessConfig
 
-		if isUnique {
-			return candidateName
-		}
+	// Login sessions live in memory, so a restart signs everyone out
+	authSessions      = make(map[string]time.Time)
+	authSessionsMutex sync.Mutex
+)
 
-		counter++
-		candidateName = baseName + " (" + strconv.Itoa(counter) + ")"
+// validateAccessConfig checks that a username and password are configured together
+func validateAccessConfig(c AccessConfig) error {
+	if (c.Username == "") != (c.Password == "") {
+		return fmt.Errorf("-auth-user and -auth-password must be set together")
 	}
+	return nil
 }
 
-// =============================================================================
-// TEMPLATE PROCESSING & VARIABLE SUBSTITUTION
-// =============================================================================
+// secretsEqual compares two secrets in constant time. Both are hashed first so the
+// comparison doesn't leak the configured secret's length either.
+func secretsEqual(given, want string) bool {
+	a := sha256.Sum256([]byte(given))
+	b := sha256.Sum256([]byte(want))
+	return subtle.ConstantTimeCompare(a[:], b[:]) == 1
+}
 
-// RespVarRef represents a parsed response variable reference like {{"RequestName".field}}
-type RespVarRef struct {
-	RequestName string
-	FieldPath   string
-	IsResponse  bool // true if referencing full response, false if specific field
+// checkLogin reports whether a login form's credentials match the configuration:
+// either the token, or the username and password
+func checkLogin(username, password, token string) bool {
+	if accessConfig.Token != "" && token != "" && secretsEqual(token, accessConfig.Token) {
+		return true
+	}
+	if accessConfig.Password == "" || password == "" {
+		return false
+	}
+	// Evaluate both so a wrong username takes as long as a wrong password
+	userOK := secretsEqual(username, accessConfig.Username)
+	passwordOK := secretsEqual(password, accessConfig.Password)
+	return userOK && passwordOK
 }
 
-// parseVariable parses response variable syntax like {{"RequestName".field}} or {{\"RequestName\".field}}
-func parseVariable(variable string) (*RespVarRef, error) {
-	// Remove outer {{ and }}
-	if !strings.HasPrefix(variable, "{{") || !strings.HasSuffix(variable, "}}") {
-		return nil, fmt.Errorf("invalid variable format")
+// newAuthSession starts a login session and returns its ID
+func newAuthSession() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	id := hex.EncodeToString(b)
+
+	authSessionsMutex.Lock()
+	defer authSessionsMutex.Unlock()
+	now := time.Now()
+	for sid, expires := range authSessions {
+		if now.After(expires) {
+			delete(authSessions, sid)
+		}
 	}
+	authSessions[id] = now.Add(authSessionTTL)
+	return id
+}
 
-	content := strings.TrimSpace(variable[2 : len(variable)-2])
-	log.Printf("Parsing response variable content: %q", content)
+// validAuthSession reports whether a session ID belongs to an unexpired login
+func validAuthSession(id string) bool {
+	authSessionsMutex.Lock()
+	defer authSessionsMutex.Unlock()
+	expires, ok := authSessions[id]
+	if ok && time.Now().After(expires) {
+		delete(authSessions, id)
+		return false
+	}
+	return ok
+}
 
-	// Handle escaped quotes: {{\"RequestName\".field}} or {{"RequestName".field}}
-	var startQuote string
-	if strings.HasPrefix(content, "\\\"") {
-		startQuote = "\\\""
-	} else if strings.HasPrefix(content, "\"") {
-		startQuote = "\""
-	} else {
-		return nil, fmt.Errorf("not a response variable - doesn't start with quote")
+// isAuthenticated reports whether a request carries the bearer token or a valid session cookie
+func isAuthenticated(r *http.Request) bool {
+	if accessConfig.Token != "" {
+		if scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok &&
+			strings.EqualFold(scheme, "Bearer") && secretsEqual(strings.TrimSpace(token), accessConfig.Token) {
+			return true
+		}
 	}
+	if cookie, err := r.Cookie(authSessionCookie); err == nil && validAuthSession(cookie.Value) {
+		return true
+	}
+	return false
+}
 
-	// Extract request name and field path
-	var requestName, fieldPath string
-
-	if startQuote == "\\\"" {
-		// Handle escaped quotes: {{\"RequestName\".field}}
-		// Find the closing \"
-		endIndex := strings.Index(content[2:], "\\\".") // Skip the opening \"
-		if endIndex == -1 {
-			return nil, fmt.Errorf("unclosed escaped quote or missing field separator")
+// authMiddleware requires a bearer token or login session on every route except the
+// health check, the login page and webhook deliveries. API calls get 401; browsers
+// asking for the UI are sent to the login page.
+func authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !accessConfig.Enabled() || r.URL.Path == "/api/health" || r.URL.Path == loginPath ||
+			isHookDelivery(r.URL.Path) || isAuthenticated(r) {
+			next.ServeHTTP(w, r)
+			return
 		}
-		requestName = content[2 : endIndex+2] // Extract name between \"...\"
-		remaining := content[endIndex+4:]     // Skip past \"."
-		fieldPath = remaining
-	} else {
-		// Handle regular quotes: {{"RequestName".field}}
-		// Find the closing quote
-		endIndex := strings.Index(content[1:], "\".") // Skip the opening "
-		if endIndex == -1 {
-			return nil, fmt.Errorf("unclosed quote or missing field separator")
+
+		if strings.HasPrefix(r.URL.Path, "/api/") || r.URL.Path == "/api" || r.Method != http.MethodGet {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="go-rest"`)
+			respondWithError(w, "Authentication required", http.StatusUnauthorized)
+			return
 		}
-		requestName = content[1 : endIndex+1] // Extract name between "..."
-		remaining := content[endIndex+3:]     // Skip past "."
-		fieldPath = remaining
-	}
+		http.Redirect(w, r, loginPath, http.StatusSeeOther)
+	})
+}
 
-	log.Printf("Extracted - request: %q, field: %q", requestName, fieldPath)
+// loginPage is the sign-in form served at /login; %s is replaced by the error message
+// and then the credential fields
+const loginPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Sign in - API Tester</title>
+<style>
+body { font-family: system-ui, sans-serif; display: flex; justify-content: center; padding-top: 10vh; background: #f5f5f5; }
+form { background: #fff; padding: 2rem; border-radius: 8px; box-shadow: 0 1px 4px rgba(0,0,0,.15); width: 18rem; }
+label { display: block; margin-top: 1rem; font-size: .9rem; }
+input { width: 100%%; box-sizing: border-box; padding: .5rem; margin-top: .25rem; }
+button { margin-top: 1.5rem; width: 100%%; padding: .6rem; }
+.error { color: #b91c1c; font-size: .9rem; }
+</style>
+</head>
+<body>
+<form method="post" action="/login">
+<h2>Sign in</h2>
+%s%s<button type="submit">Sign in</button>
+</form>
+</body>
+</html>
+`
+
+// writeLoginPage renders the sign-in form with the fields the configuration accepts
+func writeLoginPage(w http.ResponseWriter, message string, statusCode int) {
+	var fields strings.Builder
+	if accessConfig.Password != "" {
+		fields.WriteString(`<label>Username <input name="username" autocomplete="username" autofocus></label>
+<label>Password <input name="password" type="password" autocomplete="current-password"></label>
+`)
+	}
+	if accessConfig.Token != "" {
+		fields.WriteString(`<label>Access token <input name="token" type="password" autocomplete="off"></label>
+`)
+	}
+	errorHTML := ""
+	if message != "" {
+		errorHTML = `<p class="error">` + message + "</p>\n"
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+	fmt.Fprintf(w, loginPage, errorHTML, fields.String())
+}
 
-	if requestName == "" {
-		return nil, fmt.Errorf("empty request name")
+// login serves the sign-in form on GET and checks submitted credentials on POST,
+// setting the session cookie and redirecting to the UI on success
+func (api *API) login(w http.ResponseWriter, r *http.Request) {
+	if !accessConfig.Enabled() {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
 	}
-	if fieldPath == "" {
-		return nil, fmt.Errorf("empty field path")
+
+	switch r.Method {
+	case http.MethodGet:
+		writeLoginPage(w, "", http.StatusOK)
+		return
+	case http.MethodPost:
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
-	return &RespVarRef{
-		RequestName: requestName,
-		FieldPath:   fieldPath,
-		IsResponse:  fieldPath == "response",
-	}, nil
-}
+	r.Body = http.MaxBytesReader(w, r.Body, 64*1024)
+	if err := r.ParseForm(); err != nil {
+		writeLoginPage(w, "Invalid form submission", http.StatusBadRequest)
+		return
+	}
+	if !checkLogin(r.PostFormValue("username"), r.PostFormValue("password"), r.PostFormValue("token")) {
+		slog.Warn("failed sign-in", "client", clientIP(r))
+		writeLoginPage(w, "Incorrect credentials", http.StatusUnauthorized)
+		return
+	}
 
-// JSONFieldResult represents the result of extracting a JSON field
-type JSONFieldResult struct {
-	Value    string
-	IsObject bool // true if the extracted value is a JSON object/array
+	http.SetCookie(w, &http.Cookie{
+		Name:     authSessionCookie,
+		Value:    newAuthSession(),
+		Path:     "/",
+		MaxAge:   int(authSessionTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	slog.Info("signed in", "client", clientIP(r))
+	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
-// extractJSONField extracts a field from JSON data using dot notation (e.g., "user.profile.email")
-func extractJSONField(data any, fieldPath string) (*JSONFieldResult, error) {
-	if data == nil {
-		return &JSONFieldResult{Value: "", IsObject: false}, nil
+// logout handles POST requests to end the current login session
+func (api *API) logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
-	// If requesting full response, convert to string
-	if fieldPath == "response" {
-		if str, ok := data.(string); ok {
-			return &JSONFieldResult{Value: str, IsObject: false}, nil
-		}
-		// Convert JSON to string
-		jsonBytes, err := json.Marshal(data)
-		if err != nil {
-			return nil, err
-		}
-		return &JSONFieldResult{Value: string(jsonBytes), IsObject: true}, nil
+	if cookie, err := r.Cookie(authSessionCookie); err == nil {
+		authSessionsMutex.Lock()
+		delete(authSessions, cookie.Value)
+		authSessionsMutex.Unlock()
 	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     authSessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, r, loginPath, http.StatusSeeOther)
+}
 
-	// For other fields, navigate the JSON structure
-	current := data
-	parts := strings.Split(fieldPath, ".")
-
-	for _, part := range parts {
-		if part == "" {
-			continue
-		}
+// =============================================================================
+// CORE HANDLERS
+// =============================================================================
 
-		switch v := current.(type) {
-		case map[string]any:
-			if val, exists := v[part]; exists {
-				current = val
-			} else {
-				return &JSONFieldResult{Value: "", IsObject: false}, nil // Field doesn't exist, return empty string
-			}
-		default:
-			return &JSONFieldResult{Value: "", IsObject: false}, nil // Can't traverse further, return empty string
-		}
+// buildCommit returns the commit set by ldflags, else the one Go stamped into the binary
+func buildCommit() string {
+	if commit != "" {
+		return commit
 	}
-
-	// Convert final value to string and determine if it's a JSON object
-	switch v := current.(type) {
-	case string:
-		return &JSONFieldResult{Value: v, IsObject: false}, nil
-	case nil:
-		return &JSONFieldResult{Value: "", IsObject: false}, nil
-	case map[string]any, []any:
-		// This is a JSON object or array
-		jsonBytes, err := json.Marshal(v)
-		if err != nil {
-			return nil, err
-		}
-		return &JSONFieldResult{Value: string(jsonBytes), IsObject: true}, nil
-	default:
-		// Convert to JSON string for non-string primitive types (numbers, booleans, etc.)
-		jsonBytes, err := json.Marshal(v)
-		if err != nil {
-			return nil, err
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	revision, modified := "", false
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
 		}
-		return &JSONFieldResult{Value: string(jsonBytes), IsObject: false}, nil
 	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if revision != "" && modified {
+		revision += "-dirty"
+	}
+	return revision
 }
 
-// loadRequest loads a saved request by name from the saved requests file
-func loadRequest(requestName string) (*SavedRequest, error) {
-	data, err := loadRequests()
+// checkDataFile reads and strictly parses the data file, unlike fileStore.Load which
+// falls back to empty data when the file is corrupt
+func checkDataFile(path string) error {
+	contents, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil // Created on the first save
+	}
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("not readable: %v", err)
 	}
-
-	for _, request := range data.Requests {
-		if request.Name == requestName {
-			return &request, nil
-		}
+	if len(contents) == 0 {
+		return nil
 	}
-
-	return nil, fmt.Errorf("request not found: %s", requestName)
-}
-
-// resolveEnvVar resolves environment variable references (values starting with $)
-func resolveEnvVar(value string) string {
-	if strings.HasPrefix(value, "$") {
-		envVarName := value[1:] // Remove the $ prefix
-		if envValue := os.Getenv(envVarName); envValue != "" {
-			return envValue
-		}
-		// If environment variable is not set, return the original value
-		return value
+	var data SavedRequestsData
+	if err := json.Unmarshal(contents, &data); err != nil {
+		return fmt.Errorf("not valid JSON: %v", err)
 	}
-	return value
+	return nil
 }
 
-// processTemplate applies variable substitution to a string
-// Handles both response variables like {{"RequestName".field}} and environment variables like {{varName}}
-func processTemplate(input string, variables []Variable) (string, error) {
-	if input == "" {
-		return input, nil
+// health reports the server's version, uptime and storage. Clients that aren't signed
+// in only get the status when access control is on. ?deep=true also checks the data
+// file can be read and parsed, answering 503 if it can't.
+func (api *API) health(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
-	result := input
-
-	// Find all {{ }} patterns and separate response variables from regular variables
-	responseVarPattern := regexp.MustCompile(`\{\{[^}]*\}\}`)
-	allMatches := responseVarPattern.FindAllString(result, -1)
-
-	var responseMatches []string
-	for _, match := range allMatches {
-		if strings.Contains(match, "\"") || strings.Contains(match, "\\\"") {
-			responseMatches = append(responseMatches, match)
-			log.Printf("Processing response variable: %q", match)
-		}
+	status, code := "healthy", http.StatusOK
+	result := map[string]any{
+		"service":  "postman-like-api-tester",
+		"readOnly": readOnly,
+		"version":  version,
+		"commit":   buildCommit(),
 	}
+	deep := r.URL.Query().Get("deep") == "true"
 
-	// Process response variables with JSON-aware substitution
-	result = processSubstitution(result, responseMatches)
-
-	// Process regular environment variables
-	for _, variable := range variables {
-		if variable.Key != "" {
-			resolvedValue := resolveEnvVar(variable.Value)
-			placeholder := fmt.Sprintf("{{%s}}", variable.Key)
-			result = strings.ReplaceAll(result, placeholder, resolvedValue)
+	path := activeWorkspace().File
+	if deep {
+		if err := checkDataFile(path); err != nil {
+			status, code = "unhealthy", http.StatusServiceUnavailable
+			result["error"] = "Data file " + err.Error()
 		}
 	}
 
-	return result, nil
-}
-
-// processSubstitution performs JSON-aware substitution for response variables
-func processSubstitution(input string, responseMatches []string) string {
-	result := input
-
-	for _, match := range responseMatches {
-		ref, err := parseVariable(match)
-		if err != nil {
-			continue
-		}
+	if !accessConfig.Enabled() || isAuthenticated(r) {
+		result["startedAt"] = serverStartedAt.Format(time.RFC3339)
+		result["uptimeSeconds"] = int64(time.Since(serverStartedAt).Seconds())
 
-		request, err := loadRequest(ref.RequestName)
-		if err != nil {
-			continue
+		storage := map[string]any{"workspace": activeWorkspace().ID, "path": path, "exists": false}
+		if abs, err := filepath.Abs(path); err == nil {
+			storage["path"] = abs
 		}
-
-		if request.LastResponse == nil {
-			continue
+		if info, err := os.Stat(path); err == nil {
+			storage["exists"] = true
+			storage["sizeBytes"] = info.Size()
 		}
-
-		fieldResult, err := extractJSONField(request.LastResponse.Body, ref.FieldPath)
-		if err != nil {
-			continue
+		dirty, changes, lastChange := dataFileStatus(path)
+		storage["dirty"] = dirty
+		storage["externalChanges"] = changes
+		if changes > 0 {
+			storage["lastExternalChange"] = lastChange.Format(time.RFC3339)
 		}
+		result["storage"] = storage
 
-		if fieldResult.IsObject {
-			// For JSON objects, perform JSON-aware substitution
-			result = subJSONObject(result, match, fieldResult.Value)
+		if data, err := api.store.Load(); err != nil {
+			status, code = "unhealthy", http.StatusServiceUnavailable
+			result["error"] = fmt.Sprintf("Failed to load saved requests: %v", err)
 		} else {
-			// For primitive values, use simple string replacement
-			result = strings.ReplaceAll(result, match, fieldResult.Value)
+			result["counts"] = map[string]int{
+				"requests":     len(data.Requests),
+				"environments": len(data.Environments),
+				"groups":       len(data.Groups),
+			}
+			if env, err := getActiveEnvironment(r, data); err == nil {
+				result["activeEnvironment"] = env.Name
+			}
 		}
+
+		_, err := os.Stat(filepath.Join(frontendDir, "index.html"))
+		result["frontend"] = map[string]any{"path": frontendDir, "found": err == nil}
 	}
+	result["status"] = status
 
-	return result
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		slog.Error("failed to encode health response", "error", err)
+	}
 }
 
-// subJSONObject performs JSON-aware substitution of objects
-func subJSONObject(input, placeholder, jsonValue string) string {
-	// Check if the placeholder is within a JSON context (surrounded by quotes)
-	quotedPlaceholder := "\"" + placeholder + "\""
-
-	if strings.Contains(input, quotedPlaceholder) {
-		// The placeholder is quoted (e.g., "{{test.address}}"),
-		// replace the entire quoted placeholder with the raw JSON
-		return strings.ReplaceAll(input, quotedPlaceholder, jsonValue)
-	} else {
-		// The placeholder is not quoted, treat as regular string replacement
-		return strings.ReplaceAll(input, placeholder, jsonValue)
-	}
+// pingTimeout bounds a reachability check
+const pingTimeout = 5 * time.Second
+
+// PingResult reports whether a URL answered a reachability check
+type PingResult struct {
+	URL        string `json:"url"`              // URL after template substitution
+	Reachable  bool   `json:"reachable"`        // Any HTTP response counts as reachable
+	Method     string `json:"method,omitempty"` // HEAD, or GET when the server rejected HEAD
+	Status     string `json:"status,omitempty"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	RemoteAddr string `json:"remoteAddr,omitempty"` // Resolved IP and port that was connected to
+	DurationMs int64  `json:"durationMs"`
+	Error      string `json:"error,omitempty"`
+	ErrorKind  string `json:"errorKind,omitempty"` // dns, timeout, connection or tls when unreachable
 }
 
-// processTemplates applies variable substitution to all templated fields in a request
-func processTemplates(req ProxyRequest) ProxyRequest {
-	// Helper function to safely process a template field
-	processField := func(fieldName, value string) string {
-		if processed, err := processTemplate(value, req.Variables); err == nil {
-			return processed
-		} else {
-			log.Printf("⚠️  Template error in %s: %v", fieldName, err)
-			return value
-		}
+// pingOnce sends a single bodiless request and records the connected address
+func pingOnce(method, target string, extra map[string]string) (*http.Response, string, error) {
+	var remoteAddr string
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			remoteAddr = info.Conn.RemoteAddr().String()
+		},
 	}
-
-	// Process URL
-	req.URL = processField("URL", req.URL)
-
-	// Process headers
-	processedHeaders := make(map[string]string)
-	for key, value := range req.Headers {
-		processedKey := processField("header key", key)
-		processedValue := processField("header value", value)
-		processedHeaders[processedKey] = processedValue
+	httpReq, err := http.NewRequest(method, target, nil)
+	if err != nil {
+		return nil, "", err
 	}
-	req.Headers = processedHeaders
-
-	// Process body
-	// If using typed JSON, process each BodyJson field's key/value/parent
-	if req.BodyType == "json" && len(req.BodyJson) > 0 {
-		processedJson := make([]BodyField, 0, len(req.BodyJson))
-		for _, f := range req.BodyJson {
-			if f.Key != "" {
-				f.Key = processField("json body key", f.Key)
-			}
-			if f.Value != "" {
-				f.Value = processField("json body value", f.Value)
-			}
-			if f.Parent != "" {
-				f.Parent = processField("json body parent", f.Parent)
-			}
-			processedJson = append(processedJson, f)
-		}
-		req.BodyJson = processedJson
-	} else if req.BodyType == "form" && len(req.BodyForm) > 0 {
-		processedForm := make([]BodyField, 0, len(req.BodyForm))
-		for _, f := range req.BodyForm {
-			if f.Key != "" {
-				f.Key = processField("form body key", f.Key)
-			}
-			if f.Value != "" {
-				f.Value = processField("form body value", f.Value)
-			}
-			processedForm = append(processedForm, f)
-		}
-		req.BodyForm = processedForm
+	httpReq = httpReq.WithContext(httptrace.WithClientTrace(httpReq.Context(), trace))
+	for key, value := range extra {
+		httpReq.Header.Set(key, value)
 	}
 
-	return req
+	client := &http.Client{Timeout: pingTimeout, Transport: proxyTransport}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, remoteAddr, err
+	}
+	resp.Body.Close()
+	return resp, remoteAddr, nil
 }
 
-// =============================================================================
-// DATA MIGRATION & INITIALIZATION
-// =============================================================================
+// ping handles POST requests to check that a URL is reachable without sending a full request
+//
+// A HEAD request is tried first; servers that reject HEAD get a GET for a single byte.
+func (api *API) ping(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-// initEnv creates a default environment for new installations
-func initEnv(data *SavedRequestsData) *SavedRequestsData {
-	now := time.Now().Format(time.RFC3339)
-	defaultEnv := Environment{
+	var req struct {
+		URL string `json:"url"`
+	}
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+	if req.URL == "" {
+		respondWithError(w, "URL is required", http.StatusBadRequest)
+		return
+	}
+
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load environment data", "error", err)
+		respondWithError(w, "Failed to load environment data", http.StatusInternalServerError)
+		return
+	}
+	currentEnv, err := getActiveEnvironment(r, data)
+	if err != nil {
+		slog.Error("failed to get current environment", "error", err)
+		respondWithError(w, "Failed to get current environment", http.StatusInternalServerError)
+		return
+	}
+
+	pingReq := ProxyRequest{URL: req.URL}
+	if err := applyEnvironmentBaseURL(&pingReq, currentEnv); err != nil {
+		respondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	variables, err := api.resolveNestedVariables(scopeVariables(data, currentEnv))
+	if err != nil {
+		respondWithError(w, fmt.Sprintf("Template processing failed: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+	target, err := api.processURL(pingReq.URL, variables, make(map[string]string))
+	if err != nil {
+		respondWithError(w, fmt.Sprintf("Template processing failed: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+
+	result := PingResult{URL: redactSecrets(target, variables), Method: http.MethodHead}
+	start := time.Now()
+	resp, remoteAddr, err := pingOnce(http.MethodHead, target, nil)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		result.Method = http.MethodGet
+		resp, remoteAddr, err = pingOnce(http.MethodGet, target, map[string]string{"Range": "bytes=0-0"})
+	}
+	result.DurationMs = time.Since(start).Milliseconds()
+	result.RemoteAddr = remoteAddr
+	if err != nil {
+		result.Error = redactSecrets(err.Error(), variables)
+		result.ErrorKind = classifyRequestError(err)
+		slog.Info("ping failed", "url", result.URL, "kind", result.ErrorKind, "error", result.Error)
+	} else {
+		result.Reachable = true
+		result.Status = resp.Status
+		result.StatusCode = resp.StatusCode
+		slog.Info("ping", "url", result.URL, "status", resp.Status, "method", result.Method, "remote", remoteAddr)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		slog.Error("failed to encode ping response", "error", err)
+	}
+}
+
+// buildJSON builds JSON from typed body fields for preview purposes
+func (api *API) buildJSON(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		BodyJson []BodyField `json:"bodyJson"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("invalid request body for buildJSON", "error", err)
+		respondWithError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Build JSON from typed fields
+	jsonObj, err := buildJSONFromBodyFields(req.BodyJson)
+	if err != nil {
+		slog.Error("failed to build JSON from body fields", "error", err)
+		respondWithError(w, fmt.Sprintf("Failed to build JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Return the built JSON structure
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]any{
+		"json": jsonObj,
+		"jsonString": func() string {
+			if jsonBytes, err := json.MarshalIndent(jsonObj, "", "  "); err == nil {
+				return string(jsonBytes)
+			}
+			return ""
+		}(),
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.Error("failed to encode buildJSON response", "error", err)
+	}
+}
+
+// buildForm builds x-www-form-urlencoded from form fields for preview purposes
+func (api *API) buildForm(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		BodyForm []BodyField `json:"bodyForm"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("invalid request body for buildForm", "error", err)
+		respondWithError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	encoded := buildFormEncoded(req.BodyForm)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{"formString": encoded}); err != nil {
+		slog.Error("failed to encode buildForm response", "error", err)
+	}
+}
+
+// proxy handles requests to external APIs with template processing
+//
+// This is the core functionality that:
+// 1. Accepts a ProxyRequest with URL, method, headers, body, and variables
+// 2. Applies template substitution using environment variables and response references
+// 3. Makes the HTTP request to the target API
+// 4. Returns the response with parsed JSON body when possible
+//
+// Template processing supports:
+// - Environment variables: {{varName}} -> resolved from current environment
+// - Response variables: {{"RequestName".field}} -> extracts field from saved response
+// - System environment variables: values starting with $ are resolved from OS env
+func (api *API) proxy(w http.ResponseWriter, r *http.Request) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Warn("panic in handleProxy", "panic", r)
+			respondWithError(w, "Internal server error", http.StatusInternalServerError)
+		}
+	}()
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req, data, currentEnv, executionID, ok := api.readProxyCall(w, r)
+	if !ok {
+		return
+	}
+	execution, err := startExecution(r.Context(), executionID, req.Method, req.URL)
+	if err != nil {
+		respondWithError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	defer finishExecution(execution)
+
+	response, status := api.sendProxyRequest(execution.ctx, req, data, currentEnv, revealSecrets(r))
+	response.ExecutionID = execution.ID
+
+	// Return the response to the UI (frontend)
+	w.Header().Set(executionIDHeader, execution.ID)
+	setRetryAfter(w, response.retryAfter)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.Error("failed to encode response", "error", err)
+	}
+}
+
+// readProxyCall decodes and validates a call to /api/proxy, /api/proxy/async or
+// /api/proxy/compare, and loads the environment it runs in. It returns the execution ID
+// the client asked for, if any. On failure it has already answered the client.
+func (api *API) readProxyCall(w http.ResponseWriter, r *http.Request) (ProxyRequest, *SavedRequestsData, *Environment, string, bool) {
+	var req ProxyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("invalid request body", "error", err)
+		respondWithError(w, "Invalid request body", http.StatusBadRequest)
+		return req, nil, nil, "", false
+	}
+
+	// Validate required fields
+	if req.URL == "" {
+		respondWithError(w, "URL is required", http.StatusBadRequest)
+		return req, nil, nil, "", false
+	}
+
+	if req.Method == "" {
+		req.Method = "GET"
+	}
+
+	executionID := r.Header.Get(executionIDHeader)
+	if executionID != "" && !executionIDPattern.MatchString(executionID) {
+		respondWithError(w, executionIDHeader+" must be 1-64 letters, digits, '.', '_' or '-'", http.StatusBadRequest)
+		return req, nil, nil, "", false
+	}
+
+	// Get variables from current environment for template processing
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load environment data", "error", err)
+		respondWithError(w, "Failed to load environment data", http.StatusInternalServerError)
+		return req, nil, nil, "", false
+	}
+
+	currentEnv, err := getActiveEnvironment(r, data)
+	if err != nil {
+		slog.Error("failed to get current environment", "error", err)
+		respondWithError(w, "Failed to get current environment", http.StatusInternalServerError)
+		return req, nil, nil, "", false
+	}
+	return req, data, currentEnv, executionID, true
+}
+
+// sendProxyRequest runs a request through the proxy pipeline in the given environment
+// and sends it: environment headers and base URL, inherited auth, pre-request steps,
+// template processing, the strict-mode check and authentication. It returns the
+// response and the HTTP status to answer the client with (422 for strict-mode refusals).
+// Secret values are masked in the response unless reveal is set.
+func (api *API) sendProxyRequest(ctx context.Context, req ProxyRequest, data *SavedRequestsData, currentEnv *Environment, reveal bool) (ProxyResponse, int) {
+	// Use environment variables instead of request variables for template processing,
+	// with the request's own overrides taking precedence
+	scopeVars := scopeVariables(data, currentEnv)
+	req.Variables = layerVariables(req.Overrides, scopeVars)
+	req.EnvironmentID = currentEnv.ID
+	applyHeaderList(&req)
+	applyEnvironmentHeaders(&req, currentEnv)
+	if err := applyEnvironmentBaseURL(&req, currentEnv); err != nil {
+		slog.Error("template processing failed", "error", err)
+		return ProxyResponse{Error: err.Error()}, http.StatusBadRequest
+	}
+	if err := validateTimeoutMs("timeoutMs", req.TimeoutMs); err != nil {
+		return ProxyResponse{Error: err.Error()}, http.StatusBadRequest
+	}
+	overrides, err := normalizeHostOverrides(req.HostOverrides)
+	if err != nil {
+		return ProxyResponse{Error: err.Error()}, http.StatusBadRequest
+	}
+	req.HostOverrides = overrides
+	switch req.HTTPVersion = strings.ToLower(strings.TrimSpace(req.HTTPVersion)); req.HTTPVersion {
+	case "", httpVersionAuto, httpVersionHTTP1, httpVersionH2:
+	default:
+		return ProxyResponse{Error: fmt.Sprintf("httpVersion must be %q, %q or %q, got %q", httpVersionAuto, httpVersionHTTP1, httpVersionH2, req.HTTPVersion)}, http.StatusBadRequest
+	}
+	applyEnvironmentTimeout(&req, currentEnv)
+	applyEnvironmentHostOverrides(&req, currentEnv)
+	applyInheritedAuth(&req, data)
+
+	// Run pre-request hooks before template processing
+	if err := api.runPreRequestSteps(ctx, &req, nil); err != nil {
+		slog.Error("pre-request hook failed", "error", err)
+		return ProxyResponse{Error: fmt.Sprintf("Pre-request hook failed: %v", err)}, http.StatusOK
+	}
+	applyDefaultUserAgent(&req, data)
+
+	// Apply template processing to substitute variables
+	processedReq, err := api.processTemplates(req)
+	if err != nil {
+		slog.Error("template processing failed", "error", err)
+		return ProxyResponse{Error: fmt.Sprintf("Template processing failed: %v", err)}, http.StatusOK
+	}
+
+	// In strict mode, refuse to send literal placeholders to the target
+	if req.Strict || data.StrictTemplates {
+		if unresolved := findUnresolvedPlaceholders(processedReq); len(unresolved) > 0 {
+			slog.Info("strict templates: unresolved placeholders", "count", len(unresolved))
+			return ProxyResponse{
+				Status:     "422 Unresolved Template Variables",
+				StatusCode: http.StatusUnprocessableEntity,
+				Error:      fmt.Sprintf("Unresolved template variables: %s", strings.Join(unresolved, ", ")),
+				Unresolved: unresolved,
+			}, http.StatusUnprocessableEntity
+		}
+	}
+	slog.Debug("proxy templates",
+		"url", redactSecrets(req.URL, processedReq.Variables),
+		"processedUrl", redactSecrets(processedReq.URL, processedReq.Variables))
+
+	// Fetch credentials and add them to the request
+	if err := applyAuth(&processedReq, currentEnv.ID); err != nil {
+		slog.Error("authentication failed", "error", err)
+		return ProxyResponse{Error: fmt.Sprintf("Authentication failed: %v", err)}, http.StatusOK
+	}
+
+	if wait, scope := proxyRateLimiter.Reserve(processedReq.URL); wait > 0 {
+		slog.Info("rate limit exceeded", "scope", scope, "method", processedReq.Method, "url", redactSecrets(processedReq.URL, processedReq.Variables))
+		return ProxyResponse{
+			Status:     "429 Too Many Requests",
+			StatusCode: http.StatusTooManyRequests,
+			Error:      rateLimitMessage(scope, wait),
+			ErrorKind:  errorKindRateLimit,
+			retryAfter: wait,
+		}, http.StatusTooManyRequests
+	}
+
+	// Make the HTTP request
+	response := makeHTTPRequest(ctx, processedReq)
+	if processedReq.FollowPages {
+		followPagination(ctx, processedReq, &response)
+	}
+	recordExecution(processedReq, response, req.RequestID, req.Name)
+	if req.RequestID != "" {
+		if err := api.recordStatusHistory(req.RequestID, response.StatusCode); err != nil {
+			slog.Warn("failed to record status history", "error", err)
+		}
+	}
+
+	if req.Expect != nil {
+		response.Expectations = evaluateExpectations(&response, req.Expect)
+	}
+	if req.Transform != "" && response.Error == "" {
+		response.BodyTransformed, response.TransformError = transformBody(req.Transform, response.Body)
+	}
+
+	// Echo the request as sent, keeping only variables defined by pre-request steps and overrides
+	echo := processedReq
+	echo.Variables = processedReq.Variables[:len(processedReq.Variables)-len(scopeVars)]
+	response.Request = &echo
+	if !reveal {
+		redactResponse(&response, processedReq.Variables)
+	}
+
+	return response, http.StatusOK
+}
+
+// evaluateExpectations checks a response against the expectations sent with the request
+func evaluateExpectations(resp *ProxyResponse, expect *Expectation) *ExpectationResult {
+	result := &ExpectationResult{Failures: []string{}}
+	if expect.Status != nil && resp.StatusCode != *expect.Status {
+		if resp.StatusCode == 0 {
+			result.Failures = append(result.Failures, fmt.Sprintf("status: expected %d, got no response", *expect.Status))
+		} else {
+			result.Failures = append(result.Failures, fmt.Sprintf("status: expected %d, got %d", *expect.Status, resp.StatusCode))
+		}
+	}
+	if expect.MaxDurationMs != nil && resp.DurationMs > *expect.MaxDurationMs {
+		result.Failures = append(result.Failures, fmt.Sprintf("duration: expected at most %dms, took %dms", *expect.MaxDurationMs, resp.DurationMs))
+	}
+	for _, field := range expect.BodyFields {
+		if !responseHasField(resp, field) {
+			result.Failures = append(result.Failures, fmt.Sprintf("body: missing field %q", field))
+		}
+	}
+	result.Passed = len(result.Failures) == 0
+	return result
+}
+
+// responseHasField reports whether a dot path exists in a JSON or form-encoded body
+//
+// A field that is present with a null value counts as present.
+func responseHasField(resp *ProxyResponse, fieldPath string) bool {
+	current := resp.Body
+	if text, ok := current.(string); ok && isFormContentType(headerValue(resp.Headers, "Content-Type")) {
+		form, err := parseFormBody(text)
+		if err != nil {
+			return false
+		}
+		current = form
+	}
+	for _, part := range strings.Split(fieldPath, ".") {
+		if part == "" {
+			continue
+		}
+		object, ok := current.(map[string]any)
+		if !ok {
+			return false
+		}
+		if current, ok = object[part]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// FieldChange is one difference between two response bodies. Path uses the same dot
+// notation as response variables, with [i] for array elements; "response" is the whole body.
+type FieldChange struct {
+	Path string `json:"path"`
+	Old  any    `json:"old,omitempty"`
+	New  any    `json:"new,omitempty"`
+}
+
+// ResponseDiff groups the differences between a previous and a new response body
+type ResponseDiff struct {
+	Added   []FieldChange `json:"added"`
+	Removed []FieldChange `json:"removed"`
+	Changed []FieldChange `json:"changed"`
+}
+
+// compareProxy handles POST requests to send a request and diff the response body
+// against the saved request's LastResponse
+//
+// The request is sent exactly as /api/proxy would send it, under an execution ID that
+// DELETE /api/proxy/{executionId} can cancel. The saved request is found by requestId;
+// when it has no previous response the whole new body is reported as added. The
+// stored LastResponse is not updated.
+func (api *API) compareProxy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req, data, currentEnv, executionID, ok := api.readProxyCall(w, r)
+	if !ok {
+		return
+	}
+	execution, err := startExecution(r.Context(), executionID, req.Method, req.URL)
+	if err != nil {
+		respondWithError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	defer finishExecution(execution)
+
+	// Capture the previous response before sending, since pre-request steps may reload data
+	var previous *ProxyResponse
+	if saved := findProxiedSaved(data, &req); saved != nil {
+		previous = saved.LastResponse
+	}
+
+	response, status := api.sendProxyRequest(execution.ctx, req, data, currentEnv, revealSecrets(r))
+	response.ExecutionID = execution.ID
+
+	diff := ResponseDiff{Added: []FieldChange{}, Removed: []FieldChange{}, Changed: []FieldChange{}}
+	result := map[string]any{
+		"response":    response,
+		"hasPrevious": previous != nil,
+	}
+	if previous == nil {
+		diff.Added = append(diff.Added, FieldChange{Path: "response", New: response.Body})
+	} else {
+		// Exact numbers only when both sides have them, or every number would differ by type
+		before, after := previous.Body, response.Body
+		if previous.RawBody != "" && response.RawBody != "" {
+			before, after = preciseBody(previous), preciseBody(&response)
+		}
+		diffJSON("", before, after, &diff)
+		result["previousStatusCode"] = previous.StatusCode
+	}
+	result["diff"] = diff
+
+	slog.Info("compared responses", "method", req.Method, "url", req.URL,
+		"added", len(diff.Added), "removed", len(diff.Removed), "changed", len(diff.Changed))
+
+	w.Header().Set(executionIDHeader, execution.ID)
+	setRetryAfter(w, response.retryAfter)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
+
+// smokeTag marks the saved requests that POST /api/smoke runs
+const smokeTag = "smoke"
+
+// Run result statuses
+const (
+	runPassed    = "passed"
+	runFailed    = "failed"
+	runCancelled = "cancelled" // Not sent, or cut off, because the run's time budget ran out
+)
+
+// RunResult is the outcome of one request in a smoke or group run
+type RunResult struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Group      string   `json:"group"`
+	Status     string   `json:"status"` // passed, failed or cancelled
+	Passed     bool     `json:"passed"`
+	StatusCode int      `json:"statusCode"`
+	DurationMs int64    `json:"durationMs"`
+	Error      string   `json:"error,omitempty"`
+	Failures   []string `json:"failures,omitempty"` // Unmet expectations
+}
+
+// smoke handles POST requests that send every saved request tagged "smoke" and report
+// which passed, across all groups
+func (api *API) smoke(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	api.runCollection(w, r, "smoke test", func(data *SavedRequestsData) ([]SavedRequest, bool) {
+		var tagged []SavedRequest
+		for _, saved := range data.Requests {
+			if slices.Contains(saved.Tags, smokeTag) {
+				tagged = append(tagged, saved)
+			}
+		}
+		return tagged, true
+	})
+}
+
+// runGroup handles POST /api/groups/{id}/run, which sends every request in a group and
+// its subgroups and reports which passed
+func (api *API) runGroup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	api.runCollection(w, r, "group run", func(data *SavedRequestsData) ([]SavedRequest, bool) {
+		group := findGroup(data, chi.URLParam(r, "id"))
+		if group == nil {
+			respondWithError(w, "Group not found", http.StatusNotFound)
+			return nil, false
+		}
+		names := map[string]bool{}
+		var walk func(nodes []GroupNode, inside bool)
+		walk = func(nodes []GroupNode, inside bool) {
+			for _, node := range nodes {
+				within := inside || node.ID == group.ID
+				if within {
+					names[node.Name] = true
+				}
+				walk(node.Children, within)
+			}
+		}
+		walk(buildGroupTree(data.Groups), false)
+
+		var requests []SavedRequest
+		for _, saved := range data.Requests {
+			if names[saved.Group] {
+				requests = append(requests, saved)
+			}
+		}
+		return requests, true
+	})
+}
+
+// runCollection sends the saved requests chosen by pick and reports which passed. pick
+// writes its own error response and returns false when there is nothing to run.
+//
+// Requests run one after another, in the order of the group tree, through the same
+// pipeline as /api/proxy, in the active environment or the one given by ?environment=
+// (an ID) without changing the active one. A request passes with a 2xx status, or the
+// status in the optional body's expect, and with the rest of expect met. Templates are
+// skipped. The body's runTimeoutMs bounds the whole run: once it runs out, the request
+// in flight is aborted and it and the rest are reported as cancelled, alongside the
+// results so far. Each request's own timeout still applies within the budget.
+func (api *API) runCollection(w http.ResponseWriter, r *http.Request, kind string, pick func(data *SavedRequestsData) ([]SavedRequest, bool)) {
+	var req struct {
+		Expect       *Expectation `json:"expect"`       // Checked against every response
+		RunTimeoutMs int          `json:"runTimeoutMs"` // Budget for the whole run; 0 for none
+	}
+	if r.ContentLength != 0 {
+		if !decodeJSONRequest(w, r, &req) {
+			return
+		}
+	}
+	if req.RunTimeoutMs < 0 {
+		respondWithError(w, "runTimeoutMs can't be negative", http.StatusBadRequest)
+		return
+	}
+
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load saved requests", "error", err)
+		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
+		return
+	}
+
+	var env *Environment
+	if envID := r.URL.Query().Get("environment"); envID != "" {
+		if env = findEnvironment(data, envID); env == nil {
+			respondWithError(w, "Environment not found", http.StatusNotFound)
+			return
+		}
+	} else if env, err = getActiveEnvironment(r, data); err != nil {
+		slog.Error("failed to get current environment", "error", err)
+		respondWithError(w, "Failed to get current environment", http.StatusInternalServerError)
+		return
+	}
+
+	picked, ok := pick(data)
+	if !ok {
+		return
+	}
+	picked = slices.DeleteFunc(picked, func(saved SavedRequest) bool { return saved.Template })
+	picked = orderByGroupTree(data, picked)
+
+	ctx := r.Context()
+	if req.RunTimeoutMs > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(req.RunTimeoutMs)*time.Millisecond)
+		defer cancel()
+	}
+
+	slog.Info(kind+" started", "requests", len(picked), "environment", env.Name, "runTimeoutMs", req.RunTimeoutMs)
+
+	results := make([]RunResult, 0, len(picked))
+	passed, cancelled := 0, 0
+	for _, saved := range picked {
+		if r.Context().Err() != nil {
+			slog.Info(kind+" abandoned by the client", "completed", len(results))
+			return
+		}
+		result := RunResult{ID: saved.ID, Name: saved.Name, Group: saved.Group}
+		if ctx.Err() != nil {
+			result.Status = runCancelled
+			result.Error = "Run timeout exceeded"
+			cancelled++
+			results = append(results, result)
+			continue
+		}
+
+		proxyReq := proxyRequestFromSaved(saved, nil)
+		proxyReq.RequestID = saved.ID
+		proxyReq.Name = saved.Name
+		proxyReq.Expect = req.Expect
+		response, _ := api.sendProxyRequest(ctx, proxyReq, data, env, false)
+
+		result.StatusCode = response.StatusCode
+		result.DurationMs = response.DurationMs
+		result.Error = response.Error
+		if response.Error != "" && ctx.Err() != nil && r.Context().Err() == nil {
+			result.Status = runCancelled
+			result.Error = "Run timeout exceeded: " + response.Error
+			cancelled++
+			results = append(results, result)
+			continue
+		}
+
+		statusOK := response.StatusCode >= 200 && response.StatusCode < 300
+		if response.Expectations != nil {
+			result.Failures = response.Expectations.Failures
+			if req.Expect.Status != nil {
+				statusOK = response.StatusCode == *req.Expect.Status
+			}
+		}
+		if !statusOK && len(result.Failures) == 0 && response.Error == "" {
+			result.Failures = []string{fmt.Sprintf("status: expected 2xx, got %d", response.StatusCode)}
+		}
+		result.Passed = statusOK && len(result.Failures) == 0 && response.Error == ""
+		result.Status = runFailed
+		if result.Passed {
+			result.Status = runPassed
+			passed++
+		}
+		results = append(results, result)
+	}
+
+	failed := len(results) - passed - cancelled
+	slog.Info(kind+" finished", "passed", passed, "failed", failed, "cancelled", cancelled)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"environment": map[string]string{"id": env.ID, "name": env.Name},
+		"total":       len(results),
+		"passed":      passed,
+		"failed":      failed,
+		"cancelled":   cancelled,
+		"results":     results,
+	})
+}
+
+// diffJSON walks two decoded JSON values in parallel and records added, removed and
+// changed fields under path. Objects are compared key by key and arrays index by index;
+// anything else, including a change of type, is a single change at path.
+func diffJSON(path string, before, after any, diff *ResponseDiff) {
+	label := path
+	if label == "" {
+		label = "response"
+	}
+
+	switch oldVal := before.(type) {
+	case map[string]any:
+		newVal, ok := after.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(oldVal)+len(newVal))
+		for key := range oldVal {
+			keys = append(keys, key)
+		}
+		for key := range newVal {
+			if _, exists := oldVal[key]; !exists {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+			o, inOld := oldVal[key]
+			n, inNew := newVal[key]
+			switch {
+			case !inOld:
+				diff.Added = append(diff.Added, FieldChange{Path: childPath, New: n})
+			case !inNew:
+				diff.Removed = append(diff.Removed, FieldChange{Path: childPath, Old: o})
+			default:
+				diffJSON(childPath, o, n, diff)
+			}
+		}
+		return
+	case []any:
+		newVal, ok := after.([]any)
+		if !ok {
+			break
+		}
+		for i := 0; i < len(oldVal) || i < len(newVal); i++ {
+			childPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(oldVal):
+				diff.Added = append(diff.Added, FieldChange{Path: childPath, New: newVal[i]})
+			case i >= len(newVal):
+				diff.Removed = append(diff.Removed, FieldChange{Path: childPath, Old: oldVal[i]})
+			default:
+				diffJSON(childPath, oldVal[i], newVal[i], diff)
+			}
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(before, after) {
+		diff.Changed = append(diff.Changed, FieldChange{Path: label, Old: before, New: after})
+	}
+}
+
+// previewProxy handles POST requests to dry-run the template pipeline without sending
+//
+// The request goes through the same pre-request steps and template processing as
+// /api/proxy, except that runRequest steps are skipped so the preview has no side
+// effects; references to those requests use their last recorded response instead.
+func (api *API) previewProxy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ProxyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("invalid request body", "error", err)
+		respondWithError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.URL == "" {
+		respondWithError(w, "URL is required", http.StatusBadRequest)
+		return
+	}
+
+	if req.Method == "" {
+		req.Method = "GET"
+	}
+
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load environment data", "error", err)
+		respondWithError(w, "Failed to load environment data", http.StatusInternalServerError)
+		return
+	}
+
+	currentEnv, err := getActiveEnvironment(r, data)
+	if err != nil {
+		slog.Error("failed to get current environment", "error", err)
+		respondWithError(w, "Failed to get current environment", http.StatusInternalServerError)
+		return
+	}
+
+	scopeVars := scopeVariables(data, currentEnv)
+	req.Variables = layerVariables(req.Overrides, scopeVars)
+	applyHeaderList(&req)
+	applyEnvironmentHeaders(&req, currentEnv)
+	preview := PreviewResponse{
+		Substitutions: []Substitution{},
+		Unresolved:    []string{},
+		Warnings:      []string{},
+	}
+	if err := applyEnvironmentBaseURL(&req, currentEnv); err != nil {
+		preview.Warnings = append(preview.Warnings, err.Error())
+	}
+	applyEnvironmentHostOverrides(&req, currentEnv)
+	preview.AuthSource = applyInheritedAuth(&req, data)
+
+	// Skip runRequest steps; everything else is side-effect free
+	var chain []string
+	if req.Name != "" {
+		chain = []string{req.Name}
+	}
+	steps := make([]PreRequestStep, 0, len(req.PreRequest))
+	for i, step := range req.PreRequest {
+		if step.Type == "runRequest" {
+			preview.Warnings = append(preview.Warnings,
+				fmt.Sprintf("pre-request step %d: runRequest %q skipped in preview", i+1, step.Request))
+			if err := checkPreRequestChain(data, step.Request, chain); err != nil {
+				preview.Warnings = append(preview.Warnings, fmt.Sprintf("pre-request step %d: %v", i+1, err))
+			}
+			continue
+		}
+		steps = append(steps, step)
+	}
+	req.PreRequest = steps
+
+	if err := api.runPreRequestSteps(r.Context(), &req, nil); err != nil {
+		preview.Warnings = append(preview.Warnings, fmt.Sprintf("pre-request hook failed: %v", err))
+	}
+	applyDefaultUserAgent(&req, data)
+	stepVariables := req.Variables[:len(req.Variables)-len(req.Overrides)-len(scopeVars)]
+	preview.Variables = effectiveVariables(
+		append([]variableLayer{
+			{"preRequest", stepVariables},
+			{"override", req.Overrides},
+		}, scopeLayers(data, currentEnv)...)...,
+	)
+
+	processedReq, err := api.processTemplates(req)
+	if err != nil {
+		preview.Warnings = append(preview.Warnings, fmt.Sprintf("template processing failed: %v", err))
+	} else {
+		preview.Substitutions, preview.Warnings = api.describeSubstitutions(req, processedReq.Variables, preview.Warnings)
+	}
+
+	// Only a cached token is used so the preview never calls the token endpoint
+	if auth := processedReq.Auth; auth != nil && auth.Type == "oauth2" && auth.OAuth2 != nil {
+		if token, ok := cachedOAuth2Token(currentEnv.ID, auth.OAuth2); ok {
+			processedReq.Headers["Authorization"] = "Bearer " + token
+		} else {
+			preview.Warnings = append(preview.Warnings,
+				fmt.Sprintf("oauth2: no cached token; one will be fetched from %s when sent", auth.OAuth2.TokenURL))
+		}
+	}
+
+	if unresolved := findUnresolvedPlaceholders(processedReq); len(unresolved) > 0 {
+		preview.Unresolved = unresolved
+		if req.Strict || data.StrictTemplates {
+			preview.Warnings = append(preview.Warnings, "strict templates: this request would be rejected")
+		}
+	}
+
+	// Like the proxy echo, only variables defined by pre-request steps and overrides are returned
+	resolvedVars := processedReq.Variables
+	processedReq.Variables = processedReq.Variables[:len(processedReq.Variables)-len(scopeVars)]
+	processedReq.PreRequest = nil
+	preview.Request = processedReq
+
+	if !revealSecrets(r) {
+		preview.Request = redactRequest(processedReq, resolvedVars)
+		preview.Variables = maskEffectiveVariables(preview.Variables)
+		for i, sub := range preview.Substitutions {
+			preview.Substitutions[i].Value = redactSecrets(sub.Value, resolvedVars)
+		}
+	}
+
+	slog.Info("previewed request", "method", processedReq.Method, "url", redactSecrets(processedReq.URL, req.Variables),
+		"substitutions", len(preview.Substitutions), "unresolved", len(preview.Unresolved))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(preview); err != nil {
+		slog.Error("failed to encode preview response", "error", err)
+	}
+}
+
+// describeSubstitutions resolves each placeholder of a request on its own to report
+// what it was replaced with
+//
+// Response variables that can't be resolved get a warning explaining why: the
+// referenced request doesn't exist, has no recorded response, or lacks the field.
+func (api *API) describeSubstitutions(req ProxyRequest, variables []Variable, warnings []string) ([]Substitution, []string) {
+	substitutions := []Substitution{}
+	for _, placeholder := range collectPlaceholders(req) {
+		content := strings.TrimSpace(placeholder[2 : len(placeholder)-2])
+
+		if len(findResponseMatches(placeholder)) > 0 {
+			if warning := api.responseVariableWarning(placeholder); warning != "" {
+				warnings = append(warnings, warning)
+				continue
+			}
+		}
+
+		value, err := api.processTemplate(placeholder, variables)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: %v", placeholder, err))
+			continue
+		}
+		if value == placeholder {
+			continue
+		}
+
+		substitutions = append(substitutions, Substitution{
+			Placeholder: placeholder,
+			Value:       value,
+			Dynamic:     strings.HasPrefix(content, "$"),
+		})
+	}
+	return substitutions, warnings
+}
+
+// responseVariableWarning explains why a response variable can't be resolved, or
+// returns "" when it can
+func (api *API) responseVariableWarning(placeholder string) string {
+	ref, err := parseVariable(placeholder)
+	if err != nil {
+		return fmt.Sprintf("%s: %v", placeholder, err)
+	}
+
+	var request *SavedRequest
+	if ref.RequestID != "" {
+		request, err = api.loadRequestByID(ref.RequestID)
+	} else {
+		request, err = api.loadRequest(ref.RequestName)
+	}
+	if err != nil {
+		return fmt.Sprintf("%s: %v", placeholder, err)
+	}
+	if request.LastResponse == nil {
+		return fmt.Sprintf("%s: request %q has no recorded response", placeholder, request.Name)
+	}
+	if _, err := extractResponseField(request.LastResponse, ref); err != nil {
+		return fmt.Sprintf("%s: %v", placeholder, err)
+	}
+	return ""
+}
+
+// openBinaryBody returns a reader and its length for a binary body, streaming from disk
+// when a file path is given
+//
+// A file reader is closed by the HTTP client once the request has been sent.
+func openBinaryBody(req ProxyRequest) (io.Reader, int64, error) {
+	if req.BodyFile != "" {
+		file, err := os.Open(req.BodyFile)
+		if err != nil {
+			return nil, 0, err
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, 0, err
+		}
+		if info.IsDir() {
+			file.Close()
+			return nil, 0, fmt.Errorf("%s is a directory", req.BodyFile)
+		}
+		slog.Debug("streaming binary body", "path", req.BodyFile, "bytes", info.Size())
+		return file, info.Size(), nil
+	}
+
+	if req.BodyBase64 != "" {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(req.BodyBase64))
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid base64 content: %v", err)
+		}
+		slog.Debug("built binary body from base64 content", "bytes", len(decoded))
+		return bytes.NewReader(decoded), int64(len(decoded)), nil
+	}
+
+	return nil, 0, nil
+}
+
+// gRPC-Web frame flags: data frames carry a message, trailer frames carry the status
+const (
+	grpcWebDataFrame    byte = 0x00
+	grpcWebCompressed   byte = 0x01
+	grpcWebTrailerFrame byte = 0x80
+)
+
+// grpcWebRequestBody frames base64-encoded message bytes as a single gRPC-Web data frame
+//
+// The server doesn't know the proto schema, so the client sends the serialized
+// message and it is only length-prefixed here.
+func grpcWebRequestBody(messageBase64 string) ([]byte, error) {
+	message, err := base64.StdEncoding.DecodeString(strings.TrimSpace(messageBase64))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 message: %v", err)
+	}
+	framed := make([]byte, 5+len(message))
+	framed[0] = grpcWebDataFrame
+	binary.BigEndian.PutUint32(framed[1:5], uint32(len(message)))
+	copy(framed[5:], message)
+	return framed, nil
+}
+
+// isGrpcWebContentType reports whether a Content-Type denotes a gRPC-Web body, and
+// whether it uses the base64 "-text" variant
+func isGrpcWebContentType(contentType string) (grpcWeb, text bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false, false
+	}
+	if strings.HasPrefix(mediaType, "application/grpc-web-text") {
+		return true, true
+	}
+	return strings.HasPrefix(mediaType, "application/grpc-web"), false
+}
+
+// parseGrpcWebBody splits a gRPC-Web response body into its messages and trailers
+func parseGrpcWebBody(body []byte) (GrpcWebBody, error) {
+	result := GrpcWebBody{Messages: []string{}}
+	for len(body) > 0 {
+		if len(body) < 5 {
+			return result, fmt.Errorf("truncated frame header (%d bytes)", len(body))
+		}
+		flag := body[0]
+		length := binary.BigEndian.Uint32(body[1:5])
+		if uint64(len(body)-5) < uint64(length) {
+			return result, fmt.Errorf("truncated frame: want %d bytes, have %d", length, len(body)-5)
+		}
+		payload := body[5 : 5+length]
+		body = body[5+length:]
+
+		if flag&grpcWebCompressed != 0 {
+			return result, fmt.Errorf("compressed frames are not supported")
+		}
+		if flag&grpcWebTrailerFrame != 0 {
+			if result.Trailers == nil {
+				result.Trailers = make(map[string]string)
+			}
+			for _, line := range strings.Split(string(payload), "\r\n") {
+				if key, value, ok := strings.Cut(line, ":"); ok {
+					result.Trailers[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+				}
+			}
+			continue
+		}
+		result.Messages = append(result.Messages, base64.StdEncoding.EncodeToString(payload))
+	}
+	return result, nil
+}
+
+// applyGrpcWebResponse unframes a gRPC-Web response body and surfaces its status
+//
+// The status comes from the trailer frame, or from the response headers when the
+// server sent a trailers-only response.
+func applyGrpcWebResponse(response *ProxyResponse, body []byte, header http.Header) {
+	_, text := isGrpcWebContentType(header.Get("Content-Type"))
+	if text {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(body)))
+		if err != nil {
+			response.Error = fmt.Sprintf("Invalid gRPC-Web text body: %v", err)
+			return
+		}
+		body = decoded
+	}
+
+	parsed, err := parseGrpcWebBody(body)
+	if err != nil {
+		response.Error = fmt.Sprintf("Invalid gRPC-Web body: %v", err)
+		return
+	}
+	response.Body = parsed
+
+	status, message := parsed.Trailers["grpc-status"], parsed.Trailers["grpc-message"]
+	if status == "" {
+		status, message = header.Get("Grpc-Status"), header.Get("Grpc-Message")
+	}
+	if code, err := strconv.Atoi(status); err == nil {
+		response.GrpcStatus = &code
+		if decoded, err := url.PathUnescape(message); err == nil {
+			message = decoded
+		}
+		response.GrpcMessage = message
+	}
+}
+
+// recordBodyLength compares the bytes received with the length the server reported
+//
+// SizeBytes is always the number of bytes read. Responses that can't carry a body (HEAD,
+// 204, 304) are never flagged, and transparently decompressed bodies report no length.
+func recordBodyLength(response *ProxyResponse, resp *http.Response, method string, received int) {
+	response.TransferEncoding = resp.TransferEncoding
+	if resp.ContentLength < 0 {
+		return
+	}
+	reported := resp.ContentLength
+	response.ReportedLength = &reported
+
+	if method == http.MethodHead || resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return
+	}
+	if reported != int64(received) {
+		response.ContentLengthMismatch = true
+		slog.Warn("content-length mismatch", "reported", reported, "received", received)
+	}
+}
+
+// proxyTimeout bounds a proxied request, including reading the response body, unless
+// the request or its environment sets a timeout of its own
+const proxyTimeout = 30 * time.Second
+
+// maxTimeoutMs caps request and environment timeouts
+const maxTimeoutMs = 10 * 60 * 1000
+
+// validateTimeoutMs checks a request or environment timeout; 0 means unset
+func validateTimeoutMs(field string, ms int) error {
+	if ms < 0 || ms > maxTimeoutMs {
+		return fmt.Errorf("%s must be between 0 and %d milliseconds, got %d", field, maxTimeoutMs, ms)
+	}
+	return nil
+}
+
+// maxIdleConnsPerHost is how many keep-alive connections are pooled per target host.
+// The net/http default of 2 means concurrent requests to one API (e.g. a collection
+// run) keep opening and closing connections.
+const maxIdleConnsPerHost = 32
+
+// proxyTransport is shared by every outgoing request so connections and TLS sessions
+// are reused. Requests that need their own TLS or proxy settings would need a
+// separate transport.
+var proxyTransport = newProxyTransport()
+
+// proxyClient sends proxied requests over proxyTransport
+var proxyClient = &http.Client{Timeout: proxyTimeout, Transport: proxyTransport}
+
+// HTTP versions a request can ask for with httpVersion
+const (
+	httpVersionAuto  = "auto"  // HTTP/2 when the server offers it over TLS, otherwise HTTP/1.1
+	httpVersionHTTP1 = "http1" // HTTP/1.1 only
+	httpVersionH2    = "h2"    // HTTP/2 only; http:// URLs use h2c with prior knowledge
+)
+
+// protocolTransports holds copies of the proxy transports limited to one HTTP version,
+// keyed by the transport copied. They are made on first use, after startup has applied
+// the target policy to the originals, and kept so their connections are pooled.
+var protocolTransports = struct {
+	sync.Mutex
+	byBase map[*http.Transport]map[string]*http.Transport
+}{byBase: make(map[*http.Transport]map[string]*http.Transport)}
+
+// protocolTransport returns base, or for http1 and h2 its copy that speaks only that version
+func protocolTransport(base *http.Transport, version string) *http.Transport {
+	if version != httpVersionHTTP1 && version != httpVersionH2 {
+		return base
+	}
+	protocolTransports.Lock()
+	defer protocolTransports.Unlock()
+	if transport := protocolTransports.byBase[base][version]; transport != nil {
+		return transport
+	}
+
+	transport := base.Clone()
+	if transport.TLSClientConfig != nil {
+		// Once base has been used this holds its ALPN list, which offers h2 regardless of Protocols
+		transport.TLSClientConfig.NextProtos = nil
+	}
+	transport.Protocols = new(http.Protocols)
+	if version == httpVersionHTTP1 {
+		transport.Protocols.SetHTTP1(true)
+	} else {
+		transport.Protocols.SetHTTP2(true)
+		transport.Protocols.SetUnencryptedHTTP2(true)
+	}
+	if protocolTransports.byBase[base] == nil {
+		protocolTransports.byBase[base] = make(map[string]*http.Transport)
+	}
+	protocolTransports.byBase[base][version] = transport
+	return transport
+}
+
+// uncompressedTransport is proxyTransport without gzip negotiation, for requests with
+// noCompression. Compression is a transport setting, so these need a pool of their own.
+var uncompressedTransport = func() *http.Transport {
+	transport := newProxyTransport()
+	transport.DisableCompression = true
+	return transport
+}()
+
+// newProxyTransport returns a copy of the default transport with a larger idle pool,
+// dialing through the target policy
+func newProxyTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = 100
+	transport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	transport.DialContext = policyDialContext(&net.Dialer{
+		Timeout:        30 * time.Second,
+		KeepAlive:      30 * time.Second,
+		ControlContext: checkDialedAddress,
+	})
+	return transport
+}
+
+// hostOverrideTransport copies base for a single request whose connections to the hosts
+// in overrides go to other addresses; record is told about each one
+//
+// Only the dialed address changes, so TLS still verifies and sends SNI for the URL's host,
+// and the Host header is unchanged. The copy never reuses connections, as a pooled one
+// for the same host may lead to the real address, and it connects directly even when an
+// HTTP proxy is configured, which would otherwise do its own resolving.
+func hostOverrideTransport(base *http.Transport, overrides map[string]string, record func(addr, target string)) *http.Transport {
+	transport := base.Clone()
+	transport.DisableKeepAlives = true
+	transport.Proxy = nil
+	dial := transport.DialContext
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		target, ok := hostOverrideTarget(overrides, addr)
+		if !ok {
+			return dial(ctx, network, addr)
+		}
+		// The policy sees the override's address; the URL's host must pass its deny list too
+		host, _, _ := net.SplitHostPort(addr)
+		if pattern := matchHostPattern(host, proxyTargetPolicy.DenyHosts); pattern != "" {
+			return nil, &blockedTargetError{Target: host, Reason: fmt.Sprintf("is denied (matches %s)", pattern)}
+		}
+		record(addr, target)
+		return dial(ctx, network, target)
+	}
+	return transport
+}
+
+// Error kinds reported in ProxyResponse.ErrorKind
+const (
+	errorKindNone       = "none" // The upstream responded, whatever its status
+	errorKindDNS        = "dns"
+	errorKindTimeout    = "timeout"
+	errorKindConnection = "connection"
+	errorKindTLS        = "tls"
+	errorKindBlocked    = "blocked"     // Refused by this server's target policy; nothing was sent
+	errorKindRateLimit  = "rateLimited" // Refused by this server's rate limiter; nothing was sent
+	errorKindCancelled  = "cancelled"   // Aborted before completing, e.g. the client went away
+)
+
+// classifyRequestError reports why an outgoing request got no (complete) response
+//
+// The client wraps failures in *url.Error; the cause underneath is a resolver error,
+// a timeout, a TLS handshake or certificate failure, or a refused/reset connection.
+// Anything unrecognized counts as a connection failure.
+func classifyRequestError(err error) string {
+	var blockedErr *blockedTargetError
+	if errors.As(err, &blockedErr) {
+		return errorKindBlocked
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, errCancelledByUser) {
+		return errorKindCancelled
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return errorKindDNS
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return errorKindTimeout
+	}
+	var (
+		recordErr    tls.RecordHeaderError
+		alertErr     tls.AlertError
+		verifyErr    *tls.CertificateVerificationError
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+	)
+	if errors.As(err, &recordErr) || errors.As(err, &alertErr) || errors.As(err, &verifyErr) ||
+		errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) {
+		return errorKindTLS
+	}
+	return errorKindConnection
+}
+
+// makeHTTPRequest performs the actual HTTP request to the target API
+//
+// Cancelling ctx (e.g. the client disconnecting) aborts the request; the client's
+// own timeout still applies.
+func makeHTTPRequest(ctx context.Context, req ProxyRequest) ProxyResponse {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Warn("panic in makeHTTPRequest", "panic", r)
+		}
+	}()
+
+	var bodyReader io.Reader
+	var bodyStr string
+	var bodyLength int64
+
+	if req.Headers == nil {
+		req.Headers = make(map[string]string)
+	}
+
+	// Build body based on type
+	if req.BodyType == "json" && len(req.BodyJson) > 0 {
+		// Build JSON from typed fields
+		jsonObj, err := buildJSONFromBodyFields(req.BodyJson)
+		if err != nil {
+			slog.Error("failed to build JSON from body fields", "error", err)
+			return ProxyResponse{
+				Error: fmt.Sprintf("Failed to build JSON body: %v", err),
+			}
+		}
+		jsonBytes, err := json.Marshal(jsonObj)
+		if err != nil {
+			slog.Error("failed to marshal JSON body", "error", err)
+			return ProxyResponse{
+				Error: fmt.Sprintf("Failed to marshal JSON body: %v", err),
+			}
+		}
+		bodyStr = string(jsonBytes)
+		slog.Debug("built JSON body", "fields", len(req.BodyJson), "body", redactSecrets(bodyStr, req.Variables))
+		// Ensure Content-Type if not set
+		setDefaultHeader(req.Headers, "Content-Type", "application/json")
+	} else if req.BodyType == "form" && len(req.BodyForm) > 0 {
+		bodyStr = buildFormEncoded(req.BodyForm)
+		slog.Debug("built form body", "fields", len(req.BodyForm), "body", redactSecrets(bodyStr, req.Variables))
+		// Ensure Content-Type if not set
+		setDefaultHeader(req.Headers, "Content-Type", "application/x-www-form-urlencoded")
+	} else if req.BodyType == "binary" {
+		reader, length, err := openBinaryBody(req)
+		if err != nil {
+			slog.Error("failed to open binary body", "error", err)
+			return ProxyResponse{
+				Error: fmt.Sprintf("Failed to open binary body: %v", err),
+			}
+		}
+		bodyReader = reader
+		bodyLength = length
+		// Ensure Content-Type if not set
+		setDefaultHeader(req.Headers, "Content-Type", "application/octet-stream")
+	} else if req.BodyType == "grpcweb" {
+		framed, err := grpcWebRequestBody(req.BodyBase64)
+		if err != nil {
+			slog.Error("failed to build gRPC-Web body", "error", err)
+			return ProxyResponse{
+				Error: fmt.Sprintf("Failed to build gRPC-Web body: %v", err),
+			}
+		}
+		bodyReader = bytes.NewReader(framed)
+		bodyLength = int64(len(framed))
+		slog.Debug("framed gRPC-Web message", "bytes", len(framed)-5)
+		// Ensure gRPC-Web headers if not set
+		setDefaultHeader(req.Headers, "Content-Type", "application/grpc-web+proto")
+		setDefaultHeader(req.Headers, "X-Grpc-Web", "1")
+	} else if req.BodyType != "json" && req.Body != "" {
+		// Raw text (or pre-encoded form) body sent as-is
+		bodyStr = req.Body
+	}
+
+	if bodyStr != "" {
+		bodyReader = strings.NewReader(bodyStr)
+	}
+
+	// Create HTTP request
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, req.URL, bodyReader)
+	if err != nil {
+		if closer, ok := bodyReader.(io.Closer); ok {
+			closer.Close()
+		}
+		slog.Error("failed to create request", "error", err)
+		return ProxyResponse{
+			Error: fmt.Sprintf("Failed to create request: %v", err),
+		}
+	}
+	if bodyLength > 0 && httpReq.ContentLength == 0 {
+		// Streamed files aren't measured by http.NewRequest
+		httpReq.ContentLength = bodyLength
+	}
+
+	// Add headers in a fixed order, so differently-cased duplicates resolve the same way every
+	// time. A name the header list repeats goes out as one field per entry, in list order,
+	// unless a later step such as auth replaced its value.
+	repeated := repeatedHeaderValues(req.HeaderList)
+	for _, key := range sortedHeaderNames(req.Headers) {
+		value := req.Headers[key]
+		if values := repeated[http.CanonicalHeaderKey(key)]; values != nil && strings.Join(values, ", ") == value {
+			httpReq.Header.Del(key)
+			for _, v := range values {
+				httpReq.Header.Add(key, v)
+			}
+			continue
+		}
+		httpReq.Header.Set(key, value)
+	}
+	if req.NoUserAgent && headerValue(req.Headers, "User-Agent") == "" {
+		// A present but empty User-Agent tells the client to send none
+		httpReq.Header["User-Agent"] = nil
+	}
+
+	// Record every header field the client writes, including Host, Content-Length and
+	// Accept-Encoding, which it adds itself; each redirect hop starts a new list
+	var sentMutex sync.Mutex
+	var sent []HARNameValue
+	var conn *ConnectionInfo
+	var phases phaseTimes
+	// The trace hooks may run on the transport's goroutines
+	mark := func(at *time.Time) {
+		sentMutex.Lock()
+		*at = time.Now()
+		sentMutex.Unlock()
+	}
+	httpReq = httpReq.WithContext(httptrace.WithClientTrace(httpReq.Context(), &httptrace.ClientTrace{
+		GetConn: func(string) {
+			sentMutex.Lock()
+			sent = nil
+			phases = phaseTimes{}
+			sentMutex.Unlock()
+		},
+		DNSStart: func(httptrace.DNSStartInfo) { mark(&phases.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { mark(&phases.dnsDone) },
+		ConnectStart: func(string, string) {
+			// With several addresses, dials may overlap; keep the first start and the last finish
+			sentMutex.Lock()
+			if phases.connectStart.IsZero() {
+				phases.connectStart = time.Now()
+			}
+			sentMutex.Unlock()
+		},
+		ConnectDone:       func(string, string, error) { mark(&phases.connectDone) },
+		TLSHandshakeStart: func() { mark(&phases.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { mark(&phases.tlsDone) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			sentMutex.Lock()
+			conn = &ConnectionInfo{RemoteAddr: info.Conn.RemoteAddr().String(), Reused: info.Reused}
+			phases.reused = info.Reused
+			sentMutex.Unlock()
+		},
+		GotFirstResponseByte: func() { mark(&phases.firstByte) },
+		WroteHeaderField: func(key string, values []string) {
+			sentMutex.Lock()
+			for _, value := range values {
+				sent = append(sent, HARNameValue{Name: key, Value: value})
+			}
+			sentMutex.Unlock()
+		},
+	}))
+	sentHeaders := func() []HARNameValue {
+		sentMutex.Lock()
+		defer sentMutex.Unlock()
+		return sent
+	}
+	var dialed map[string]string
+	recordOverride := func(addr, target string) {
+		sentMutex.Lock()
+		defer sentMutex.Unlock()
+		if dialed == nil {
+			dialed = make(map[string]string)
+		}
+		dialed[addr] = target
+		slog.Info("host override", "addr", addr, "target", target)
+	}
+	hostOverrides := func() map[string]string {
+		sentMutex.Lock()
+		defer sentMutex.Unlock()
+		return dialed
+	}
+	timings := func(start time.Time) *ResponseTimings {
+		sentMutex.Lock()
+		defer sentMutex.Unlock()
+		return phases.timings(start, time.Now())
+	}
+	// The TLS details come from the response, which has the final hop's connection state
+	connection := func(state *tls.ConnectionState) *ConnectionInfo {
+		sentMutex.Lock()
+		defer sentMutex.Unlock()
+		if conn == nil || state == nil {
+			return conn
+		}
+		info := *conn
+		info.TLSVersion = tls.VersionName(state.Version)
+		info.CipherSuite = tls.CipherSuiteName(state.CipherSuite)
+		info.Protocol = state.NegotiatedProtocol
+		info.ServerName = state.ServerName
+		describeCertificates(&info, state.PeerCertificates, time.Now())
+		return &info
+	}
+
+	host := targetHost(req.URL)
+	slog.Debug("proxy request",
+		"method", req.Method,
+		"url", redactSecrets(req.URL, req.Variables),
+		"headers", redactHeaders(req.Headers, req.Variables))
+	start := time.Now()
+	client := proxyClient
+	if req.TimeoutMs > 0 || req.NoCompression || len(req.HostOverrides) > 0 || req.HTTPVersion != "" {
+		custom := *proxyClient
+		if req.TimeoutMs > 0 {
+			custom.Timeout = time.Duration(req.TimeoutMs) * time.Millisecond
+		}
+		transport := proxyTransport
+		if req.NoCompression {
+			transport = uncompressedTransport
+		}
+		transport = protocolTransport(transport, req.HTTPVersion)
+		if len(req.HostOverrides) > 0 {
+			transport = hostOverrideTransport(transport, req.HostOverrides, recordOverride)
+			defer transport.CloseIdleConnections()
+		}
+		custom.Transport = transport
+		client = &custom
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		kind := classifyRequestError(err)
+		slog.Warn("proxy request failed",
+			"method", req.Method,
+			"host", host,
+			"kind", kind,
+			"duration", time.Since(start),
+			"error", redactSecrets(err.Error(), req.Variables))
+		var blockedErr *blockedTargetError
+		if errors.As(err, &blockedErr) {
+			return ProxyResponse{Error: blockedErr.Error(), ErrorKind: kind}
+		}
+		return ProxyResponse{
+			Error:         describeRequestError(ctx, "Request failed", err),
+			ErrorKind:     kind,
+			SentHeaders:   sentHeaders(),
+			HostOverrides: hostOverrides(),
+			Connection:    connection(nil),
+			Timings:       timings(start),
+		}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		// Report how much arrived so truncated responses can be diagnosed
+		slog.Error("failed to read response body", "bytes", len(body), "error", err)
+		response := ProxyResponse{
+			Status:        resp.Status,
+			StatusCode:    resp.StatusCode,
+			Error:         describeRequestError(ctx, "Failed to read response body", err),
+			ErrorKind:     classifyRequestError(err),
+			SizeBytes:     len(body),
+			SentHeaders:   sentHeaders(),
+			HostOverrides: hostOverrides(),
+			Connection:    connection(resp.TLS),
+			Timings:       timings(start),
+			HTTPVersion:   resp.Proto,
+		}
+		recordBodyLength(&response, resp, req.Method, len(body))
+		return response
+	}
+
+	// Convert response headers to map
+	headers := make(map[string]string)
+	for key, values := range resp.Header {
+		if len(values) > 0 {
+			headers[key] = values[0] // Take first value if multiple
+		}
+	}
+
+	duration := time.Since(start)
+	slog.Info("proxy response",
+		"method", req.Method,
+		"host", host,
+		"status", resp.StatusCode,
+		"duration", duration,
+		"bytes", len(body))
+
+	// Parse response body according to the content type the server reported
+	responseBody, parseSkipped := parseResponseBody(body, resp.Header.Get("Content-Type"))
+	if parseSkipped {
+		slog.Info("response body is over the parse limit; returning it as text", "bytes", len(body), "limit", maxParseBytes)
+	}
+
+	response := ProxyResponse{
+		Status:        resp.Status,
+		StatusCode:    resp.StatusCode,
+		Headers:       headers,
+		Body:          responseBody,
+		StartedAt:     start.Format(time.RFC3339Nano),
+		DurationMs:    duration.Milliseconds(),
+		SizeBytes:     len(body),
+		ErrorKind:     errorKindNone,
+		SentHeaders:   sentHeaders(),
+		HostOverrides: hostOverrides(),
+		Connection:    connection(resp.TLS),
+		Timings:       timings(start),
+		HTTPVersion:   resp.Proto,
+	}
+	if parseSkipped {
+		response.BodyParsed = new(bool)
+	}
+	// Only parsed bodies need the original text; a string body already is it
+	if _, isText := responseBody.(string); !isText && len(body) <= maxRawBodyBytes {
+		response.RawBody = string(body)
+	}
+	recordBodyLength(&response, resp, req.Method, len(body))
+	if grpcWeb, _ := isGrpcWebContentType(resp.Header.Get("Content-Type")); grpcWeb {
+		applyGrpcWebResponse(&response, body, resp.Header)
+	}
+	// Link may be sent as several headers, which the headers map collapses to the first
+	response.nextPage = nextPageLink(resp.Header.Values("Link"), resp.Request.URL)
+	return response
+}
+
+// phaseTimes records when each phase of a request started and finished
+type phaseTimes struct {
+	dnsStart, dnsDone         time.Time
+	connectStart, connectDone time.Time
+	tlsStart, tlsDone         time.Time
+	firstByte                 time.Time
+	reused                    bool
+}
+
+// timings converts the recorded times to durations for a request sent at start
+//
+// A reused connection reports no DNS, connect or TLS time. It can still record some,
+// when the transport began dialing and an idle connection freed up first.
+func (p *phaseTimes) timings(start, end time.Time) *ResponseTimings {
+	ms := func(from, to time.Time) float64 {
+		if from.IsZero() || to.IsZero() || to.Before(from) {
+			return 0
+		}
+		return math.Round(float64(to.Sub(from))/float64(time.Millisecond)*100) / 100
+	}
+	t := &ResponseTimings{
+		TTFBMs:  ms(start, p.firstByte),
+		TotalMs: ms(start, end),
+	}
+	if !p.reused {
+		t.DNSMs = ms(p.dnsStart, p.dnsDone)
+		t.ConnectMs = ms(p.connectStart, p.connectDone)
+		t.TLSMs = ms(p.tlsStart, p.tlsDone)
+	}
+	return t
+}
+
+// describeCertificates adds a server's certificate chain to info, with a summary of the
+// certificate that expires first
+func describeCertificates(info *ConnectionInfo, chain []*x509.Certificate, now time.Time) {
+	if len(chain) == 0 {
+		return
+	}
+	soonest := 0
+	for i, cert := range chain {
+		sans := append([]string(nil), cert.DNSNames...)
+		for _, ip := range cert.IPAddresses {
+			sans = append(sans, ip.String())
+		}
+		info.Certificates = append(info.Certificates, CertificateInfo{
+			Subject:         cert.Subject.String(),
+			Issuer:          cert.Issuer.String(),
+			SANs:            sans,
+			NotBefore:       cert.NotBefore.UTC().Format(time.RFC3339),
+			NotAfter:        cert.NotAfter.UTC().Format(time.RFC3339),
+			DaysUntilExpiry: int(math.Floor(cert.NotAfter.Sub(now).Hours() / 24)),
+		})
+		if cert.NotAfter.Before(chain[soonest].NotAfter) {
+			soonest = i
+		}
+	}
+
+	days := info.Certificates[soonest].DaysUntilExpiry
+	info.CertificateExpiresInDays = &days
+	switch {
+	case days == -1:
+		info.CertificateSummary = "expired 1 day ago"
+	case days < 0:
+		info.CertificateSummary = fmt.Sprintf("expired %d days ago", -days)
+	case days == 0:
+		info.CertificateSummary = "expires today"
+	case days == 1:
+		info.CertificateSummary = "expires in 1 day"
+	default:
+		info.CertificateSummary = fmt.Sprintf("expires in %d days", days)
+	}
+	if soonest > 0 {
+		// An intermediate expiring first breaks the chain just the same, so name it
+		info.CertificateSummary += fmt.Sprintf(" (%s)", chain[soonest].Subject.CommonName)
+	}
+}
+
+// Pagination limits for followPagination
+const (
+	defaultMaxPages = 10
+	maxMaxPages     = 100
+)
+
+// nextPageLink returns the absolute target of the rel="next" link in Link header values
+// (RFC 8288), or "" when there is none
+func nextPageLink(values []string, base *url.URL) string {
+	for _, value := range values {
+		for rest := value; ; {
+			start := strings.IndexByte(rest, '<')
+			if start == -1 {
+				break
+			}
+			end := strings.IndexByte(rest[start:], '>')
+			if end == -1 {
+				break
+			}
+			target := rest[start+1 : start+end]
+			rest = rest[start+end+1:]
+
+			// Parameters run up to the next link
+			params := rest
+			if next := strings.IndexByte(rest, '<'); next != -1 {
+				params = rest[:next]
+			}
+			for _, param := range strings.Split(params, ";") {
+				name, val, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if !ok || !strings.EqualFold(strings.TrimSpace(name), "rel") {
+					continue
+				}
+				val = strings.Trim(strings.TrimSpace(val), `", `)
+				for _, rel := range strings.Fields(val) {
+					if strings.EqualFold(rel, "next") {
+						ref, err := url.Parse(target)
+						if err != nil {
+							return ""
+						}
+						return base.ResolveReference(ref).String()
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// followPagination fetches the pages after the first by following rel="next" Link
+// headers, appending each page's JSON array to the first page's body
+//
+// Later pages are GET requests with the same headers. Following stops at the page cap,
+// on a failed or non-2xx page, on a body that isn't a JSON array, on a repeated URL, and
+// on a link to another origin, so credentials are never sent to a different host.
+// Status and headers are the first page's; size and duration cover every page.
+func followPagination(ctx context.Context, req ProxyRequest, response *ProxyResponse) {
+	maxPages := req.MaxPages
+	if maxPages <= 0 {
+		maxPages = defaultMaxPages
+	}
+	maxPages = min(maxPages, maxMaxPages)
+
+	response.Pages = 1
+	items, ok := response.Body.([]any)
+	if response.Error != "" || response.StatusCode < 200 || response.StatusCode > 299 {
+		return
+	}
+	if !ok {
+		if response.nextPage != "" {
+			response.PaginationNote = "response body is not a JSON array, so pages can't be combined"
+		}
+		return
+	}
+
+	origin, err := url.Parse(req.URL)
+	if err != nil {
+		return
+	}
+	headers := make(map[string]string, len(req.Headers))
+	for key, value := range req.Headers {
+		if !strings.EqualFold(key, "Content-Type") && !strings.EqualFold(key, "Content-Length") {
+			headers[key] = value
+		}
+	}
+
+	visited := map[string]bool{req.URL: true}
+	next := response.nextPage
+	for next != "" {
+		if response.Pages >= maxPages {
+			response.PaginationNote = fmt.Sprintf("stopped at the %d page limit", maxPages)
+			break
+		}
+		if visited[next] {
+			response.PaginationNote = fmt.Sprintf("next link repeats an earlier page: %s", next)
+			break
+		}
+		target, err := url.Parse(next)
+		if err != nil || target.Scheme != origin.Scheme || target.Host != origin.Host {
+			response.PaginationNote = fmt.Sprintf("next link points to another origin: %s", next)
+			break
+		}
+		visited[next] = true
+		if wait, scope := proxyRateLimiter.Reserve(next); wait > 0 {
+			response.PaginationNote = fmt.Sprintf("page %d: %s", response.Pages+1, rateLimitMessage(scope, wait))
+			break
+		}
+
+		page := makeHTTPRequest(ctx, ProxyRequest{
+			Method:        http.MethodGet,
+			URL:           next,
+			Headers:       headers,
+			HeaderList:    req.HeaderList,
+			Variables:     req.Variables,
+			HostOverrides: req.HostOverrides,
+			HTTPVersion:   req.HTTPVersion,
+			TimeoutMs:     req.TimeoutMs,
+			NoCompression: req.NoCompression,
+			NoUserAgent:   req.NoUserAgent,
+		})
+		if page.Error != "" {
+			response.PaginationNote = fmt.Sprintf("page %d failed: %s", response.Pages+1, page.Error)
+			break
+		}
+		if page.StatusCode < 200 || page.StatusCode > 299 {
+			response.PaginationNote = fmt.Sprintf("page %d returned %s", response.Pages+1, page.Status)
+			break
+		}
+		pageItems, ok := page.Body.([]any)
+		if !ok {
+			response.PaginationNote = fmt.Sprintf("page %d is not a JSON array", response.Pages+1)
+			break
+		}
+
+		items = append(items, pageItems...)
+		response.Pages++
+		response.SizeBytes += page.SizeBytes
+		response.DurationMs += page.DurationMs
+		next = page.nextPage
+	}
+
+	response.Body = items
+	response.RawBody = "" // The combined array was never sent as one body
+	slog.Info("followed pagination", "pages", response.Pages, "items", len(items))
+}
+
+// =============================================================================
+// RUNNING EXECUTIONS
+// =============================================================================
+
+// executionIDHeader carries the ID of a request sent through /api/proxy. A client may
+// pick the ID itself by sending the header, so it can cancel without waiting for one.
+const executionIDHeader = "X-Execution-Id"
+
+// executionIDPattern limits client-chosen execution IDs to URL-safe tokens
+var executionIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// errCancelledByUser is the cause given to a request aborted through DELETE /api/proxy/{executionId}
+var errCancelledByUser = errors.New("cancelled by user")
+
+// runningExecution is a request being sent through /api/proxy
+type runningExecution struct {
+	ID        string `json:"id"`
+	Method    string `json:"method"`
+	URL       string `json:"url"` // As given, before templates are resolved
+	StartedAt string `json:"startedAt"`
+
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+}
+
+// runningExecutions holds the requests that can still be cancelled, by ID
+var runningExecutions = struct {
+	sync.Mutex
+	byID map[string]*runningExecution
+}{byID: make(map[string]*runningExecution)}
+
+// startExecution registers a request under id, or a new ID if id is empty, and returns
+// it with a context that cancelProxyRequest can abort. It fails if id is already running.
+func startExecution(ctx context.Context, id, method, url string) (*runningExecution, error) {
+	if id == "" {
+		id = generateID()
+	}
+
+	execution := &runningExecution{
+		ID:        id,
+		Method:    method,
+		URL:       url,
+		StartedAt: time.Now().Format(time.RFC3339),
+	}
+	execution.ctx, execution.cancel = context.WithCancelCause(ctx)
+
+	runningExecutions.Lock()
+	defer runningExecutions.Unlock()
+	if _, exists := runningExecutions.byID[id]; exists {
+		execution.cancel(nil)
+		return nil, fmt.Errorf("execution %s is already running", id)
+	}
+	runningExecutions.byID[id] = execution
+	return execution, nil
+}
+
+// finishExecution removes a completed request from the registry
+func finishExecution(execution *runningExecution) {
+	runningExecutions.Lock()
+	delete(runningExecutions.byID, execution.ID)
+	runningExecutions.Unlock()
+	execution.cancel(nil)
+}
+
+// describeRequestError formats a failed request's error, reporting a cancellation by the
+// user as such whether it surfaces as its cause or as a bare "context canceled"
+func describeRequestError(ctx context.Context, prefix string, err error) string {
+	if errors.Is(err, errCancelledByUser) || (errors.Is(err, context.Canceled) && errors.Is(context.Cause(ctx), errCancelledByUser)) {
+		return prefix + ": " + errCancelledByUser.Error()
+	}
+	return fmt.Sprintf("%s: %v", prefix, err)
+}
+
+// runningProxyRequests handles GET requests to list requests still being sent through
+// /api/proxy, oldest first
+func (api *API) runningProxyRequests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	runningExecutions.Lock()
+	executions := make([]runningExecution, 0, len(runningExecutions.byID))
+	for _, execution := range runningExecutions.byID {
+		executions = append(executions, *execution)
+	}
+	runningExecutions.Unlock()
+	sort.Slice(executions, func(i, j int) bool {
+		return executions[i].StartedAt < executions[j].StartedAt
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]any{"executions": executions}); err != nil {
+		slog.Error("failed to encode running executions", "error", err)
+	}
+}
+
+// cancelProxyRequest handles DELETE requests to abort a request sent through /api/proxy
+//
+// The original call then returns with errorKind "cancelled" and the timings collected
+// so far. A request that has already finished is no longer known and gets a 404.
+func (api *API) cancelProxyRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := chi.URLParam(r, "executionId")
+	runningExecutions.Lock()
+	execution := runningExecutions.byID[id]
+	runningExecutions.Unlock()
+	if execution == nil {
+		respondWithError(w, "Execution not found or already finished", http.StatusNotFound)
+		return
+	}
+
+	execution.cancel(errCancelledByUser)
+	slog.Info("cancelled execution", "method", execution.Method, "url", execution.URL, "execution", id)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]any{
+		"cancelled":   true,
+		"executionId": id,
+	}); err != nil {
+		slog.Error("failed to encode cancel response", "error", err)
+	}
+}
+
+// Asynchronous executions started with /api/proxy/async live in memory only
+const (
+	maxAsyncExecutions = 200              // Running and finished, before the oldest finished ones are dropped
+	asyncResultTTL     = 10 * time.Minute // How long a finished execution's response can be polled
+)
+
+// Statuses of an asynchronous execution
+const (
+	asyncStatusPending = "pending" // Accepted, not yet sending
+	asyncStatusRunning = "running"
+	asyncStatusDone    = "done"   // The upstream answered, whatever its status code
+	asyncStatusFailed  = "failed" // No complete response: refused, unreachable, timed out or cancelled
+)
+
+// AsyncExecution is a request sent in the background by /api/proxy/async
+type AsyncExecution struct {
+	ID         string         `json:"id"`
+	Status     string         `json:"status"`
+	Method     string         `json:"method"`
+	URL        string         `json:"url"` // As given, before templates are resolved
+	CreatedAt  string         `json:"createdAt"`
+	FinishedAt string         `json:"finishedAt,omitempty"`
+	ExpiresAt  string         `json:"expiresAt,omitempty"`  // When a finished execution is dropped
+	HTTPStatus int            `json:"httpStatus,omitempty"` // Status /api/proxy would have answered with
+	Response   *ProxyResponse `json:"response,omitempty"`   // Set once finished
+
+	created  time.Time
+	finished time.Time
+}
+
+var (
+	asyncMutex      sync.Mutex
+	asyncExecutions = make(map[string]*AsyncExecution)
+)
+
+// pruneAsyncExecutionsLocked drops finished executions older than asyncResultTTL, then the
+// oldest finished ones while the map is full; the caller holds asyncMutex
+func pruneAsyncExecutionsLocked(now time.Time) {
+	var finished []*AsyncExecution
+	for id, execution := range asyncExecutions {
+		if execution.finished.IsZero() {
+			continue
+		}
+		if now.Sub(execution.finished) > asyncResultTTL {
+			delete(asyncExecutions, id)
+			continue
+		}
+		finished = append(finished, execution)
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].finished.Before(finished[j].finished)
+	})
+	for len(asyncExecutions) >= maxAsyncExecutions && len(finished) > 0 {
+		delete(asyncExecutions, finished[0].ID)
+		finished = finished[1:]
+	}
+}
+
+// proxyAsync handles POST requests to send a request in the background
+//
+// It takes the same body as /api/proxy and answers 202 Accepted at once with the
+// execution ID; GET /api/executions/{id} reports progress and, when finished, the
+// response. Nothing ties the request to this call, so closing the connection doesn't
+// cancel it and an intermediary's timeout can't cut it short.
+func (api *API) proxyAsync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req, data, currentEnv, executionID, ok := api.readProxyCall(w, r)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	asyncMutex.Lock()
+	pruneAsyncExecutionsLocked(now)
+	full := len(asyncExecutions) >= maxAsyncExecutions
+	asyncMutex.Unlock()
+	if full {
+		respondWithError(w, fmt.Sprintf("At most %d asynchronous executions can run at once", maxAsyncExecutions), http.StatusServiceUnavailable)
+		return
+	}
+
+	// The execution outlives this request, so it only ends when cancelled or at shutdown
+	execution, err := startExecution(backgroundCtx, executionID, req.Method, req.URL)
+	if err != nil {
+		respondWithError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	async := &AsyncExecution{
+		ID:        execution.ID,
+		Status:    asyncStatusPending,
+		Method:    req.Method,
+		URL:       req.URL,
+		CreatedAt: now.Format(time.RFC3339),
+		created:   now,
+	}
+	asyncMutex.Lock()
+	asyncExecutions[async.ID] = async
+	asyncMutex.Unlock()
+
+	reveal := revealSecrets(r)
+	goBackground(func() { api.runAsyncExecution(async, execution, req, data, currentEnv, reveal) })
+	slog.Info("started background execution", "method", req.Method, "url", req.URL, "execution", async.ID)
+
+	statusURL := "/api/executions/" + async.ID
+	w.Header().Set(executionIDHeader, async.ID)
+	w.Header().Set("Location", statusURL)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(map[string]any{
+		"executionId": async.ID,
+		"status":      asyncStatusPending,
+		"statusUrl":   statusURL,
+	}); err != nil {
+		slog.Error("failed to encode async execution", "error", err)
+	}
+}
+
+// runAsyncExecution sends an asynchronous execution's request and stores the outcome
+func (api *API) runAsyncExecution(async *AsyncExecution, execution *runningExecution, req ProxyRequest, data *SavedRequestsData, currentEnv *Environment, reveal bool) {
+	response := ProxyResponse{Error: "Internal server error"}
+	status := http.StatusInternalServerError
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Warn("panic in async execution", "execution", async.ID, "panic", r)
+		}
+		finishExecution(execution)
+
+		response.ExecutionID = async.ID
+		now := time.Now()
+		asyncMutex.Lock()
+		async.Status = asyncStatusDone
+		if response.Error != "" {
+			async.Status = asyncStatusFailed
+		}
+		async.HTTPStatus = status
+		async.Response = &response
+		async.finished = now
+		async.FinishedAt = now.Format(time.RFC3339)
+		async.ExpiresAt = now.Add(asyncResultTTL).Format(time.RFC3339)
+		asyncMutex.Unlock()
+	}()
+
+	asyncMutex.Lock()
+	async.Status = asyncStatusRunning
+	asyncMutex.Unlock()
+
+	response, status = api.sendProxyRequest(execution.ctx, req, data, currentEnv, reveal)
+}
+
+// asyncExecution handles GET requests to poll an asynchronous execution and DELETE
+// requests to cancel it
+//
+// Deleting a running execution cancels it; it then finishes as failed with errorKind
+// "cancelled" and can still be polled. Deleting a finished one discards its response.
+func (api *API) asyncExecution(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	asyncMutex.Lock()
+	pruneAsyncExecutionsLocked(time.Now())
+	async, exists := asyncExecutions[id]
+	var snapshot AsyncExecution
+	if exists {
+		snapshot = *async
+		if r.Method == http.MethodDelete && !async.finished.IsZero() {
+			delete(asyncExecutions, id)
+		}
+	}
+	asyncMutex.Unlock()
+	if !exists {
+		respondWithError(w, "Execution not found or expired", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(snapshot); err != nil {
+			slog.Error("failed to encode async execution", "error", err)
+		}
+	case http.MethodDelete:
+		cancelled := false
+		if snapshot.finished.IsZero() {
+			runningExecutions.Lock()
+			execution := runningExecutions.byID[id]
+			runningExecutions.Unlock()
+			if execution != nil {
+				execution.cancel(errCancelledByUser)
+				cancelled = true
+				slog.Info("cancelled execution", "method", execution.Method, "url", execution.URL, "execution", id)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]any{
+			"executionId": id,
+			"cancelled":   cancelled,
+		}); err != nil {
+			slog.Error("failed to encode cancel response", "error", err)
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// =============================================================================
+// RESPONSE TRANSFORMS
+// =============================================================================
+
+// Limits on a transform expression, so a runaway filter can't tie up the server
+const (
+	transformTimeout    = 2 * time.Second
+	maxTransformResults = 10000
+)
+
+// transformBody runs a jq expression against a parsed JSON response body
+//
+// A single result is returned as-is and several results as an array (none gives null).
+// Problems are returned as a message rather than an error, because a bad expression
+// shouldn't fail the request it was attached to.
+func transformBody(expression string, body any) (any, string) {
+	query, err := gojq.Parse(expression)
+	if err != nil {
+		return nil, fmt.Sprintf("Invalid transform expression: %v", err)
+	}
+	code, err := gojq.Compile(query)
+	if err != nil {
+		return nil, fmt.Sprintf("Invalid transform expression: %v", err)
+	}
+	if _, isText := body.(string); isText {
+		return nil, "Transform needs a JSON response body"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), transformTimeout)
+	defer cancel()
+
+	results := []any{}
+	iter := code.RunWithContext(ctx, body)
+	for {
+		value, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, isErr := value.(error); isErr {
+			if ctx.Err() != nil {
+				return nil, fmt.Sprintf("Transform stopped after %v", transformTimeout)
+			}
+			return nil, fmt.Sprintf("Transform failed: %v", err)
+		}
+		if len(results) == maxTransformResults {
+			return nil, fmt.Sprintf("Transform produced more than %d results", maxTransformResults)
+		}
+		results = append(results, value)
+	}
+
+	switch len(results) {
+	case 0:
+		return nil, ""
+	case 1:
+		return results[0], ""
+	default:
+		return results, ""
+	}
+}
+
+// =============================================================================
+// PROXY TARGET POLICY
+// =============================================================================
+
+// TargetPolicy decides which hosts the proxy may connect to. Entries are CIDRs
+// (10.0.0.0/8), single IPs, exact host names (localhost) or subdomain patterns
+// (*.internal). An empty policy allows everything.
+//
+// Host names are matched against the URL before connecting and IPs against the
+// address actually dialed, after DNS resolution, so a name that resolves to a denied
+// address is refused too. Deny entries win. With allow entries, a target must match
+// one of them by name or by resolved address.
+type TargetPolicy struct {
+	AllowNets  []*net.IPNet
+	AllowHosts []string
+	DenyNets   []*net.IPNet
+	DenyHosts  []string
+}
+
+// hardenedDenyList holds the link-local and cloud metadata targets denied by -harden
+var hardenedDenyList = []string{
+	"169.254.0.0/16",           // IPv4 link-local, including 169.254.169.254 metadata
+	"fe80::/10",                // IPv6 link-local
+	"fd00:ec2::254/128",        // AWS IPv6 metadata
+	"100.100.100.200/32",       // Alibaba Cloud metadata
+	"metadata.google.internal", // GCP metadata host name
+	"metadata.goog",
+}
+
+// proxyTargetPolicy is set from the command line at startup
+var proxyTargetPolicy TargetPolicy
+
+// blockedTargetError reports a connection refused by the target policy
+type blockedTargetError struct {
+	Target string
+	Reason string
+}
+
+func (e *blockedTargetError) Error() string {
+	return fmt.Sprintf("blocked by go-rest target policy, not by the target: %s %s", e.Target, e.Reason)
+}
+
+type hostAllowedKey struct{}
+
+// parseTargetPolicy builds a policy from comma-separated allow and deny lists,
+// adding hardenedDenyList when harden is set
+func parseTargetPolicy(allow, deny string, harden bool) (TargetPolicy, error) {
+	var policy TargetPolicy
+	add := func(list string, nets *[]*net.IPNet, hosts *[]string) error {
+		for _, entry := range strings.Split(list, ",") {
+			entry = strings.ToLower(strings.TrimSpace(entry))
+			if entry == "" {
+				continue
+			}
+			if strings.Contains(entry, "/") {
+				_, network, err := net.ParseCIDR(entry)
+				if err != nil {
+					return fmt.Errorf("invalid CIDR %q", entry)
+				}
+				*nets = append(*nets, network)
+				continue
+			}
+			if ip := net.ParseIP(entry); ip != nil {
+				bits := 128
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 32
+				}
+				*nets = append(*nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+			if strings.ContainsAny(strings.TrimPrefix(entry, "*."), "*:[]") {
+				return fmt.Errorf("invalid host pattern %q: use a name, or *. followed by a domain", entry)
+			}
+			*hosts = append(*hosts, entry)
+		}
+		return nil
+	}
+
+	if err := add(allow, &policy.AllowNets, &policy.AllowHosts); err != nil {
+		return policy, fmt.Errorf("-allow: %v", err)
+	}
+	if err := add(deny, &policy.DenyNets, &policy.DenyHosts); err != nil {
+		return policy, fmt.Errorf("-deny: %v", err)
+	}
+	if harden {
+		add(strings.Join(hardenedDenyList, ","), &policy.DenyNets, &policy.DenyHosts)
+	}
+	return policy, nil
+}
+
+// Empty reports whether the policy allows every target
+func (p TargetPolicy) Empty() bool {
+	return len(p.AllowNets)+len(p.AllowHosts)+len(p.DenyNets)+len(p.DenyHosts) == 0
+}
+
+// matchHostPattern returns the first pattern host matches, or "". A pattern is an exact
+// name or *.domain, which covers every subdomain of domain.
+func matchHostPattern(host string, patterns []string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, pattern := range patterns {
+		if domain, ok := strings.CutPrefix(pattern, "*."); ok {
+			if strings.HasSuffix(host, "."+domain) {
+				return pattern
+			}
+		} else if host == pattern {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// matchNetwork returns the first network containing ip, or nil
+func matchNetwork(ip net.IP, networks []*net.IPNet) *net.IPNet {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return network
+		}
+	}
+	return nil
+}
+
+// policyDialContext checks the host name being dialed against the policy before
+// resolving it, then dials through dialer, whose ControlContext checks each address
+func policyDialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		policy := proxyTargetPolicy
+		if policy.Empty() {
+			return dialer.DialContext(ctx, network, addr)
+		}
+
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		if pattern := matchHostPattern(host, policy.DenyHosts); pattern != "" {
+			return nil, &blockedTargetError{Target: host, Reason: fmt.Sprintf("is denied (matches %s)", pattern)}
+		}
+		allowed := len(policy.AllowNets)+len(policy.AllowHosts) == 0 || matchHostPattern(host, policy.AllowHosts) != ""
+		return dialer.DialContext(context.WithValue(ctx, hostAllowedKey{}, allowed), network, addr)
+	}
+}
+
+// checkDialedAddress applies the policy's address rules to a resolved address just
+// before the connection is made
+func checkDialedAddress(ctx context.Context, network, address string, _ syscall.RawConn) error {
+	policy := proxyTargetPolicy
+	if policy.Empty() {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return &blockedTargetError{Target: address, Reason: "is not an IP address"}
+	}
+	if network := matchNetwork(ip, policy.DenyNets); network != nil {
+		return &blockedTargetError{Target: host, Reason: fmt.Sprintf("is denied (in %s)", network)}
+	}
+	if allowed, _ := ctx.Value(hostAllowedKey{}).(bool); !allowed && matchNetwork(ip, policy.AllowNets) == nil {
+		return &blockedTargetError{Target: host, Reason: "is not on the allow list"}
+	}
+	return nil
+}
+
+// =============================================================================
+// RATE LIMITING
+// =============================================================================
+
+// RateLimitSettings configures the token buckets that limit outgoing proxy requests.
+// A rate of 0 means unlimited; a burst of 0 defaults to the rate rounded up.
+type RateLimitSettings struct {
+	GlobalPerSecond  float64 `json:"globalPerSecond"`  // All targets together
+	GlobalBurst      int     `json:"globalBurst"`      // Requests allowed at once before the rate applies
+	PerHostPerSecond float64 `json:"perHostPerSecond"` // Each target host:port separately
+	PerHostBurst     int     `json:"perHostBurst"`
+}
+
+// maxRateLimit bounds configured rates and bursts
+const maxRateLimit = 100000
+
+// maxRateLimitHosts is how many per-host buckets are kept before idle ones are dropped
+const maxRateLimitHosts = 1024
+
+// tokenBucket holds tokens that refill continuously up to a burst size
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// refill adds the tokens earned since the last update
+func (b *tokenBucket) refill(now time.Time, rate float64, burst int) {
+	b.tokens = min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+}
+
+// wait returns how long until the bucket holds a whole token; 0 if it does now
+func (b *tokenBucket) wait(rate float64) time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / rate * float64(time.Second))
+}
+
+// RateLimiter limits outgoing proxy requests globally and per target host. It only
+// guards requests sent to targets, never the local management endpoints.
+type RateLimiter struct {
+	mu       sync.Mutex
+	settings RateLimitSettings
+	global   *tokenBucket
+	hosts    map[string]*tokenBucket
+}
+
+// proxyRateLimiter is configured from the command line and /api/settings/ratelimit
+var proxyRateLimiter = &RateLimiter{hosts: make(map[string]*tokenBucket)}
+
+// validateRateLimit checks ranges and fills in default bursts
+func validateRateLimit(settings RateLimitSettings) (RateLimitSettings, error) {
+	if !(settings.GlobalPerSecond >= 0 && settings.GlobalPerSecond <= maxRateLimit) ||
+		!(settings.PerHostPerSecond >= 0 && settings.PerHostPerSecond <= maxRateLimit) {
+		return settings, fmt.Errorf("rates must be between 0 (unlimited) and %d requests per second", maxRateLimit)
+	}
+	if settings.GlobalBurst < 0 || settings.GlobalBurst > maxRateLimit ||
+		settings.PerHostBurst < 0 || settings.PerHostBurst > maxRateLimit {
+		return settings, fmt.Errorf("bursts must be between 0 (default) and %d", maxRateLimit)
+	}
+	defaultBurst := func(rate float64, burst int) int {
+		if rate == 0 {
+			return 0
+		}
+		if burst == 0 {
+			return max(1, int(math.Ceil(rate)))
+		}
+		return burst
+	}
+	settings.GlobalBurst = defaultBurst(settings.GlobalPerSecond, settings.GlobalBurst)
+	settings.PerHostBurst = defaultBurst(settings.PerHostPerSecond, settings.PerHostBurst)
+	return settings, nil
+}
+
+// parseRateLimitFlags builds the startup limits from the -rate-limit and -rate-limit-host values
+func parseRateLimitFlags(global, perHost string) (RateLimitSettings, error) {
+	var settings RateLimitSettings
+	var err error
+	if settings.GlobalPerSecond, err = strconv.ParseFloat(strings.TrimSpace(global), 64); err != nil {
+		return settings, fmt.Errorf("-rate-limit must be a number of requests per second, got %q", global)
+	}
+	if settings.PerHostPerSecond, err = strconv.ParseFloat(strings.TrimSpace(perHost), 64); err != nil {
+		return settings, fmt.Errorf("-rate-limit-host must be a number of requests per second, got %q", perHost)
+	}
+	if settings, err = validateRateLimit(settings); err != nil {
+		return settings, fmt.Errorf("-rate-limit: %v", err)
+	}
+	return settings, nil
+}
+
+// Configure replaces the limits and refills every bucket
+func (l *RateLimiter) Configure(settings RateLimitSettings) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.settings = settings
+	l.global = nil
+	l.hosts = make(map[string]*tokenBucket)
+}
+
+// Settings returns the current limits
+func (l *RateLimiter) Settings() RateLimitSettings {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.settings
+}
+
+// Reserve takes a token for a request to targetURL from the global bucket and the
+// target host's bucket. If either is empty nothing is taken, and it returns how long
+// until a retry can succeed and which limit ("global" or the host) was hit.
+func (l *RateLimiter) Reserve(targetURL string) (time.Duration, string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	s := l.settings
+	if s.GlobalPerSecond == 0 && s.PerHostPerSecond == 0 {
+		return 0, ""
+	}
+	now := time.Now()
+
+	var wait time.Duration
+	scope := ""
+	if s.GlobalPerSecond > 0 {
+		if l.global == nil {
+			l.global = &tokenBucket{tokens: float64(s.GlobalBurst), last: now}
+		}
+		l.global.refill(now, s.GlobalPerSecond, s.GlobalBurst)
+		if w := l.global.wait(s.GlobalPerSecond); w > 0 {
+			wait, scope = w, "global"
+		}
+	}
+
+	var host *tokenBucket
+	if s.PerHostPerSecond > 0 {
+		key := ""
+		if u, err := url.Parse(targetURL); err == nil {
+			key = strings.ToLower(u.Host)
+		}
+		host = l.hosts[key]
+		if host == nil {
+			if len(l.hosts) >= maxRateLimitHosts {
+				l.dropIdleHosts(now)
+			}
+			host = &tokenBucket{tokens: float64(s.PerHostBurst), last: now}
+			l.hosts[key] = host
+		}
+		host.refill(now, s.PerHostPerSecond, s.PerHostBurst)
+		if w := host.wait(s.PerHostPerSecond); w > wait {
+			wait, scope = w, "host "+key
+		}
+	}
+
+	if wait > 0 {
+		return wait, scope
+	}
+	if l.global != nil && s.GlobalPerSecond > 0 {
+		l.global.tokens--
+	}
+	if host != nil {
+		host.tokens--
+	}
+	return 0, ""
+}
+
+// dropIdleHosts forgets host buckets that have refilled completely, since a new bucket
+// would start in the same state
+func (l *RateLimiter) dropIdleHosts(now time.Time) {
+	for key, bucket := range l.hosts {
+		bucket.refill(now, l.settings.PerHostPerSecond, l.settings.PerHostBurst)
+		if bucket.tokens >= float64(l.settings.PerHostBurst) {
+			delete(l.hosts, key)
+		}
+	}
+}
+
+// rateLimitMessage explains a rate limit refusal
+func rateLimitMessage(scope string, wait time.Duration) string {
+	return fmt.Sprintf("rate limit exceeded by go-rest, not the target (%s); retry in %.1fs", scope, wait.Seconds())
+}
+
+// setRetryAfter sets the Retry-After header, in whole seconds rounded up, for a
+// rate-limited response
+func setRetryAfter(w http.ResponseWriter, wait time.Duration) {
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	}
+}
+
+// rateLimit handles GET requests for the proxy rate limits and POST requests to
+// change them. Changes last until the server restarts.
+func (api *API) rateLimit(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req RateLimitSettings
+		if !decodeJSONRequest(w, r, &req) {
+			return
+		}
+		settings, err := validateRateLimit(req)
+		if err != nil {
+			respondWithError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		proxyRateLimiter.Configure(settings)
+		slog.Info("rate limits set", "globalPerSecond", settings.GlobalPerSecond, "globalBurst", settings.GlobalBurst,
+			"perHostPerSecond", settings.PerHostPerSecond, "perHostBurst", settings.PerHostBurst)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(proxyRateLimiter.Settings()); err != nil {
+		slog.Error("failed to encode rate limit settings", "error", err)
+	}
+}
+
+// =============================================================================
+// DATA PERSISTENCE
+// =============================================================================
+
+const requestsFileName = "saved_requests.json"
+
+// Mutex to prevent concurrent file access
+var fileAccessMutex sync.RWMutex
+
+// uniqueName creates a unique name by appending a counter if needed
+func uniqueName(baseName string, requests []SavedRequest) string {
+	candidateName := baseName
+	counter := 1
+
+	for {
+		isUnique := true
+		for _, req := range requests {
+			if req.Name == candidateName {
+				isUnique = false
+				break
+			}
+		}
+
+		if isUnique {
+			return candidateName
+		}
+
+		counter++
+		candidateName = baseName + " (" + strconv.Itoa(counter) + ")"
+	}
+}
+
+// =============================================================================
+// DATA DIRECTORY LOCK
+// =============================================================================
+
+// dataLockFile sits next to saved_requests.json. The in-process mutexes only order
+// writes within one server, so a second server started in the same directory would
+// interleave its saves with ours; an OS lock on this file keeps it out.
+const dataLockFile = ".go-rest.lock"
+
+// errDataLocked is returned by lockFile when another process holds the lock
+var errDataLocked = errors.New("data directory is in use by another process")
+
+// dataLock keeps the lock file open for the life of the process; the OS releases
+// the lock when the process exits, however it exits
+var dataLock *os.File
+
+// acquireDataLock takes the data directory lock and records this process's PID in
+// the lock file so a second instance can say who holds it
+//
+// The file is never removed: deleting it would let a new process lock a fresh file
+// while an old one still holds the deleted one.
+func acquireDataLock() error {
+	file, err := os.OpenFile(dataLockFile, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", dataLockFile, err)
+	}
+	if err := lockFile(file, false); err != nil {
+		file.Close()
+		if !errors.Is(err, errDataLocked) {
+			return fmt.Errorf("failed to lock %s: %w", dataLockFile, err)
+		}
+		if pid, readErr := os.ReadFile(dataLockFile); readErr == nil && len(bytes.TrimSpace(pid)) > 0 {
+			return fmt.Errorf("%w (PID %s)", err, bytes.TrimSpace(pid))
+		}
+		return err
+	}
+	if err := file.Truncate(0); err == nil {
+		file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	dataLock = file
+	return nil
+}
+
+// The directory lock is only checked at startup. Each load and save also locks the data
+// file's own "<file>.lock" for as long as it reads or writes: shared for loads, exclusive
+// for saves. A read-only server sharing the directory, or any process reaching the file
+// by another path, then never reads a half-written file, and a save's check for edits
+// made since its data was read can't race another process's write.
+const (
+	dataFileLockTimeout  = 5 * time.Second
+	dataFileLockMinDelay = 5 * time.Millisecond
+	dataFileLockMaxDelay = 250 * time.Millisecond
+)
+
+// lockDataFile locks the lock file for the data file at path, retrying with exponential
+// backoff while another process holds it, and returns the function that releases it
+//
+// A reader that can't create the lock file (a read-only mount, say) reads unlocked.
+func lockDataFile(path string, shared bool) (func(), error) {
+	lockPath := path + ".lock"
+	file, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil && shared {
+		if file, err = os.Open(lockPath); err != nil {
+			slog.Debug("storage lock unavailable; reading unlocked", "path", lockPath, "error", err)
+			return func() {}, nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", lockPath, err)
+	}
+
+	deadline := time.Now().Add(dataFileLockTimeout)
+	for delay := dataFileLockMinDelay; ; delay = min(delay*2, dataFileLockMaxDelay) {
+		err := lockFile(file, shared)
+		if err == nil {
+			return func() {
+				unlockFile(file)
+				file.Close()
+			}, nil
+		}
+		if !errors.Is(err, errDataLocked) {
+			file.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", lockPath, err)
+		}
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, fmt.Errorf("%s stayed locked for %v: %w", lockPath, dataFileLockTimeout, err)
+		}
+		time.Sleep(delay)
+	}
+}
+
+// =============================================================================
+// DATA FILE WATCHER
+// =============================================================================
+
+// Every handler reads the data file afresh, so an edit made in a text editor is seen by
+// the next call without reloading anything. The watcher notices such edits so clients
+// can be told to refresh (see the health check), and saves refuse to overwrite an edit
+// made after their data was read.
+
+// dataFileDebounce waits for an editor to finish writing before the file is checked
+const dataFileDebounce = 300 * time.Millisecond
+
+var errExternalEdit = errors.New("the data file was edited outside go-rest after it was read; reload and try again")
+
+// fileStamp identifies a version of a file by modification time and size
+type fileStamp struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+}
+
+func statFileStamp(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{exists: true, modTime: info.ModTime(), size: info.Size()}
+}
+
+func (s fileStamp) same(other fileStamp) bool {
+	return s.exists == other.exists && s.size == other.size && s.modTime.Equal(other.modTime)
+}
+
+// dataFileState tracks who last changed a data file
+type dataFileState struct {
+	ownWrite           fileStamp // Last version written by fileStore
+	lastSeen           fileStamp // Last version the watcher accounted for
+	dirty              bool      // Changed outside go-rest since go-rest last wrote it
+	externalChanges    int
+	lastExternalChange time.Time
+}
+
+var (
+	dataFileStatesMutex sync.Mutex
+	dataFileStates      = make(map[string]*dataFileState)
+)
+
+// dataFileStateLocked returns the state of a data file; the caller holds dataFileStatesMutex
+func dataFileStateLocked(path string) *dataFileState {
+	path = filepath.Clean(path)
+	state, ok := dataFileStates[path]
+	if !ok {
+		state = &dataFileState{}
+		dataFileStates[path] = state
+	}
+	return state
+}
+
+// recordOwnWrite notes the version of a file go-rest just wrote, so the watcher
+// doesn't mistake it for an external edit, and returns it
+func recordOwnWrite(path string) fileStamp {
+	stamp := statFileStamp(path)
+	dataFileStatesMutex.Lock()
+	defer dataFileStatesMutex.Unlock()
+	state := dataFileStateLocked(path)
+	state.ownWrite = stamp
+	state.lastSeen = stamp
+	state.dirty = false
+	return stamp
+}
+
+// isOwnWrite reports whether the file on disk is the version go-rest last wrote
+func isOwnWrite(path string) bool {
+	stamp := statFileStamp(path)
+	dataFileStatesMutex.Lock()
+	defer dataFileStatesMutex.Unlock()
+	return stamp.same(dataFileStateLocked(path).ownWrite)
+}
+
+// dataFileStatus reports whether a data file has external edits go-rest hasn't written
+// over yet, how many were seen, and when the last one was
+func dataFileStatus(path string) (bool, int, time.Time) {
+	dataFileStatesMutex.Lock()
+	defer dataFileStatesMutex.Unlock()
+	state := dataFileStateLocked(path)
+	return state.dirty, state.externalChanges, state.lastExternalChange
+}
+
+// checkDataFileChange runs once writes to the active data file have settled and
+// records the change if go-rest didn't make it
+func checkDataFileChange() {
+	path := activeWorkspace().File
+
+	// Saves hold the write lock, so this never sees a save half-written
+	fileAccessMutex.RLock()
+	defer fileAccessMutex.RUnlock()
+	stamp := statFileStamp(path)
+
+	dataFileStatesMutex.Lock()
+	state := dataFileStateLocked(path)
+	if stamp.same(state.ownWrite) || stamp.same(state.lastSeen) {
+		dataFileStatesMutex.Unlock()
+		return
+	}
+	state.lastSeen = stamp
+	state.dirty = true
+	state.externalChanges++
+	state.lastExternalChange = time.Now()
+	dataFileStatesMutex.Unlock()
+
+	slog.Warn("data file was changed outside go-rest; requests from now on use the edited file", "path", path)
+	if contents, err := os.ReadFile(path); err == nil && len(contents) > 0 && !json.Valid(contents) {
+		slog.Warn("data file is not valid JSON; until it is fixed go-rest reads it as empty, and saving would overwrite it", "path", path)
+	}
+}
+
+// watchDataFiles watches the directories holding workspace data files until ctx ends
+//
+// Directories are watched rather than files because editors often save by writing a
+// new file and renaming it over the old one, which would end a watch on the file.
+func watchDataFiles(ctx context.Context) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		slog.Warn("not watching the data file for external edits", "error", err)
+		return
+	}
+	for _, dir := range []string{".", workspacesDir} {
+		if err := watcher.Add(dir); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("not watching for external edits", "dir", dir, "error", err)
+		}
+	}
+
+	goBackground(func() {
+		defer watcher.Close()
+		var debounce *time.Timer
+		for {
+			select {
+			case <-ctx.Done():
+				if debounce != nil {
+					debounce.Stop()
+				}
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				name := filepath.Clean(event.Name)
+				if name == workspacesDir && event.Has(fsnotify.Create) {
+					watcher.Add(workspacesDir)
+				}
+				if name != filepath.Clean(activeWorkspace().File) {
+					continue
+				}
+				if debounce == nil {
+					debounce = time.AfterFunc(dataFileDebounce, checkDataFileChange)
+				} else {
+					debounce.Reset(dataFileDebounce)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				slog.Warn("data file watcher", "error", err)
+			}
+		}
+	})
+}
+
+// =============================================================================
+// TEMPLATE PROCESSING & VARIABLE SUBSTITUTION
+// =============================================================================
+
+// RespVarRef represents a parsed response variable reference like {{"RequestName".field}}
+type RespVarRef struct {
+	RequestName string
+	RequestID   string // Set instead of RequestName for ID-based references like {{#id.field}}
+	Source      string // Part of the stored response to read: "body", "headers", "status" or "statusCode"
+	FieldPath   string
+	IsResponse  bool // true if referencing full response, false if specific field
+}
+
+// newRespVarRef splits a field path into a source selector and the remaining path
+//
+// "headers.Location" reads a response header, "status" and "statusCode" read the
+// response status, and an explicit "body." prefix reaches body fields that would
+// otherwise collide with those selectors. Anything else is a body field path.
+func newRespVarRef(requestName, requestID, fieldPath string) *RespVarRef {
+	ref := &RespVarRef{
+		RequestName: requestName,
+		RequestID:   requestID,
+		Source:      "body",
+		FieldPath:   fieldPath,
+	}
+
+	switch {
+	case fieldPath == "status" || fieldPath == "statusCode":
+		ref.Source = fieldPath
+	case strings.HasPrefix(fieldPath, "headers."):
+		ref.Source = "headers"
+		ref.FieldPath = strings.TrimPrefix(fieldPath, "headers.")
+	case strings.HasPrefix(fieldPath, "body."):
+		ref.FieldPath = strings.TrimPrefix(fieldPath, "body.")
+	}
+
+	ref.IsResponse = ref.Source == "body" && ref.FieldPath == "response"
+	return ref
+}
+
+// idRefPattern matches the content of ID-based references: #a1b2c3d4.field or id:a1b2c3d4.field
+var idRefPattern = regexp.MustCompile(`^(?:#|id:)([A-Za-z0-9_-]+)\.(.+)$`)
+
+// isIDReference reports whether template content addresses a request by ID
+func isIDReference(content string) bool {
+	return strings.HasPrefix(content, "#") || strings.HasPrefix(content, "id:")
+}
+
+// parseVariable parses response variable syntax like {{"RequestName".field}} or {{\"RequestName\".field}}
+// as well as the ID-based forms {{#requestId.field}} and {{id:requestId.field}}
+func parseVariable(variable string) (*RespVarRef, error) {
+	// Remove outer {{ and }}
+	if !strings.HasPrefix(variable, "{{") || !strings.HasSuffix(variable, "}}") {
+		return nil, fmt.Errorf("invalid variable format")
+	}
+
+	content := strings.TrimSpace(variable[2 : len(variable)-2])
+	slog.Debug("parsing response variable", "content", content)
+
+	// ID-based references survive request renames
+	if isIDReference(content) {
+		parts := idRefPattern.FindStringSubmatch(content)
+		if parts == nil {
+			return nil, fmt.Errorf("invalid request ID reference")
+		}
+		return newRespVarRef("", parts[1], parts[2]), nil
+	}
+
+	// Handle escaped quotes: {{\"RequestName\".field}} or {{"RequestName".field}}
+	var startQuote string
+	if strings.HasPrefix(content, "\\\"") {
+		startQuote = "\\\""
+	} else if strings.HasPrefix(content, "\"") {
+		startQuote = "\""
+	} else {
+		return nil, fmt.Errorf("not a response variable - doesn't start with quote")
+	}
+
+	// Extract request name and field path
+	var requestName, fieldPath string
+
+	if startQuote == "\\\"" {
+		// Handle escaped quotes: {{\"RequestName\".field}}
+		// Find the closing \"
+		endIndex := strings.Index(content[2:], "\\\".") // Skip the opening \"
+		if endIndex == -1 {
+			return nil, fmt.Errorf("unclosed escaped quote or missing field separator")
+		}
+		requestName = content[2 : endIndex+2] // Extract name between \"...\"
+		remaining := content[endIndex+4:]     // Skip past \"."
+		fieldPath = remaining
+	} else {
+		// Handle regular quotes: {{"RequestName".field}}
+		// Find the closing quote
+		endIndex := strings.Index(content[1:], "\".") // Skip the opening "
+		if endIndex == -1 {
+			return nil, fmt.Errorf("unclosed quote or missing field separator")
+		}
+		requestName = content[1 : endIndex+1] // Extract name between "..."
+		remaining := content[endIndex+3:]     // Skip past "."
+		fieldPath = remaining
+	}
+
+	slog.Debug("extracted response variable", "request", requestName, "field", fieldPath)
+
+	if requestName == "" {
+		return nil, fmt.Errorf("empty request name")
+	}
+	if fieldPath == "" {
+		return nil, fmt.Errorf("empty field path")
+	}
+
+	return newRespVarRef(requestName, "", fieldPath), nil
+}
+
+// JSONFieldResult represents the result of extracting a JSON field
+type JSONFieldResult struct {
+	Value    string
+	IsObject bool // true if the extracted value is a JSON object/array
+}
+
+// extractJSONField extracts a field from JSON data using dot notation (e.g., "user.profile.email")
+func extractJSONField(data any, fieldPath string) (*JSONFieldResult, error) {
+	if data == nil {
+		return &JSONFieldResult{Value: "", IsObject: false}, nil
+	}
+
+	// If requesting full response, convert to string
+	if fieldPath == "response" {
+		if str, ok := data.(string); ok {
+			return &JSONFieldResult{Value: str, IsObject: false}, nil
+		}
+		// Convert JSON to string
+		jsonBytes, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		return &JSONFieldResult{Value: string(jsonBytes), IsObject: true}, nil
+	}
+
+	// For other fields, navigate the JSON structure
+	current := data
+	parts := strings.Split(fieldPath, ".")
+
+	for _, part := range parts {
+		if part == "" {
+			continue
+		}
+
+		switch v := current.(type) {
+		case map[string]any:
+			if val, exists := v[part]; exists {
+				current = val
+			} else {
+				return &JSONFieldResult{Value: "", IsObject: false}, nil // Field doesn't exist, return empty string
+			}
+		default:
+			return &JSONFieldResult{Value: "", IsObject: false}, nil // Can't traverse further, return empty string
+		}
+	}
+
+	// Convert final value to string and determine if it's a JSON object
+	switch v := current.(type) {
+	case string:
+		return &JSONFieldResult{Value: v, IsObject: false}, nil
+	case nil:
+		return &JSONFieldResult{Value: "", IsObject: false}, nil
+	case map[string]any, []any:
+		// This is a JSON object or array
+		jsonBytes, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return &JSONFieldResult{Value: string(jsonBytes), IsObject: true}, nil
+	default:
+		// Convert to JSON string for non-string primitive types (numbers, booleans, etc.)
+		jsonBytes, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return &JSONFieldResult{Value: string(jsonBytes), IsObject: false}, nil
+	}
+}
+
+// extractResponseField reads the value a reference selects from a stored response
+func extractResponseField(resp *ProxyResponse, ref *RespVarRef) (*JSONFieldResult, error) {
+	switch ref.Source {
+	case "status":
+		return &JSONFieldResult{Value: resp.Status}, nil
+	case "statusCode":
+		return &JSONFieldResult{Value: strconv.Itoa(resp.StatusCode)}, nil
+	case "headers":
+		// Header names are case-insensitive
+		for key, value := range resp.Headers {
+			if strings.EqualFold(key, ref.FieldPath) {
+				return &JSONFieldResult{Value: value}, nil
+			}
+		}
+		return &JSONFieldResult{Value: ""}, nil
+	default:
+		// Form-encoded bodies are stored as text; decode them so fields can be referenced
+		if text, ok := resp.Body.(string); ok && isFormContentType(headerValue(resp.Headers, "Content-Type")) {
+			form, err := parseFormBody(text)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse form-encoded response: %v", err)
+			}
+			return extractJSONField(form, ref.FieldPath)
+		}
+		if ref.FieldPath == "response" && resp.RawBody != "" {
+			return &JSONFieldResult{Value: resp.RawBody, IsObject: true}, nil
+		}
+		return extractJSONField(preciseBody(resp), ref.FieldPath)
+	}
+}
+
+// loadRequest loads a saved request by name from the API's store
+func (api *API) loadRequest(requestName string) (*SavedRequest, error) {
+	requests, err := api.store.ListRequests()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, request := range requests {
+		if request.Name == requestName {
+			withReadOnlyResponse(&request)
+			return &request, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", errRequestNotFound, requestName)
+}
+
+// loadRequestByID loads a saved request by its stable ID from the API's store
+func (api *API) loadRequestByID(requestID string) (*SavedRequest, error) {
+	request, err := api.store.GetRequest(requestID)
+	if err != nil {
+		return nil, err
+	}
+	withReadOnlyResponse(request)
+	return request, nil
+}
+
+// resolveEnvVar resolves environment variable references (values starting with $)
+func resolveEnvVar(value string) string {
+	if strings.HasPrefix(value, "$") {
+		envVarName := value[1:] // Remove the $ prefix
+		if envValue := os.Getenv(envVarName); envValue != "" {
+			return envValue
+		}
+		// If environment variable is not set, return the original value
+		return value
+	}
+	return value
+}
+
+// dynamicVarPattern matches dynamic template functions like {{$uuid}}, {{$uuid:alias}} or {{$randomInt 1 10}}
+var dynamicVarPattern = regexp.MustCompile(`\{\{\s*\$(uuid|timestamp|isoTimestamp|randomInt)(?::([A-Za-z0-9_-]+))?((?:\s+-?\d+)*)\s*\}\}`)
+
+// generateUUID creates a random RFC 4122 version 4 UUID
+func generateUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40 // Version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // Variant 10
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// randomIntInRange returns a random integer in [min, max] inclusive
+//
+// The size of the range is computed with big.Int, since max-min+1 overflows int64 for
+// ranges wider than half of it.
+func randomIntInRange(min, max int64) (int64, error) {
+	if max < min {
+		min, max = max, min
+	}
+	size := new(big.Int).Sub(big.NewInt(max), big.NewInt(min))
+	size.Add(size, big.NewInt(1))
+	n, err := rand.Int(rand.Reader, size)
+	if err != nil {
+		return 0, err
+	}
+	return n.Add(n, big.NewInt(min)).Int64(), nil
+}
+
+// evalDynamicVariable produces a fresh value for a dynamic template function
+func evalDynamicVariable(name string, args []string) (string, error) {
+	switch name {
+	case "uuid":
+		return generateUUID(), nil
+	case "timestamp":
+		return strconv.FormatInt(time.Now().Unix(), 10), nil
+	case "isoTimestamp":
+		return time.Now().UTC().Format(time.RFC3339), nil
+	case "randomInt":
+		// Defaults match Postman's $randomInt range
+		min, max := int64(0), int64(1000)
+		switch len(args) {
+		case 0:
+		case 2:
+			var err error
+			if min, err = strconv.ParseInt(args[0], 10, 64); err != nil {
+				return "", fmt.Errorf("$randomInt: invalid minimum %q", args[0])
+			}
+			if max, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+				return "", fmt.Errorf("$randomInt: invalid maximum %q", args[1])
+			}
+		default:
+			return "", fmt.Errorf("$randomInt takes a minimum and a maximum, got %d arguments", len(args))
+		}
+		n, err := randomIntInRange(min, max)
+		if err != nil {
+			return "", fmt.Errorf("$randomInt: %v", err)
+		}
+		return strconv.FormatInt(n, 10), nil
+	}
+	return "", fmt.Errorf("unknown dynamic variable $%s", name)
+}
+
+// processDynamicVariables substitutes dynamic template functions with fresh values
+//
+// Every occurrence gets its own value unless written with an alias ({{$uuid:id}}),
+// in which case the first generated value is reused for that alias via the aliases map.
+// Invalid arguments, like a $randomInt bound that doesn't fit in 64 bits, are an error.
+func processDynamicVariables(input string, aliases map[string]string) (string, error) {
+	if !strings.Contains(input, "{{") {
+		return input, nil
+	}
+
+	var firstErr error
+	result := dynamicVarPattern.ReplaceAllStringFunc(input, func(match string) string {
+		parts := dynamicVarPattern.FindStringSubmatch(match)
+		name, alias, args := parts[1], parts[2], strings.Fields(parts[3])
+
+		aliasKey := name + ":" + alias
+		if alias != "" {
+			if value, exists := aliases[aliasKey]; exists {
+				return value
+			}
+		}
+		value, err := evalDynamicVariable(name, args)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return match
+		}
+		if alias != "" {
+			aliases[aliasKey] = value
+		}
+		return value
+	})
+	return result, firstErr
+}
+
+// responseVarPattern matches any {{ }} placeholder
+var responseVarPattern = regexp.MustCompile(`\{\{[^}]*\}\}`)
+
+// findResponseMatches returns the response variable placeholders in input
+func findResponseMatches(input string) []string {
+	var responseMatches []string
+	for _, match := range responseVarPattern.FindAllString(input, -1) {
+		content := strings.TrimSpace(match[2 : len(match)-2])
+		if strings.Contains(match, "\"") || strings.Contains(match, "\\\"") || isIDReference(content) {
+			responseMatches = append(responseMatches, match)
+			slog.Debug("processing response variable", "match", match)
+		}
+	}
+	return responseMatches
+}
+
+// maxVariableDepth bounds how deeply variable values may reference other variables
+const maxVariableDepth = 10
+
+// variableRefPattern matches plain variable placeholders like {{host}}
+var variableRefPattern = regexp.MustCompile(`\{\{([^{}"|$#]+)\}\}`)
+
+// resolveNestedVariables expands variables whose values reference other variables
+//
+// A value like "https://{{host}}:{{port}}" is resolved recursively (up to
+// maxVariableDepth levels) regardless of the order of the slice. Response variables
+// inside values are resolved in the same pass. Cycles are reported as an error
+// naming the chain, e.g. "cycle: a → b → a".
+func (api *API) resolveNestedVariables(variables []Variable) ([]Variable, error) {
+	raw := make(map[string]string, len(variables))
+	for _, variable := range variables {
+		if _, exists := raw[variable.Key]; !exists && variable.Key != "" {
+			raw[variable.Key] = variable.Value
+		}
+	}
+
+	resolved := make(map[string]string, len(raw))
+	var resolve func(key string, chain []string) (string, error)
+	resolve = func(key string, chain []string) (string, error) {
+		if value, done := resolved[key]; done {
+			return value, nil
+		}
+		for i, seen := range chain {
+			if seen == key {
+				return "", fmt.Errorf("cycle: %s", strings.Join(append(chain[i:], key), " → "))
+			}
+		}
+		if len(chain) >= maxVariableDepth {
+			return "", fmt.Errorf("variable nesting deeper than %d levels at %s", maxVariableDepth, key)
+		}
+
+		value := resolveEnvVar(raw[key])
+		if strings.Contains(value, "{{") {
+			var err error
+			if value, err = api.processSubstitution(value, findResponseMatches(value), nil); err != nil {
+				return "", err
+			}
+
+			chain = append(chain, key)
+			for _, ref := range variableRefPattern.FindAllStringSubmatch(value, -1) {
+				name := ref[1]
+				if _, defined := raw[name]; !defined {
+					continue
+				}
+				nested, err := resolve(name, chain)
+				if err != nil {
+					return "", err
+				}
+				value = strings.ReplaceAll(value, ref[0], nested)
+			}
+		}
+
+		resolved[key] = value
+		return value, nil
+	}
+
+	result := make([]Variable, len(variables))
+	for i, variable := range variables {
+		result[i] = variable
+		if variable.Key == "" || !strings.Contains(raw[variable.Key], "{{") {
+			continue
+		}
+		value, err := resolve(variable.Key, nil)
+		if err != nil {
+			return nil, err
+		}
+		result[i].Value = value
+	}
+
+	return result, nil
+}
+
+// processTemplate applies variable substitution to a string
+// Handles both response variables like {{"RequestName".field}} and environment variables like {{varName}}
+func (api *API) processTemplate(input string, variables []Variable) (string, error) {
+	if input == "" {
+		return input, nil
+	}
+
+	// Dynamic functions are evaluated first so variables can't shadow them
+	result, err := processDynamicVariables(input, make(map[string]string))
+	if err != nil {
+		return input, err
+	}
+
+	// Process response variables with JSON-aware substitution
+	result, err = api.processSubstitution(result, findResponseMatches(result), nil)
+	if err != nil {
+		return input, err
+	}
+
+	// Process regular environment variables
+	for _, variable := range variables {
+		if variable.Key != "" {
+			resolvedValue := resolveEnvVar(variable.Value)
+			placeholder := fmt.Sprintf("{{%s}}", variable.Key)
+			result = strings.ReplaceAll(result, placeholder, resolvedValue)
+		}
+	}
+
+	// Fall back to defaults and apply modifiers for placeholders like {{name|default}}
+	result = applyPipes(result, variables)
+
+	return result, nil
+}
+
+// lookupVariable returns the resolved value of the first variable with the given key
+//
+// A $ENV reference whose OS variable is unset counts as missing.
+func lookupVariable(key string, variables []Variable) (string, bool) {
+	for _, variable := range variables {
+		if variable.Key != key {
+			continue
+		}
+		resolved := resolveEnvVar(variable.Value)
+		if strings.HasPrefix(variable.Value, "$") && resolved == variable.Value {
+			return "", false
+		}
+		return resolved, true
+	}
+	return "", false
+}
+
+// findPlaceholderEnd returns the index just past the "}}" closing the placeholder that
+// starts at start, honoring nested placeholders and double-quoted text, or -1 if unclosed
+func findPlaceholderEnd(input string, start int) int {
+	depth := 0
+	inQuote := false
+	for i := start; i < len(input); {
+		switch {
+		case inQuote:
+			if input[i] == '"' {
+				inQuote = false
+			}
+			i++
+		case strings.HasPrefix(input[i:], "{{"):
+			depth++
+			i += 2
+		case strings.HasPrefix(input[i:], "}}"):
+			depth--
+			i += 2
+			if depth == 0 {
+				return i
+			}
+		default:
+			if input[i] == '"' {
+				inQuote = true
+			}
+			i++
+		}
+	}
+	return -1
+}
+
+// templateModifiers transform a substituted value, e.g. {{name | urlencode}}
+//
+// "raw" leaves the value untouched and opts out of automatic query-string encoding.
+var templateModifiers = map[string]func(string) string{
+	"urlencode": encodeURIComponent,
+	"base64":    func(v string) string { return base64.StdEncoding.EncodeToString([]byte(v)) },
+	"raw":       func(v string) string { return v },
+}
+
+// encodeURIComponent percent-encodes a value so it is safe in a path segment or query string
+func encodeURIComponent(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
+
+// splitPipes splits placeholder content at every pipe outside quotes and nested placeholders
+func splitPipes(content string) []string {
+	var parts []string
+	depth := 0
+	inQuote := false
+	last := 0
+	for i := 0; i < len(content); i++ {
+		switch {
+		case content[i] == '"':
+			inQuote = !inQuote
+		case inQuote:
+		case strings.HasPrefix(content[i:], "{{"):
+			depth++
+			i++
+		case strings.HasPrefix(content[i:], "}}"):
+			depth--
+			i++
+		case content[i] == '|' && depth == 0:
+			parts = append(parts, strings.TrimSpace(content[last:i]))
+			last = i + 1
+		}
+	}
+	return append(parts, strings.TrimSpace(content[last:]))
+}
+
+// parsePipes splits placeholder content into a variable name, an optional default, and
+// the modifiers to apply in order
+//
+// Segments naming a known modifier are modifiers; the first other segment is the
+// default. Quote a default that happens to match a modifier name ({{mode|"raw"}}).
+func parsePipes(content string) (name, defaultValue string, hasDefault bool, modifiers []string, ok bool) {
+	parts := splitPipes(content)
+	if len(parts) < 2 {
+		return "", "", false, nil, false
+	}
+
+	for _, part := range parts[1:] {
+		if _, isModifier := templateModifiers[part]; isModifier {
+			modifiers = append(modifiers, part)
+			continue
+		}
+		if hasDefault {
+			return "", "", false, nil, false
+		}
+		defaultValue, hasDefault = part, true
+	}
+	return parts[0], defaultValue, hasDefault, modifiers, true
+}
+
+// hasExplicitModifier reports whether a placeholder names a modifier
+func hasExplicitModifier(placeholder string) bool {
+	_, _, _, modifiers, ok := parsePipes(placeholder[2 : len(placeholder)-2])
+	return ok && len(modifiers) > 0
+}
+
+// applyPipes resolves {{name|default}} and {{name | modifier}} placeholders
+//
+// The variable's value is used when it is defined and non-empty, otherwise the default.
+// Defaults may be double-quoted to contain pipes ({{sep|"a|b"}}) and may contain a nested
+// variable ({{host|{{fallbackHost}}}}), which is resolved one level deep. Modifiers are
+// applied to whichever value was chosen; a placeholder with neither a value nor a
+// default is left in place.
+func applyPipes(input string, variables []Variable) string {
+	if !strings.Contains(input, "|") {
+		return input
+	}
+
+	var sb strings.Builder
+	i := 0
+	for {
+		start := strings.Index(input[i:], "{{")
+		if start == -1 {
+			sb.WriteString(input[i:])
+			break
+		}
+		start += i
+		end := findPlaceholderEnd(input, start)
+		if end == -1 {
+			sb.WriteString(input[i:])
+			break
+		}
+
+		sb.WriteString(input[i:start])
+		placeholder := input[start:end]
+		name, defaultValue, hasDefault, modifiers, ok := parsePipes(placeholder[2 : len(placeholder)-2])
+		if !ok || name == "" || strings.ContainsAny(name, "\"{}") {
+			sb.WriteString(placeholder)
+			i = end
+			continue
+		}
+
+		value, found := lookupVariable(name, variables)
+		if !found || value == "" {
+			if !hasDefault {
+				sb.WriteString(placeholder)
+				i = end
+				continue
+			}
+			if len(defaultValue) >= 2 && strings.HasPrefix(defaultValue, "\"") && strings.HasSuffix(defaultValue, "\"") {
+				defaultValue = defaultValue[1 : len(defaultValue)-1]
+			}
+			// Resolve a nested variable in the default one level deep
+			for _, variable := range variables {
+				if variable.Key != "" {
+					defaultValue = strings.ReplaceAll(defaultValue, "{{"+variable.Key+"}}", resolveEnvVar(variable.Value))
+				}
+			}
+			value = defaultValue
+		}
+
+		for _, modifier := range modifiers {
+			value = templateModifiers[modifier](value)
+		}
+		sb.WriteString(value)
+		i = end
+	}
+
+	return sb.String()
+}
+
+// indexOutsidePlaceholders returns the index of the first sep that isn't inside a
+// {{...}} placeholder, or -1
+func indexOutsidePlaceholders(input string, sep byte) int {
+	for i := 0; i < len(input); i++ {
+		if strings.HasPrefix(input[i:], "{{") {
+			end := findPlaceholderEnd(input, i)
+			if end == -1 {
+				return -1
+			}
+			i = end - 1
+			continue
+		}
+		if input[i] == sep {
+			return i
+		}
+	}
+	return -1
+}
+
+// processURL substitutes variables in a URL, percent-encoding values substituted into
+// the query string
+//
+// Only substituted values are encoded; literal text typed in the URL is left as-is.
+// Placeholders with an explicit modifier ({{q | raw}}, {{q | base64}}) are not
+// encoded automatically.
+func (api *API) processURL(rawURL string, variables []Variable, aliases map[string]string) (string, error) {
+	rawURL, err := processDynamicVariables(rawURL, aliases)
+	if err != nil {
+		return rawURL, err
+	}
+
+	queryStart := indexOutsidePlaceholders(rawURL, '?')
+	if queryStart == -1 {
+		return api.processTemplate(rawURL, variables)
+	}
+
+	base, err := api.processTemplate(rawURL[:queryStart], variables)
+	if err != nil {
+		return rawURL, err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(base)
+	query := rawURL[queryStart:]
+	i := 0
+	for {
+		start := strings.Index(query[i:], "{{")
+		if start == -1 {
+			sb.WriteString(query[i:])
+			break
+		}
+		start += i
+		end := findPlaceholderEnd(query, start)
+		if end == -1 {
+			sb.WriteString(query[i:])
+			break
+		}
+
+		sb.WriteString(query[i:start])
+		placeholder := query[start:end]
+		value, err := api.processTemplate(placeholder, variables)
+		if err != nil {
+			return rawURL, err
+		}
+		if value != placeholder && !hasExplicitModifier(placeholder) {
+			value = encodeURIComponent(value)
+		}
+		sb.WriteString(value)
+		i = end
+	}
+
+	return sb.String(), nil
+}
+
+// processSubstitution performs JSON-aware substitution for response variables
+//
+// A substituted value that itself holds response variables is resolved in turn, with
+// chain naming the requests already being resolved. A request reached again is reported
+// as an error naming the chain, e.g. "cycle: A → B → A", as is nesting deeper than
+// maxVariableDepth levels.
+func (api *API) processSubstitution(input string, responseMatches []string, chain []string) (string, error) {
+	result := input
+
+	for _, match := range responseMatches {
+		ref, err := parseVariable(match)
+		if err != nil {
+			continue
+		}
+
+		var request *SavedRequest
+		if ref.RequestID != "" {
+			request, err = api.loadRequestByID(ref.RequestID)
+		} else {
+			request, err = api.loadRequest(ref.RequestName)
+		}
+		if err != nil {
+			continue
+		}
+
+		if request.LastResponse == nil {
+			continue
+		}
+
+		for i, seen := range chain {
+			if seen == request.Name {
+				return input, fmt.Errorf("cycle: %s", strings.Join(append(chain[i:len(chain):len(chain)], request.Name), " → "))
+			}
+		}
+		if len(chain) >= maxVariableDepth {
+			return input, fmt.Errorf("response variables nested deeper than %d levels at %s", maxVariableDepth, request.Name)
+		}
+
+		fieldResult, err := extractResponseField(request.LastResponse, ref)
+		if err != nil {
+			continue
+		}
+
+		value := fieldResult.Value
+		if nested := findResponseMatches(value); len(nested) > 0 {
+			if value, err = api.processSubstitution(value, nested, append(chain[:len(chain):len(chain)], request.Name)); err != nil {
+				return input, err
+			}
+		}
+
+		if fieldResult.IsObject {
+			// For JSON objects, perform JSON-aware substitution
+			result = subJSONObject(result, match, value)
+		} else {
+			// For primitive values, use simple string replacement
+			result = strings.ReplaceAll(result, match, value)
+		}
+	}
+
+	return result, nil
+}
+
+// subJSONObject performs JSON-aware substitution of objects
+func subJSONObject(input, placeholder, jsonValue string) string {
+	// Check if the placeholder is within a JSON context (surrounded by quotes)
+	quotedPlaceholder := "\"" + placeholder + "\""
+
+	if strings.Contains(input, quotedPlaceholder) {
+		// The placeholder is quoted (e.g., "{{test.address}}"),
+		// replace the entire quoted placeholder with the raw JSON
+		return strings.ReplaceAll(input, quotedPlaceholder, jsonValue)
+	} else {
+		// The placeholder is not quoted, treat as regular string replacement
+		return strings.ReplaceAll(input, placeholder, jsonValue)
+	}
+}
+
+// processTemplates applies variable substitution to all templated fields in a request
+//
+// An error is returned when the variables themselves can't be resolved (e.g. a cycle).
+func (api *API) processTemplates(req ProxyRequest) (ProxyRequest, error) {
+	// Expand variables that reference other variables before substituting
+	variables, err := api.resolveNestedVariables(req.Variables)
+	if err != nil {
+		return req, err
+	}
+	req.Variables = variables
+
+	// Aliased dynamic values ({{$uuid:name}}) are shared across every field of the request
+	dynamicAliases := make(map[string]string)
+
+	// Helper function to safely process a template field; the first error is returned
+	// once every field has been processed
+	var templateErr error
+	processField := func(fieldName, value string) string {
+		value, err := processDynamicVariables(value, dynamicAliases)
+		if err == nil {
+			var processed string
+			if processed, err = api.processTemplate(value, req.Variables); err == nil {
+				return processed
+			}
+		}
+		slog.Warn("template error", "field", fieldName, "error", err)
+		if templateErr == nil {
+			templateErr = fmt.Errorf("%s: %v", fieldName, err)
+		}
+		return value
+	}
+
+	// Fill {name} path segments before {{...}} placeholders so the two syntaxes can't
+	// collide; each value gets template processing of its own
+	if len(req.PathParams) > 0 {
+		pathParams := make([]QueryParam, 0, len(req.PathParams))
+		for _, p := range req.PathParams {
+			if p.Enabled && p.Value != "" {
+				p.Value = processField("path param "+p.Key, p.Value)
+			}
+			pathParams = append(pathParams, p)
+		}
+		req.PathParams = pathParams
+		req.URL = applyPathParams(req.URL, req.PathParams)
+	}
+
+	// Process URL, encoding values substituted into the query string
+	if processedURL, err := api.processURL(req.URL, req.Variables, dynamicAliases); err == nil {
+		req.URL = processedURL
+	} else {
+		slog.Warn("template error in URL", "error", err)
+		if templateErr == nil {
+			templateErr = fmt.Errorf("URL: %v", err)
+		}
+	}
+
+	// Process headers
+	processedHeaders := make(map[string]string)
+	for key, value := range req.Headers {
+		processedKey := processField("header key", key)
+		processedValue := processField("header value", value)
+		processedHeaders[processedKey] = processedValue
+	}
+	req.Headers = processedHeaders
+	if len(req.HeaderList) > 0 {
+		processedList := make([]QueryParam, 0, len(req.HeaderList))
+		for _, entry := range req.HeaderList {
+			if entry.Enabled {
+				entry.Key = processField("header key", entry.Key)
+				entry.Value = processField("header value", entry.Value)
+			}
+			processedList = append(processedList, entry)
+		}
+		req.HeaderList = processedList
+		// Dynamic values differ on every substitution, so take listed names from the list again
+		applyHeaderList(&req)
+	}
+
+	// Process body
+	req.Body = processField("body", req.Body)
+	req.BodyFile = processField("body file", req.BodyFile)
+
+	// Process the key/value/parent of every enabled typed JSON and form field
+	processFields := func(kind string, fields []BodyField) []BodyField {
+		if len(fields) == 0 {
+			return fields
+		}
+		processed := make([]BodyField, 0, len(fields))
+		for _, f := range fields {
+			if f.Enabled {
+				if f.Key != "" {
+					f.Key = processField(kind+" body key", f.Key)
+				}
+				if f.Value != "" {
+					f.Value = processField(kind+" body value", f.Value)
+				}
+				if f.Parent != "" {
+					f.Parent = processField(kind+" body parent", f.Parent)
+				}
+			}
+			processed = append(processed, f)
+		}
+		return processed
+	}
+	req.BodyJson = processFields("json", req.BodyJson)
+	req.BodyForm = processFields("form", req.BodyForm)
+
+	// Process auth settings on a copy so the caller's config isn't modified
+	if req.Auth != nil && req.Auth.OAuth2 != nil {
+		req.Auth = copyAuthConfig(req.Auth)
+		oauth := req.Auth.OAuth2
+		oauth.TokenURL = processField("oauth2 token url", oauth.TokenURL)
+		oauth.ClientID = processField("oauth2 client id", oauth.ClientID)
+		oauth.ClientSecret = processField("oauth2 client secret", oauth.ClientSecret)
+		oauth.Scope = processField("oauth2 scope", oauth.Scope)
+	}
+
+	return req, templateErr
+}
+
+// findUnresolvedPlaceholders lists the distinct {{...}} placeholders left in a processed request
+//
+// Response variables whose source request has no LastResponse are left in place by
+// processSubstitution, so they are reported here as well.
+func findUnresolvedPlaceholders(req ProxyRequest) []string {
+	unresolved := collectPlaceholders(req)
+	sort.Strings(unresolved)
+	return unresolved
+}
+
+// collectPlaceholders lists the distinct {{...}} placeholders in a request's templated
+// fields, in the order they first appear
+func collectPlaceholders(req ProxyRequest) []string {
+	var placeholders []string
+	seen := make(map[string]bool)
+	collect := func(value string) {
+		for _, match := range responseVarPattern.FindAllString(value, -1) {
+			if !seen[match] {
+				seen[match] = true
+				placeholders = append(placeholders, match)
+			}
+		}
+	}
+
+	collect(req.URL)
+	for _, p := range req.PathParams {
+		if p.Enabled {
+			collect(p.Value)
+		}
+	}
+	headerKeys := make([]string, 0, len(req.Headers))
+	for key := range req.Headers {
+		headerKeys = append(headerKeys, key)
+	}
+	sort.Strings(headerKeys)
+	for _, key := range headerKeys {
+		collect(key)
+		collect(req.Headers[key])
+	}
+	collect(req.Body)
+	collect(req.BodyFile)
+	for _, f := range req.BodyJson {
+		if f.Enabled {
+			collect(f.Key)
+			collect(f.Value)
+		}
+	}
+	for _, f := range req.BodyForm {
+		if f.Enabled {
+			collect(f.Key)
+			collect(f.Value)
+		}
+	}
+	if req.Auth != nil && req.Auth.OAuth2 != nil {
+		collect(req.Auth.OAuth2.TokenURL)
+		collect(req.Auth.OAuth2.ClientID)
+		collect(req.Auth.OAuth2.ClientSecret)
+		collect(req.Auth.OAuth2.Scope)
+	}
+
+	return placeholders
+}
+
+// =============================================================================
+// PRE-REQUEST HOOKS
+// =============================================================================
+
+// maxPreRequestDepth limits how deeply runRequest steps may chain to prevent loops
+const maxPreRequestDepth = 5
+
+// chainError reports a runRequest chain that loops back on itself or nests too deeply
+//
+// It is returned unwrapped through every level of the chain so the message names the
+// full chain, e.g. "cycle: A → B → A".
+type chainError struct {
+	message string
+}
+
+func (e *chainError) Error() string {
+	return e.message
+}
+
+// runPreRequestSteps executes the request's declarative pre-request steps in order
+//
+// Supported step types:
+// - setHeader: resolves Value as a template and sets it as header Key
+// - setVariable: resolves Value as a template and defines variable Key for this request
+// - runRequest: sends the named saved request first so its response can be referenced
+//
+// chain holds the names of the saved requests currently being run, outermost first.
+func (api *API) runPreRequestSteps(ctx context.Context, req *ProxyRequest, chain []string) error {
+	if len(req.PreRequest) == 0 {
+		return nil
+	}
+	if req.Name != "" && (len(chain) == 0 || chain[len(chain)-1] != req.Name) {
+		chain = append(chain[:len(chain):len(chain)], req.Name)
+	}
+	if len(chain) > maxPreRequestDepth {
+		return &chainError{fmt.Sprintf("pre-request depth limit of %d exceeded: %s", maxPreRequestDepth, strings.Join(chain, " → "))}
+	}
+
+	if req.Headers == nil {
+		req.Headers = make(map[string]string)
+	}
+
+	// Steps may reference variables whose values nest other variables
+	variables, err := api.resolveNestedVariables(req.Variables)
+	if err != nil {
+		return err
+	}
+	req.Variables = variables
+
+	for i, step := range req.PreRequest {
+		switch step.Type {
+		case "setHeader":
+			if step.Key == "" {
+				return fmt.Errorf("pre-request step %d: header name is required", i+1)
+			}
+			value, err := api.processTemplate(step.Value, req.Variables)
+			if err != nil {
+				return fmt.Errorf("pre-request step %d: %v", i+1, err)
+			}
+			req.Headers[step.Key] = value
+			slog.Info("pre-request set header", "header", step.Key)
+		case "setVariable":
+			if step.Key == "" {
+				return fmt.Errorf("pre-request step %d: variable name is required", i+1)
+			}
+			value, err := api.processTemplate(step.Value, req.Variables)
+			if err != nil {
+				return fmt.Errorf("pre-request step %d: %v", i+1, err)
+			}
+			// Prepend so the step's value takes precedence over environment variables
+			req.Variables = append([]Variable{{Key: step.Key, Value: value}}, req.Variables...)
+			slog.Info("pre-request set variable", "variable", step.Key)
+		case "runRequest":
+			if step.Request == "" {
+				return fmt.Errorf("pre-request step %d: request name is required", i+1)
+			}
+			for j, name := range chain {
+				if name == step.Request {
+					return &chainError{fmt.Sprintf("cycle: %s", strings.Join(append(chain[j:len(chain):len(chain)], step.Request), " → "))}
+				}
+			}
+			if _, err := api.runSavedRequest(ctx, step.Request, req.EnvironmentID, chain); err != nil {
+				var chainErr *chainError
+				if errors.As(err, &chainErr) {
+					return err
+				}
+				return fmt.Errorf("pre-request step %d: %v", i+1, err)
+			}
+		default:
+			return fmt.Errorf("pre-request step %d: unknown type %q", i+1, step.Type)
+		}
+	}
+
+	return nil
+}
+
+// maxStatusHistory is the number of recent status codes kept per saved request
+const maxStatusHistory = 20
+
+// appendStatusHistory adds a status code, keeping only the most recent maxStatusHistory
+func appendStatusHistory(history []int, code int) []int {
+	history = append(history, code)
+	if len(history) > maxStatusHistory {
+		history = append([]int(nil), history[len(history)-maxStatusHistory:]...)
+	}
+	return history
+}
+
+// recordStatusHistory appends a status code to the history of the saved request with the given ID
+//
+// Only that request is updated, in one step, so a send never overwrites or is refused
+// over a change saved while it was in flight. A read-only server doesn't keep status
+// history.
+func (api *API) recordStatusHistory(requestID string, code int) error {
+	if readOnly {
+		return nil
+	}
+	return api.store.UpdateRequest(requestID, func(req *SavedRequest) error {
+		req.StatusHistory = appendStatusHistory(req.StatusHistory, code)
+		return nil
+	})
+}
+
+// applyPathParams replaces {name} segments in a URL's path with the matching enabled
+// path parameter, path-escaping the value
+//
+// Only single-brace names are matched; {{...}} placeholders are skipped whole, as are
+// the query string and fragment. Names without an enabled parameter are left as-is.
+func applyPathParams(rawURL string, params []QueryParam) string {
+	values := make(map[string]string, len(params))
+	for _, p := range params {
+		if p.Enabled && p.Key != "" {
+			values[p.Key] = p.Value
+		}
+	}
+	if len(values) == 0 {
+		return rawURL
+	}
+
+	end := len(rawURL)
+	if i := indexOutsidePlaceholders(rawURL, '?'); i != -1 {
+		end = i
+	}
+	if i := indexOutsidePlaceholders(rawURL[:end], '#'); i != -1 {
+		end = i
+	}
+	path := rawURL[:end]
+
+	var sb strings.Builder
+	for i := 0; i < len(path); {
+		if strings.HasPrefix(path[i:], "{{") {
+			placeholderEnd := findPlaceholderEnd(path, i)
+			if placeholderEnd == -1 {
+				sb.WriteString(path[i:])
+				break
+			}
+			sb.WriteString(path[i:placeholderEnd])
+			i = placeholderEnd
+			continue
+		}
+		if path[i] == '{' {
+			if closing := strings.IndexAny(path[i+1:], "{}"); closing != -1 && path[i+1+closing] == '}' {
+				if value, ok := values[path[i+1:i+1+closing]]; ok {
+					sb.WriteString(url.PathEscape(value))
+					i += closing + 2
+					continue
+				}
+			}
+		}
+		sb.WriteByte(path[i])
+		i++
+	}
+	sb.WriteString(rawURL[end:])
+	return sb.String()
+}
+
+// buildURLWithParams appends enabled query parameters to a URL
+//
+// {{...}} placeholders in keys and values are kept intact so processURL can substitute
+// (and encode) them later; the literal text around them is query-escaped here.
+func buildURLWithParams(rawURL string, params []QueryParam) string {
+	var pairs []string
+	for _, p := range params {
+		if p.Enabled && p.Key != "" {
+			pairs = append(pairs, escapeQueryTemplate(p.Key)+"="+escapeQueryTemplate(p.Value))
+		}
+	}
+	if len(pairs) == 0 {
+		return rawURL
+	}
+
+	separator := "?"
+	if indexOutsidePlaceholders(rawURL, '?') != -1 {
+		separator = "&"
+	}
+	return rawURL + separator + strings.Join(pairs, "&")
+}
+
+// escapeQueryTemplate query-escapes the text of a templated value outside its placeholders
+func escapeQueryTemplate(value string) string {
+	var sb strings.Builder
+	i := 0
+	for {
+		start := strings.Index(value[i:], "{{")
+		if start == -1 {
+			sb.WriteString(url.QueryEscape(value[i:]))
+			return sb.String()
+		}
+		start += i
+		end := findPlaceholderEnd(value, start)
+		if end == -1 {
+			sb.WriteString(url.QueryEscape(value[i:]))
+			return sb.String()
+		}
+		sb.WriteString(url.QueryEscape(value[i:start]))
+		sb.WriteString(value[start:end])
+		i = end
+	}
+}
+
+// proxyRequestFromSaved builds a ProxyRequest from a saved request definition, layering
+// the request's overrides above the given environment variables
+func proxyRequestFromSaved(saved SavedRequest, variables []Variable) ProxyRequest {
+	headers := make(map[string]string, len(saved.Headers))
+	for k, v := range saved.Headers {
+		headers[k] = v
+	}
+
+	method := saved.Method
+	if method == "" {
+		method = "GET"
+	}
+
+	return ProxyRequest{
+		RequestID:  saved.ID,
+		Name:       saved.Name,
+		Group:      saved.Group,
+		URL:        buildURLWithParams(saved.URL, saved.Params),
+		PathParams: saved.PathParams,
+		Method:     method,
+		Headers:    headers,
+		HeaderList: saved.HeaderList,
+		Body:       saved.BodyText,
+		BodyType:   saved.BodyType,
+		BodyFile:   saved.BodyFile,
+		BodyBase64: saved.BodyBase64,
+		BodyJson:   saved.BodyJson,
+		BodyForm:   saved.BodyForm,
+		Variables:  layerVariables(saved.Overrides, variables),
+		Overrides:  saved.Overrides,
+		PreRequest: saved.PreRequest,
+		Strict:     saved.Strict,
+		Auth:       saved.Auth,
+	}
+}
+
+// checkPreRequestChain walks the runRequest steps reachable from a saved request without
+// sending anything, returning a chainError for a cycle or a chain that nests too deeply
+func checkPreRequestChain(data *SavedRequestsData, name string, chain []string) error {
+	for j, prev := range chain {
+		if prev == name {
+			return &chainError{fmt.Sprintf("cycle: %s", strings.Join(append(chain[j:len(chain):len(chain)], name), " → "))}
+		}
+	}
+	chain = append(chain[:len(chain):len(chain)], name)
+	if len(chain) > maxPreRequestDepth {
+		return &chainError{fmt.Sprintf("pre-request depth limit of %d exceeded: %s", maxPreRequestDepth, strings.Join(chain, " → "))}
+	}
+
+	for _, saved := range data.Requests {
+		if saved.Name != name {
+			continue
+		}
+		for _, step := range saved.PreRequest {
+			if step.Type != "runRequest" || step.Request == "" {
+				continue
+			}
+			if err := checkPreRequestChain(data, step.Request, chain); err != nil {
+				return err
+			}
+		}
+		break
+	}
+	return nil
+}
+
+// runSavedRequest sends a saved request by name and stores the result as its LastResponse
+//
+// chain holds the names of the requests whose pre-request steps led here. envID is the
+// caller's active environment; the global current environment is used when it's unknown.
+func (api *API) runSavedRequest(ctx context.Context, name, envID string, chain []string) (*ProxyResponse, error) {
+	data, err := api.store.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	currentEnv := findEnvironment(data, envID)
+	if currentEnv == nil {
+		if currentEnv, err = getCurrentEnvironment(data); err != nil {
+			return nil, err
+		}
+	}
+
+	var saved *SavedRequest
+	for i := range data.Requests {
+		if data.Requests[i].Name == name {
+			saved = &data.Requests[i]
+			break
+		}
+	}
+	if saved == nil {
+		return nil, fmt.Errorf("request not found: %s", name)
+	}
+
+	slog.Info("running pre-request", "name", name)
+
+	req := proxyRequestFromSaved(*saved, scopeVariables(data, currentEnv))
+	req.EnvironmentID = currentEnv.ID
+	applyEnvironmentHeaders(&req, currentEnv)
+	if err := applyEnvironmentBaseURL(&req, currentEnv); err != nil {
+		return nil, fmt.Errorf("request %q: %v", name, err)
+	}
+	applyEnvironmentTimeout(&req, currentEnv)
+	applyEnvironmentHostOverrides(&req, currentEnv)
+	applyInheritedAuth(&req, data)
+	if err := api.runPreRequestSteps(ctx, &req, chain); err != nil {
+		return nil, err
+	}
+	applyDefaultUserAgent(&req, data)
+
+	processedReq, err := api.processTemplates(req)
+	if err != nil {
+		return nil, fmt.Errorf("request %q: %v", name, err)
+	}
+	if req.Strict || data.StrictTemplates {
+		if unresolved := findUnresolvedPlaceholders(processedReq); len(unresolved) > 0 {
+			return nil, fmt.Errorf("request %q has unresolved template variables: %s", name, strings.Join(unresolved, ", "))
+		}
+	}
+	if err := applyAuth(&processedReq, currentEnv.ID); err != nil {
+		return nil, fmt.Errorf("request %q authentication failed: %v", name, err)
+	}
+	if wait, scope := proxyRateLimiter.Reserve(processedReq.URL); wait > 0 {
+		return nil, fmt.Errorf("request %q: %s", name, rateLimitMessage(scope, wait))
+	}
+	response := makeHTTPRequest(ctx, processedReq)
+	recordExecution(processedReq, response, saved.ID, name)
+	if response.Error != "" {
+		return nil, fmt.Errorf("request %q failed: %s", name, response.Error)
+	}
+
+	stored := response
+	redactResponse(&stored, processedReq.Variables)
+	if readOnly {
+		readOnlyResponses.Store(saved.ID, &stored)
+		return &response, nil
+	}
+	// Update only this request, so what the nested request's own steps saved is kept
+	err = api.store.UpdateRequest(saved.ID, func(request *SavedRequest) error {
+		request.LastResponse = &stored
+		request.StatusHistory = appendStatusHistory(request.StatusHistory, response.StatusCode)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// =============================================================================
+// AUTHENTICATION
+// =============================================================================
+
+// oauth2ExpiryLeeway refreshes cached tokens this long before they actually expire
+const oauth2ExpiryLeeway = 10 * time.Second
+
+// cachedToken is an access token with its expiry time
+type cachedToken struct {
+	AccessToken string
+	ExpiresAt   time.Time
+}
+
+// oauth2TokenCache holds access tokens keyed by environment and client settings
+var (
+	oauth2TokenCache = make(map[string]cachedToken)
+	oauth2TokenMutex sync.Mutex
+)
+
+// clearOAuth2TokenCache drops every cached access token
+func clearOAuth2TokenCache() {
+	oauth2TokenMutex.Lock()
+	defer oauth2TokenMutex.Unlock()
+	clear(oauth2TokenCache)
+}
+
+// copyAuthConfig returns a deep copy of an auth config
+func copyAuthConfig(auth *AuthConfig) *AuthConfig {
+	if auth == nil {
+		return nil
+	}
+	clone := *auth
+	if auth.OAuth2 != nil {
+		oauth := *auth.OAuth2
+		clone.OAuth2 = &oauth
+	}
+	return &clone
+}
+
+// findProxiedSaved returns the saved request a proxied request was sent from, or nil
+//
+// Only the explicit requestId counts: an ad-hoc request that happens to share a saved
+// request's name must not pick up that request's auth.
+func findProxiedSaved(data *SavedRequestsData, req *ProxyRequest) *SavedRequest {
+	if req.RequestID == "" {
+		return nil
+	}
+	for i, saved := range data.Requests {
+		if saved.ID == req.RequestID {
+			return &data.Requests[i]
+		}
+	}
+	return nil
+}
+
+// applyInheritedAuth resolves a request's auth: its own config (including an explicit
+// "none") wins, then the nearest enclosing group with auth, walking up parent groups.
+// Clients that don't send auth get the saved request's own config. It returns where
+// the auth came from: "request", "group:<name>" or "none".
+func applyInheritedAuth(req *ProxyRequest, data *SavedRequestsData) string {
+	groupName := req.Group
+	if saved := findProxiedSaved(data, req); saved != nil {
+		if req.Auth == nil {
+			req.Auth = copyAuthConfig(saved.Auth)
+		}
+		if groupName == "" {
+			groupName = saved.Group
+		}
+	}
+	if req.Auth != nil && req.Auth.Type != "" {
+		if req.Auth.Type == "none" {
+			return "none"
+		}
+		return "request"
+	}
+
+	var group *Group
+	for i := range data.Groups {
+		if data.Groups[i].Name == groupName {
+			group = &data.Groups[i]
+			break
+		}
+	}
+	// repairGroupTree guarantees parents exist and don't loop
+	for group != nil {
+		if group.Auth != nil && group.Auth.Type != "" {
+			if group.Auth.Type == "none" {
+				return "none"
+			}
+			req.Auth = copyAuthConfig(group.Auth)
+			slog.Info("using auth from group", "group", group.Name)
+			return "group:" + group.Name
+		}
+		if group.ParentID == "" {
+			break
+		}
+		group = findGroup(data, group.ParentID)
+	}
+	return "none"
+}
+
+// stripAuthSecret returns a copy of auth without literal client secrets; secrets that
+// reference a variable are kept since the variable itself is stripped separately
+func stripAuthSecret(auth *AuthConfig) *AuthConfig {
+	auth = copyAuthConfig(auth)
+	if auth != nil && auth.OAuth2 != nil && !strings.Contains(auth.OAuth2.ClientSecret, "{{") {
+		auth.OAuth2.ClientSecret = ""
+	}
+	return auth
+}
+
+// oauth2CacheKey identifies a token by environment, endpoint, client and scope
+func oauth2CacheKey(envID string, cfg *OAuth2Config) string {
+	return strings.Join([]string{envID, cfg.TokenURL, cfg.ClientID, cfg.Scope}, "\x00")
+}
+
+// cachedOAuth2Token returns a cached token that is still valid
+func cachedOAuth2Token(envID string, cfg *OAuth2Config) (string, bool) {
+	oauth2TokenMutex.Lock()
+	defer oauth2TokenMutex.Unlock()
+
+	token, ok := oauth2TokenCache[oauth2CacheKey(envID, cfg)]
+	if !ok || time.Now().Add(oauth2ExpiryLeeway).After(token.ExpiresAt) {
+		return "", false
+	}
+	return token.AccessToken, true
+}
+
+// fetchOAuth2Token returns an access token for the client-credentials grant
+//
+// Tokens are cached per environment until they are within oauth2ExpiryLeeway of
+// expiring. Tokens issued without an expires_in are not cached.
+func fetchOAuth2Token(envID string, cfg *OAuth2Config) (string, error) {
+	if cfg.TokenURL == "" {
+		return "", fmt.Errorf("oauth2 token URL is required")
+	}
+	if cfg.ClientID == "" {
+		return "", fmt.Errorf("oauth2 client ID is required")
+	}
+
+	if token, ok := cachedOAuth2Token(envID, cfg); ok {
+		return token, nil
+	}
+
+	form := []BodyField{
+		{Key: "grant_type", Value: "client_credentials", Enabled: true},
+		{Key: "client_id", Value: cfg.ClientID, Enabled: true},
+		{Key: "client_secret", Value: cfg.ClientSecret, Enabled: cfg.ClientSecret != ""},
+		{Key: "scope", Value: cfg.Scope, Enabled: cfg.Scope != ""},
+	}
+
+	slog.Info("fetching OAuth2 token", "url", cfg.TokenURL)
+	// Tokens are cached for every later request, so the fetch isn't tied to this one.
+	// The client secret is marked secret so the logged form body masks it.
+	resp := makeHTTPRequest(context.Background(), ProxyRequest{
+		URL:       cfg.TokenURL,
+		Method:    http.MethodPost,
+		Headers:   map[string]string{"Accept": "application/json"},
+		BodyType:  "form",
+		BodyForm:  form,
+		Variables: []Variable{{Key: "clientSecret", Value: cfg.ClientSecret, Secret: true}},
+	})
+	if resp.Error != "" {
+		return "", fmt.Errorf("token request failed: %s", resp.Error)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("token endpoint returned %s: %s", resp.Status, harContentText(resp.Body))
+	}
+
+	// Some providers answer with a form-encoded body or send JSON with a non-JSON content type
+	body, ok := resp.Body.(map[string]any)
+	if !ok {
+		text, isText := resp.Body.(string)
+		if !isText {
+			return "", fmt.Errorf("token endpoint returned an invalid response")
+		}
+		if isFormContentType(headerValue(resp.Headers, "Content-Type")) {
+			form, err := parseFormBody(text)
+			if err != nil {
+				return "", fmt.Errorf("token endpoint returned an invalid response: %v", err)
+			}
+			body = form
+		} else if json.Unmarshal([]byte(text), &body) != nil {
+			return "", fmt.Errorf("token endpoint returned an invalid response")
+		}
+	}
+
+	accessToken, _ := body["access_token"].(string)
+	if accessToken == "" {
+		return "", fmt.Errorf("token endpoint response has no access_token")
+	}
+
+	var expiresIn float64
+	switch v := body["expires_in"].(type) {
+	case float64:
+		expiresIn = v
+	case string:
+		expiresIn, _ = strconv.ParseFloat(v, 64)
+	}
+
+	if expiresIn > 0 {
+		oauth2TokenMutex.Lock()
+		oauth2TokenCache[oauth2CacheKey(envID, cfg)] = cachedToken{
+			AccessToken: accessToken,
+			ExpiresAt:   time.Now().Add(time.Duration(expiresIn * float64(time.Second))),
+		}
+		oauth2TokenMutex.Unlock()
+	}
+
+	slog.Info("obtained OAuth2 token", "expiresIn", time.Duration(expiresIn*float64(time.Second)))
+	return accessToken, nil
+}
+
+// applyAuth adds the credentials described by req.Auth to the request headers
+func applyAuth(req *ProxyRequest, envID string) error {
+	if req.Auth == nil || req.Auth.Type == "" {
+		return nil
+	}
+
+	switch req.Auth.Type {
+	case "none":
+		// Explicit opt-out of group auth
+	case "oauth2":
+		if req.Auth.OAuth2 == nil {
+			return fmt.Errorf("oauth2 settings are required")
+		}
+		token, err := fetchOAuth2Token(envID, req.Auth.OAuth2)
+		if err != nil {
+			return err
+		}
+		if req.Headers == nil {
+			req.Headers = make(map[string]string)
+		}
+		req.Headers["Authorization"] = "Bearer " + token
+	default:
+		return fmt.Errorf("unknown auth type %q", req.Auth.Type)
+	}
+
+	return nil
+}
+
+// =============================================================================
+// DATA MIGRATION & INITIALIZATION
+// =============================================================================
+
+// initEnv creates a default environment for new installations
+func initEnv(data *SavedRequestsData) *SavedRequestsData {
+	now := time.Now().Format(time.RFC3339)
+	defaultEnv := Environment{
 		ID:        generateID(),
 		Name:      "Default",
 		Variables: []Variable{},
@@ -1025,792 +6404,6592 @@ func initEnv(data *SavedRequestsData) *SavedRequestsData {
 		UpdatedAt: now,
 	}
 
-	data.Environments = []Environment{defaultEnv}
-	data.CurrentEnvironment = defaultEnv.ID
-	return data
+	data.Environments = []Environment{defaultEnv}
+	data.CurrentEnvironment = defaultEnv.ID
+	return data
+}
+
+// getCurrentEnvironment returns the current active environment
+func getCurrentEnvironment(data *SavedRequestsData) (*Environment, error) {
+	if data.CurrentEnvironment == "" && len(data.Environments) > 0 {
+		data.CurrentEnvironment = data.Environments[0].ID
+	}
+
+	for i := range data.Environments {
+		if data.Environments[i].ID == data.CurrentEnvironment {
+			return &data.Environments[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("current environment not found")
+}
+
+// sessionEnvironmentID returns the environment ID selected by the client, if any
+func sessionEnvironmentID(r *http.Request) string {
+	envID, _ := r.Context().Value(sessionEnvironmentKey).(string)
+	return envID
+}
+
+// getActiveEnvironment returns the environment selected by the client's session, falling
+// back to the global current environment when none is selected or it no longer exists
+func getActiveEnvironment(r *http.Request, data *SavedRequestsData) (*Environment, error) {
+	if envID := sessionEnvironmentID(r); envID != "" {
+		if env := findEnvironment(data, envID); env != nil {
+			return env, nil
+		}
+		slog.Warn("session environment not found, using the current environment", "environment", envID)
+	}
+	return getCurrentEnvironment(data)
+}
+
+// Store loads and saves a workspace's data
+//
+// Handlers that change several parts of the data at once (deleting an environment also
+// moves its requests, importing adds requests, groups and environments) work on the
+// whole document with Load and Save. Everything else goes through the per-entity
+// operations, which read or change one request, environment, group or the settings.
+// Each change is a single Update, so it can't lose an edit made since the data was read.
+type Store interface {
+	documentStore
+
+	ListRequests() ([]SavedRequest, error)
+	GetRequest(id string) (*SavedRequest, error)
+	SaveRequest(req SavedRequest) error
+	UpdateRequest(id string, fn func(req *SavedRequest) error) error
+	DeleteRequest(id string) (*SavedRequest, error)
+
+	ListEnvironments() ([]Environment, error)
+	GetEnvironment(id string) (*Environment, error)
+	UpdateEnvironment(id string, fn func(env *Environment) error) error
+
+	ListGroups() ([]Group, error)
+	GetGroup(id string) (*Group, error)
+	UpdateGroup(id string, fn func(group *Group) error) error
+
+	Settings() (Settings, error)
+	UpdateSettings(fn func(settings *Settings) error) error
+}
+
+// documentStore is the storage a Store is built on
+//
+// Load returns everything with the usual repairs applied and Save writes it all back.
+// Update applies fn to the current data and saves the result as one step; nothing is
+// saved when fn returns an error.
+type documentStore interface {
+	Load() (*SavedRequestsData, error)
+	Save(data *SavedRequestsData) error
+	Update(fn func(data *SavedRequestsData) error) error
+}
+
+// API holds the HTTP handlers and the store they read and write
+type API struct {
+	store Store
+}
+
+// newAPI returns handlers backed by store
+func newAPI(store Store) *API {
+	return &API{store: store}
+}
+
+var (
+	errRequestNotFound     = errors.New("request not found")
+	errEnvironmentNotFound = errors.New("environment not found")
+	errGroupNotFound       = errors.New("group not found")
+)
+
+// entityStore gives a documentStore the per-entity operations of Store
+type entityStore struct {
+	documentStore
+}
+
+// ListRequests returns the saved requests, templates included
+func (s entityStore) ListRequests() ([]SavedRequest, error) {
+	data, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+	return data.Requests, nil
+}
+
+// GetRequest returns the saved request with the given ID
+func (s entityStore) GetRequest(id string) (*SavedRequest, error) {
+	data, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+	for i := range data.Requests {
+		if data.Requests[i].ID == id {
+			return &data.Requests[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", errRequestNotFound, id)
+}
+
+// SaveRequest replaces the saved request with req's ID, or adds req when there is none
+func (s entityStore) SaveRequest(req SavedRequest) error {
+	return s.Update(func(data *SavedRequestsData) error {
+		for i := range data.Requests {
+			if data.Requests[i].ID == req.ID {
+				data.Requests[i] = req
+				return nil
+			}
+		}
+		data.Requests = append(data.Requests, req)
+		return nil
+	})
+}
+
+// UpdateRequest applies fn to the saved request with the given ID and saves it
+func (s entityStore) UpdateRequest(id string, fn func(req *SavedRequest) error) error {
+	return s.Update(func(data *SavedRequestsData) error {
+		for i := range data.Requests {
+			if data.Requests[i].ID == id {
+				return fn(&data.Requests[i])
+			}
+		}
+		return fmt.Errorf("%w: %s", errRequestNotFound, id)
+	})
+}
+
+// DeleteRequest moves the saved request with the given ID to the trash and returns it
+func (s entityStore) DeleteRequest(id string) (*SavedRequest, error) {
+	var deleted SavedRequest
+	err := s.Update(func(data *SavedRequestsData) error {
+		for i := range data.Requests {
+			if data.Requests[i].ID == id {
+				deleted = data.Requests[i]
+				deleted.DeletedAt = time.Now().Format(time.RFC3339)
+				data.Trash = append(data.Trash, deleted)
+				data.Requests = append(data.Requests[:i], data.Requests[i+1:]...)
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %s", errRequestNotFound, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &deleted, nil
+}
+
+// ListEnvironments returns the environments
+func (s entityStore) ListEnvironments() ([]Environment, error) {
+	data, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+	return data.Environments, nil
+}
+
+// GetEnvironment returns the environment with the given ID
+func (s entityStore) GetEnvironment(id string) (*Environment, error) {
+	data, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+	if env := findEnvironment(data, id); env != nil {
+		return env, nil
+	}
+	return nil, fmt.Errorf("%w: %s", errEnvironmentNotFound, id)
+}
+
+// UpdateEnvironment applies fn to the environment with the given ID and saves it
+func (s entityStore) UpdateEnvironment(id string, fn func(env *Environment) error) error {
+	return s.Update(func(data *SavedRequestsData) error {
+		if env := findEnvironment(data, id); env != nil {
+			return fn(env)
+		}
+		return fmt.Errorf("%w: %s", errEnvironmentNotFound, id)
+	})
+}
+
+// ListGroups returns the groups in display order
+func (s entityStore) ListGroups() ([]Group, error) {
+	data, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+	return data.Groups, nil
+}
+
+// GetGroup returns the group with the given ID
+func (s entityStore) GetGroup(id string) (*Group, error) {
+	data, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+	if group := findGroup(data, id); group != nil {
+		return group, nil
+	}
+	return nil, fmt.Errorf("%w: %s", errGroupNotFound, id)
+}
+
+// UpdateGroup applies fn to the group with the given ID and saves it
+func (s entityStore) UpdateGroup(id string, fn func(group *Group) error) error {
+	return s.Update(func(data *SavedRequestsData) error {
+		if group := findGroup(data, id); group != nil {
+			return fn(group)
+		}
+		return fmt.Errorf("%w: %s", errGroupNotFound, id)
+	})
+}
+
+// Settings returns the workspace's settings
+func (s entityStore) Settings() (Settings, error) {
+	data, err := s.Load()
+	if err != nil {
+		return Settings{}, err
+	}
+	return data.Settings, nil
+}
+
+// UpdateSettings applies fn to the workspace's settings and saves them
+func (s entityStore) UpdateSettings(fn func(settings *Settings) error) error {
+	return s.Update(func(data *SavedRequestsData) error {
+		return fn(&data.Settings)
+	})
+}
+
+// fileStore keeps each workspace's data in its JSON file, guarded by fileAccessMutex
+type fileStore struct{}
+
+// newFileStore returns the Store main serves from
+func newFileStore() Store {
+	return entityStore{fileStore{}}
+}
+
+// Load reads the active workspace's data file
+//
+// It holds only the read lock and never writes: repairs made while loading (default
+// environment and group, group tree, expired trash, orphan drafts) stay in memory until
+// the caller's next save.
+func (fileStore) Load() (*SavedRequestsData, error) {
+	fileAccessMutex.RLock()
+	defer fileAccessMutex.RUnlock()
+	return readDataFile(activeWorkspace().File, false)
+}
+
+// readDataFile reads and repairs the data file at path; the caller holds fileAccessMutex,
+// and the file lock too when holdsLock is set
+func readDataFile(path string, holdsLock bool) (*SavedRequestsData, error) {
+	start := time.Now()
+
+	// Stat before reading: an edit landing in between makes the stamp older than the
+	// contents, which can only cause a needless refusal to save, never a lost edit
+	data := &SavedRequestsData{
+		Requests:     []SavedRequest{},
+		Variables:    []Variable{},
+		Globals:      []Variable{},
+		Environments: []Environment{},
+		Trash:        []SavedRequest{},
+		path:         path,
+		loaded:       true,
+		loadedStamp:  statFileStamp(path),
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		// File doesn't exist, create default environment
+		data = initEnv(data)
+		return data, nil
+	}
+
+	unlock := func() {}
+	if !holdsLock {
+		var err error
+		if unlock, err = lockDataFile(path, true); err != nil {
+			return nil, err
+		}
+	}
+	file, err := os.ReadFile(path)
+	unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read requests file: %v", err)
+	}
+
+	if len(file) == 0 {
+		// Empty file, create default environment
+		data = initEnv(data)
+		return data, nil
+	}
+
+	if err := json.Unmarshal(file, data); err != nil {
+		slog.Warn("storage file is not valid JSON; starting with empty data", "path", path, "error", err)
+		// If JSON is corrupted, create a new file with default environment
+		data = initEnv(data)
+		return data, nil
+	}
+	repairData(data)
+
+	slog.Debug("storage load", "path", path, "bytes", len(file), "requests", len(data.Requests), "duration", time.Since(start))
+	return data, nil
+}
+
+// repairData fills in defaults and fixes up data decoded from a stored document
+//
+// Stores apply it to everything they load, so older files and hand edits get the same
+// migrations whatever the backend.
+func repairData(data *SavedRequestsData) {
+	// Ensure variables array is not nil
+	if data.Variables == nil {
+		data.Variables = []Variable{}
+	}
+
+	// Ensure globals array is not nil
+	if data.Globals == nil {
+		data.Globals = []Variable{}
+	}
+
+	// Ensure environments array is not nil
+	if data.Environments == nil {
+		data.Environments = []Environment{}
+	}
+
+	// Ensure we have at least a default environment
+	if len(data.Environments) == 0 {
+		initEnv(data)
+		noteRepair(data, repairCreatedEnvironment, data.CurrentEnvironment, "Default", "no environments existed")
+	}
+
+	// Ensure current environment is set
+	if data.CurrentEnvironment == "" && len(data.Environments) > 0 {
+		data.CurrentEnvironment = data.Environments[0].ID
+		noteRepair(data, repairCurrentEnvironment, data.Environments[0].ID, data.Environments[0].Name, "no current environment was set")
+	}
+
+	// Ensure groups array is not nil
+	if data.Groups == nil {
+		data.Groups = []Group{}
+	}
+
+	// Keep groups in display order with a valid hierarchy, then ensure default group exists
+	sortGroups(data)
+	repairGroupTree(data)
+	normalizeGroupMetadata(data)
+	ensureDefaultGroup(data)
+	reassignOrphanedRequests(data)
+
+	// Ensure trash array is not nil and drop expired entries
+	if data.Trash == nil {
+		data.Trash = []SavedRequest{}
+	}
+	purgeExpiredTrash(data)
+
+	if data.DraftIdleSeconds <= 0 {
+		data.DraftIdleSeconds = defaultDraftIdleSeconds
+	}
+	pruneOrphanDrafts(data)
+
+	// Requests saved before header lists existed get one built from their header map
+	for _, requests := range [][]SavedRequest{data.Requests, data.Trash} {
+		for i := range requests {
+			if len(requests[i].HeaderList) == 0 && len(requests[i].Headers) > 0 {
+				requests[i].HeaderList = headerListFromMap(requests[i].Headers)
+				noteRepair(data, repairMigratedHeaders, requests[i].ID, requests[i].Name, "built a header list from the header map")
+			}
+		}
+	}
+}
+
+// Save writes data to the file it was loaded from
+//
+// Writing back to the same file keeps a save from landing in another workspace when
+// the active workspace is switched while a request is being handled. A read-only
+// server refuses every save, whichever route it comes from.
+func (fileStore) Save(data *SavedRequestsData) error {
+	if readOnly {
+		return errReadOnly
+	}
+	path := data.path
+	if path == "" {
+		path = activeWorkspace().File
+	}
+
+	fileAccessMutex.Lock()
+	defer fileAccessMutex.Unlock()
+	start := time.Now()
+
+	// Held across the edit check and the write so another process can't slip in between
+	unlock, err := lockDataFile(path, false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if data.loaded && !statFileStamp(path).same(data.loadedStamp) && !isOwnWrite(path) {
+		slog.Warn("data file was edited outside go-rest after it was read; keeping the edited file and refusing this save", "path", path)
+		return errExternalEdit
+	}
+	return writeDataFile(path, data, start)
+}
+
+// Update applies fn to the active workspace's data and saves the result
+//
+// The locks are held from the read to the write, so fn sees the latest data and the
+// save can't be refused as an external edit.
+func (fileStore) Update(fn func(data *SavedRequestsData) error) error {
+	if readOnly {
+		return errReadOnly
+	}
+	path := activeWorkspace().File
+
+	fileAccessMutex.Lock()
+	defer fileAccessMutex.Unlock()
+	start := time.Now()
+
+	unlock, err := lockDataFile(path, false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	data, err := readDataFile(path, true)
+	if err != nil {
+		return err
+	}
+	if err := fn(data); err != nil {
+		return err
+	}
+	return writeDataFile(path, data, start)
+}
+
+// writeDataFile writes data to path; the caller holds fileAccessMutex and the file lock
+func writeDataFile(path string, data *SavedRequestsData, start time.Time) error {
+	// Marshal data to JSON
+	jsonData, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal requests data: %v", err)
+	}
+
+	// On Windows, try direct write first (simpler approach)
+	// If that fails, fall back to atomic write with retries
+	if err := tryDirectWrite(path, jsonData); err == nil {
+		data.loadedStamp = recordOwnWrite(path)
+		slog.Info("storage save", "path", path, "bytes", len(jsonData), "requests", len(data.Requests), "duration", time.Since(start))
+		return nil
+	}
+
+	// Fallback: atomic write with retry logic for Windows file locking issues
+	tempFileName := path + ".tmp"
+	if err := os.WriteFile(tempFileName, jsonData, 0644); err != nil {
+		return fmt.Errorf("failed to write temporary file: %v", err)
+	}
+
+	// Retry rename operation with exponential backoff for Windows file locking
+	maxRetries := 5
+	baseDelay := 25 * time.Millisecond
+
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		// Try to remove target file first (Windows sometimes requires this)
+		if _, err := os.Stat(path); err == nil {
+			os.Remove(path)
+			time.Sleep(10 * time.Millisecond) // Small delay after removal
+		}
+
+		// Attempt rename
+		if err := os.Rename(tempFileName, path); err == nil {
+			data.loadedStamp = recordOwnWrite(path)
+			slog.Info("storage save", "path", path, "bytes", len(jsonData), "requests", len(data.Requests), "duration", time.Since(start), "attempt", attempt)
+			return nil
+		} else {
+			slog.Warn("storage rename failed", "path", path, "attempt", attempt, "error", err)
+			if attempt < maxRetries {
+				time.Sleep(baseDelay << (attempt - 1))
+			}
+		}
+	}
+
+	// If all retries failed, clean up and return error
+	os.Remove(tempFileName)
+	return fmt.Errorf("failed to save after %d attempts - file may be locked by another process", maxRetries)
+}
+
+// tryDirectWrite attempts a direct write to the file (simpler, works most of the time)
+func tryDirectWrite(path string, jsonData []byte) error {
+	// Try to write directly to the file
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(jsonData)
+	if err != nil {
+		return err
+	}
+
+	return file.Sync() // Ensure data is written to disk
+}
+
+// Kinds of fix reported by /api/admin/repair. The first group is made on every load;
+// the rest only when a repair is asked for.
+const (
+	repairCreatedEnvironment = "createdEnvironment" // No environments; a Default one was added
+	repairCurrentEnvironment = "currentEnvironment" // The current environment was unset or missing
+	repairCreatedGroup       = "createdGroup"       // The default group was missing
+	repairMovedGroup         = "movedGroup"         // Orphaned or cyclic group moved to the top level
+	repairClearedColor       = "clearedColor"       // Invalid group color removed
+	repairPurgedTrash        = "purgedTrash"        // Expired trash entry removed
+	repairDroppedDraft       = "droppedDraft"       // Draft of a request that no longer exists removed
+	repairMigratedHeaders    = "migratedHeaders"    // Header list built from an old header map
+	repairReassignedRequest  = "reassignedRequest"  // Request in a group that doesn't exist moved to default
+
+	repairEnvironmentID  = "environmentId"  // Environment sharing another's ID given a new one
+	repairRenamedRequest = "renamedRequest" // Request sharing another's name given a suffix
+)
+
+// RepairAction is one fix made to the data
+type RepairAction struct {
+	Kind   string `json:"kind"`
+	ID     string `json:"id,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Detail string `json:"detail"`
+}
+
+// noteRepair records a fix in data, to be reported if a repair was asked for
+func noteRepair(data *SavedRequestsData, kind, id, name, detail string) {
+	data.repairs = append(data.repairs, RepairAction{Kind: kind, ID: id, Name: name, Detail: detail})
+}
+
+// checkIntegrity fixes problems that loading leaves alone because they can only come from
+// hand edits or merges of the data file: environments sharing an ID, requests sharing a
+// name, and a current environment that's gone
+//
+// The first of each duplicate keeps its ID or name, so references to it stay valid.
+func checkIntegrity(data *SavedRequestsData) {
+	now := time.Now().Format(time.RFC3339)
+
+	seenEnvironments := make(map[string]bool, len(data.Environments))
+	for i := range data.Environments {
+		env := &data.Environments[i]
+		if seenEnvironments[env.ID] {
+			oldID := env.ID
+			env.ID = generateID()
+			env.UpdatedAt = now
+			noteRepair(data, repairEnvironmentID, env.ID, env.Name, "was "+oldID+", the ID of an earlier environment")
+		}
+		seenEnvironments[env.ID] = true
+	}
+	if findEnvironment(data, data.CurrentEnvironment) == nil && len(data.Environments) > 0 {
+		noteRepair(data, repairCurrentEnvironment, data.Environments[0].ID, data.Environments[0].Name, "environment "+data.CurrentEnvironment+" no longer exists")
+		data.CurrentEnvironment = data.Environments[0].ID
+	}
+
+	seenNames := make(map[string]bool, len(data.Requests))
+	for i := range data.Requests {
+		req := &data.Requests[i]
+		if seenNames[req.Name] {
+			oldName := req.Name
+			req.Name = uniqueName(oldName, data.Requests)
+			req.UpdatedAt = now
+			req.Version++
+			noteRepair(data, repairRenamedRequest, req.ID, req.Name, fmt.Sprintf("was %q, the name of an earlier request", oldName))
+		}
+		seenNames[req.Name] = true
+	}
+}
+
+// repairWorkspace handles POST requests to check the active workspace's data and fix
+// what it can, returning a report of each fix
+//
+// This surfaces the repairs loading makes, which a hand edit since startup can call for,
+// along with checks that only run here. With ?dryRun=true the report is returned
+// without saving anything.
+func (api *API) repairWorkspace(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	dryRun := r.URL.Query().Get("dryRun") == "true"
+
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load saved requests", "error", err)
+		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
+		return
+	}
+	checkIntegrity(data)
+	actions := data.repairs
+	if actions == nil {
+		actions = []RepairAction{}
+	}
+
+	saved := false
+	if len(actions) > 0 && !dryRun {
+		if err := api.store.Save(data); err != nil {
+			slog.Error("failed to save repaired data", "error", err)
+			respondWithError(w, "Failed to save repaired data", http.StatusInternalServerError)
+			return
+		}
+		saved = true
+		slog.Info("repaired workspace", "workspace", activeWorkspace().ID, "fixes", len(actions))
+		recordAudit(r, "repair", "workspace", activeWorkspace().ID, "")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]any{
+		"dryRun":  dryRun,
+		"saved":   saved,
+		"count":   len(actions),
+		"actions": actions,
+	}); err != nil {
+		slog.Error("failed to encode repair report", "error", err)
+	}
+}
+
+// saveLoadRepairs saves the fixes loading makes to the active workspace's data, so a
+// problem in the file is fixed and logged once rather than on every load
+//
+// It runs at startup and when a workspace is activated. A read-only server only logs
+// the fixes.
+func (api *API) saveLoadRepairs() {
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Warn("failed to check the data file for repairs", "error", err)
+		return
+	}
+	if len(data.repairs) == 0 {
+		return
+	}
+	for _, action := range data.repairs {
+		slog.Info("repairing data file", "kind", action.Kind, "id", action.ID, "name", action.Name, "detail", action.Detail)
+	}
+	if readOnly {
+		slog.Info("read-only server; data file repairs are kept in memory", "fixes", len(data.repairs))
+		return
+	}
+	// Update loads again under the write lock, so the fixes apply to the current file
+	if err := api.store.Update(func(*SavedRequestsData) error { return nil }); err != nil {
+		slog.Warn("failed to save data file repairs", "error", err)
+	}
+}
+
+// requests handles GET requests to retrieve all saved requests
+func (api *API) requests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load saved requests", "error", err)
+		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
+		return
+	}
+
+	// Templates are only listed when explicitly requested
+	if r.URL.Query().Get("includeTemplates") != "true" {
+		concrete := make([]SavedRequest, 0, len(data.Requests))
+		for _, request := range data.Requests {
+			if !request.Template {
+				concrete = append(concrete, request)
+			}
+		}
+		data.Requests = concrete
+	}
+
+	if tag := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("tag"))); tag != "" {
+		tagged := make([]SavedRequest, 0)
+		for _, request := range data.Requests {
+			if slices.Contains(request.Tags, tag) {
+				tagged = append(tagged, request)
+			}
+		}
+		data.Requests = tagged
+	}
+	if r.URL.Query().Get("pinned") == "true" {
+		pinned := make([]SavedRequest, 0)
+		for _, request := range data.Requests {
+			if request.Pinned {
+				pinned = append(pinned, request)
+			}
+		}
+		data.Requests = pinned
+	}
+
+	// Pinned requests come first; the client groups the list, so they lead their group
+	sort.SliceStable(data.Requests, func(i, j int) bool {
+		return data.Requests[i].Pinned && !data.Requests[j].Pinned
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		slog.Error("failed to encode saved requests", "error", err)
+	}
+}
+
+// =============================================================================
+// REQUEST MANAGEMENT HANDLERS
+// =============================================================================
+
+// Helper function to decode JSON request body with error handling
+func decodeJSONRequest(w http.ResponseWriter, r *http.Request, target interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(target); err != nil {
+		slog.Error("invalid JSON request body", "error", err)
+		respondWithError(w, "Invalid request body", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// maxNameLength caps request, environment and group names (in characters)
+const maxNameLength = 200
+
+// normalizeName trims a request/environment/group name and rejects names that
+// are empty, longer than maxNameLength or contain control characters
+func normalizeName(kind, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("%s name is required", kind)
+	}
+	if n := utf8.RuneCountInString(name); n > maxNameLength {
+		return "", fmt.Errorf("%s name is too long (%d characters, max %d)", kind, n, maxNameLength)
+	}
+	for _, c := range name {
+		if unicode.IsControl(c) {
+			return "", fmt.Errorf("%s name must not contain control characters", kind)
+		}
+	}
+	return name, nil
+}
+
+// Helper function to validate required fields for saved requests, returning the normalized name
+func validateSavedRequest(name, url string) (string, error) {
+	name, err := normalizeName("request", name)
+	if err != nil {
+		return "", err
+	}
+	if url == "" {
+		return "", fmt.Errorf("URL is required")
+	}
+	return name, nil
+}
+
+// SaveRequestPayload is the client payload for creating a new saved request
+type SaveRequestPayload struct {
+	Name         string            `json:"name"`
+	URL          string            `json:"url"`
+	Method       string            `json:"method"`
+	Headers      map[string]string `json:"headers"`
+	HeaderList   []QueryParam      `json:"headerList,omitempty"`
+	Body         any               `json:"body"`
+	BodyType     string            `json:"bodyType,omitempty"`
+	BodyText     string            `json:"bodyText,omitempty"`
+	BodyFile     string            `json:"bodyFile,omitempty"`
+	BodyBase64   string            `json:"bodyBase64,omitempty"`
+	BodyJson     []BodyField       `json:"bodyJson,omitempty"`
+	BodyForm     []BodyField       `json:"bodyForm,omitempty"`
+	Params       []QueryParam      `json:"params"`
+	PathParams   []QueryParam      `json:"pathParams,omitempty"`
+	Group        string            `json:"group"`
+	Description  string            `json:"description"`
+	Tags         []string          `json:"tags,omitempty"`
+	Overrides    []Variable        `json:"overrides,omitempty"`
+	PreRequest   []PreRequestStep  `json:"preRequest,omitempty"`
+	Template     bool              `json:"template,omitempty"`
+	Strict       bool              `json:"strictTemplates,omitempty"`
+	Auth         *AuthConfig       `json:"auth,omitempty"`
+	ExternalID   string            `json:"externalId,omitempty"`
+	LastResponse *ProxyResponse    `json:"lastResponse,omitempty"`
+}
+
+// prettifyJSONBody indents a raw JSON body so saved requests are formatted consistently
+//
+// It applies to "json" bodies and to text bodies sent with a JSON Content-Type. Key order,
+// number literals and string escapes are kept as written; only whitespace changes. Text
+// that isn't valid JSON (work in progress, or placeholders standing in for values) is
+// returned unchanged.
+func prettifyJSONBody(bodyType string, headers map[string]string, text string) string {
+	switch bodyType {
+	case "json":
+	case "", "text":
+		mediaType, _, err := mime.ParseMediaType(headerValue(headers, "Content-Type"))
+		if err != nil || !isJSONContentType(mediaType) {
+			return text
+		}
+	default:
+		return text
+	}
+
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" || !json.Valid([]byte(trimmed)) {
+		return text
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(trimmed), "", "  "); err != nil {
+		return text
+	}
+	return buf.String()
+}
+
+// normalizeTags trims and lower-cases tags, dropping empty and repeated ones
+func normalizeTags(tags []string) []string {
+	var normalized []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
+
+// newSavedRequest builds a SavedRequest from a payload, applying defaults and a fresh ID
+func newSavedRequest(req SaveRequestPayload, now string) SavedRequest {
+	if req.Method == "" {
+		req.Method = "GET"
+	}
+	if req.Group == "" {
+		req.Group = "default"
+	}
+	req.Headers, req.HeaderList = savedHeaders(req.Headers, req.HeaderList)
+
+	return SavedRequest{
+		ID:           generateID(),
+		Name:         req.Name,
+		URL:          req.URL,
+		Method:       req.Method,
+		Headers:      req.Headers,
+		HeaderList:   req.HeaderList,
+		BodyType:     req.BodyType,
+		BodyText:     prettifyJSONBody(req.BodyType, req.Headers, req.BodyText),
+		BodyFile:     req.BodyFile,
+		BodyBase64:   req.BodyBase64,
+		BodyJson:     req.BodyJson,
+		BodyForm:     req.BodyForm,
+		Params:       req.Params,
+		PathParams:   req.PathParams,
+		Group:        req.Group,
+		Description:  req.Description,
+		Tags:         normalizeTags(req.Tags),
+		Overrides:    req.Overrides,
+		PreRequest:   req.PreRequest,
+		Template:     req.Template,
+		Strict:       req.Strict,
+		Auth:         req.Auth,
+		ExternalID:   req.ExternalID,
+		LastResponse: req.LastResponse,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+}
+
+// errStaleRecord is returned when an update is based on an outdated version of a record
+var errStaleRecord = errors.New("record was modified by another client; reload and try again")
+
+// preconditionStatus maps a checkPrecondition error to an HTTP status code
+func preconditionStatus(err error) int {
+	if err == errStaleRecord {
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
+
+// checkPrecondition rejects an update when the client's view of a record is stale
+//
+// The client may send its known version or updatedAt in the payload, or either value
+// in an If-Match header. All checks are optional, so clients that send none of them
+// keep the previous last-write-wins behavior.
+func checkPrecondition(r *http.Request, clientVersion *int, clientUpdatedAt *string, storedVersion int, storedUpdatedAt string) error {
+	if ifMatch := strings.Trim(strings.TrimSpace(r.Header.Get("If-Match")), `"`); ifMatch != "" && ifMatch != "*" {
+		if version, err := strconv.Atoi(ifMatch); err == nil {
+			clientVersion = &version
+		} else {
+			clientUpdatedAt = &ifMatch
+		}
+	}
+
+	if clientVersion != nil && *clientVersion != storedVersion {
+		return errStaleRecord
+	}
+
+	if clientUpdatedAt != nil && *clientUpdatedAt != "" {
+		known, err := time.Parse(time.RFC3339, *clientUpdatedAt)
+		if err != nil {
+			return fmt.Errorf("invalid updatedAt: %v", err)
+		}
+		if stored, err := time.Parse(time.RFC3339, storedUpdatedAt); err == nil && stored.After(known) {
+			return errStaleRecord
+		}
+	}
+
+	return nil
+}
+
+// saveRequest handles POST requests to save a new request
+func (api *API) saveRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req SaveRequestPayload
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+
+	// Validate required fields
+	name, err := validateSavedRequest(req.Name, req.URL)
+	if err != nil {
+		respondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Name = name
+
+	// Load existing requests
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load saved requests", "error", err)
+		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
+		return
+	}
+
+	// Check for duplicate names (case-sensitive)
+	for _, existing := range data.Requests {
+		if existing.Name == req.Name {
+			respondWithError(w, fmt.Sprintf("Request name '%s' already exists. Please choose a different name.", req.Name), http.StatusConflict)
+			return
+		}
+	}
+
+	// Create new saved request
+	savedReq := newSavedRequest(req, time.Now().Format(time.RFC3339))
+
+	// Add to requests list
+	data.Requests = append(data.Requests, savedReq)
+	delete(data.Drafts, newRequestDraftID)
+
+	// Save to file
+	if err := api.store.Save(data); err != nil {
+		slog.Error("failed to save requests", "error", err)
+		respondWithError(w, "Failed to save request", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("saved request", "name", savedReq.Name, "method", savedReq.Method, "url", savedReq.URL)
+	recordAudit(r, "create", "request", savedReq.ID, savedReq.Name)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(savedReq); err != nil {
+		slog.Error("failed to encode saved request response", "error", err)
+	}
+}
+
+// findUpsertTarget returns the index of the live request an upsert payload refers to, or -1
+//
+// A payload with an externalId matches only on that key; otherwise the request is
+// matched by name within its group.
+func findUpsertTarget(requests []SavedRequest, req SaveRequestPayload) int {
+	for i, existing := range requests {
+		if existing.DeletedAt != "" {
+			continue
+		}
+		if req.ExternalID != "" {
+			if existing.ExternalID == req.ExternalID {
+				return i
+			}
+			continue
+		}
+		if existing.Name == req.Name && existing.Group == req.Group {
+			return i
+		}
+	}
+	return -1
+}
+
+// upsertRequest handles PUT requests that create a request or replace the matching one
+func (api *API) upsertRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req SaveRequestPayload
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+
+	name, err := validateSavedRequest(req.Name, req.URL)
+	if err != nil {
+		respondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Name = name
+	req.ExternalID = strings.TrimSpace(req.ExternalID)
+	if req.Group == "" {
+		req.Group = "default"
+	}
+
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load saved requests", "error", err)
+		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
+		return
+	}
+
+	target := findUpsertTarget(data.Requests, req)
+
+	// Names stay unique across all requests, so a match elsewhere is still a conflict
+	for i, existing := range data.Requests {
+		if i != target && existing.Name == req.Name {
+			respondWithError(w, fmt.Sprintf("Request name '%s' already exists. Please choose a different name.", req.Name), http.StatusConflict)
+			return
+		}
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	created := target < 0
+	var savedReq SavedRequest
+	if created {
+		savedReq = newSavedRequest(req, now)
+		data.Requests = append(data.Requests, savedReq)
+	} else {
+		existing := data.Requests[target]
+		savedReq = newSavedRequest(req, now)
+		savedReq.ID = existing.ID
+		savedReq.CreatedAt = existing.CreatedAt
+		savedReq.Version = existing.Version + 1
+		if savedReq.ExternalID == "" {
+			savedReq.ExternalID = existing.ExternalID
+		}
+		if savedReq.LastResponse == nil {
+			savedReq.LastResponse = existing.LastResponse
+		}
+		savedReq.StatusHistory = existing.StatusHistory
+		savedReq.Pinned = existing.Pinned
+		data.Requests[target] = savedReq
+		delete(data.Drafts, savedReq.ID)
+	}
+
+	if err := api.store.Save(data); err != nil {
+		slog.Error("failed to save requests", "error", err)
+		respondWithError(w, "Failed to save request", http.StatusInternalServerError)
+		return
+	}
+
+	status, action := http.StatusOK, "update"
+	if created {
+		status, action = http.StatusCreated, "create"
+		slog.Info("upsert created request", "name", savedReq.Name, "method", savedReq.Method, "url", savedReq.URL)
+	} else {
+		slog.Info("upsert updated request", "name", savedReq.Name, "method", savedReq.Method, "url", savedReq.URL)
+	}
+	recordAudit(r, action, "request", savedReq.ID, savedReq.Name)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(map[string]any{
+		"created": created,
+		"request": savedReq,
+	}); err != nil {
+		slog.Error("failed to encode upsert response", "error", err)
+	}
+}
+
+// BulkSaveResult reports the outcome of saving a single item in a bulk save
+type BulkSaveResult struct {
+	Index   int    `json:"index"`
+	Success bool   `json:"success"`
+	ID      string `json:"id,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// bulkSaveRequests handles POST requests to save many requests in a single write
+//
+// Accepts either a bare JSON array of requests or {"requests": [...], "atomic": true}.
+// Names are de-duplicated against existing requests and each other. Invalid items are
+// skipped and reported, unless atomic is set, in which case nothing is saved.
+func (api *API) bulkSaveRequests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var raw json.RawMessage
+	if !decodeJSONRequest(w, r, &raw) {
+		return
+	}
+
+	var req struct {
+		Requests []SaveRequestPayload `json:"requests"`
+		Atomic   bool                 `json:"atomic"`
+	}
+	var err error
+	if trimmed := strings.TrimSpace(string(raw)); strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal(raw, &req.Requests)
+	} else {
+		err = json.Unmarshal(raw, &req)
+	}
+	if err != nil {
+		slog.Error("invalid request body for bulk save", "error", err)
+		respondWithError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Requests) == 0 {
+		respondWithError(w, "At least one request is required", http.StatusBadRequest)
+		return
+	}
+
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load saved requests", "error", err)
+		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	results := make([]BulkSaveResult, 0, len(req.Requests))
+	taken := append([]SavedRequest(nil), data.Requests...)
+	var saved []SavedRequest
+	failed := 0
+
+	for i, item := range req.Requests {
+		name, err := validateSavedRequest(item.Name, item.URL)
+		if err != nil {
+			results = append(results, BulkSaveResult{Index: i, Name: item.Name, Error: err.Error()})
+			failed++
+			continue
+		}
+		item.Name = name
+
+		savedReq := newSavedRequest(item, now)
+		// Check against existing requests and those earlier in this batch
+		savedReq.Name = uniqueName(savedReq.Name, taken)
+		taken = append(taken, savedReq)
+		saved = append(saved, savedReq)
+		results = append(results, BulkSaveResult{Index: i, Success: true, ID: savedReq.ID, Name: savedReq.Name})
+	}
+
+	response := map[string]any{
+		"results": results,
+		"saved":   len(saved),
+		"failed":  failed,
+	}
+
+	if req.Atomic && failed > 0 {
+		// Nothing was written, so no item in the batch counts as saved
+		for i := range results {
+			results[i].Success = false
+			results[i].ID = ""
+		}
+		response["saved"] = 0
+		response["error"] = fmt.Sprintf("%d of %d requests failed validation; nothing was saved", failed, len(req.Requests))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	if len(saved) > 0 {
+		data.Requests = append(data.Requests, saved...)
+		if err := api.store.Save(data); err != nil {
+			slog.Error("failed to save bulk requests", "error", err)
+			respondWithError(w, "Failed to save requests", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	slog.Info("bulk saved requests", "saved", len(saved), "failed", failed)
+	for _, savedReq := range saved {
+		recordAudit(r, "create", "request", savedReq.ID, savedReq.Name)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.Error("failed to encode bulk save response", "error", err)
+	}
+}
+
+// updateRequest handles PUT requests to update an existing request
+func (api *API) updateRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	type UpdatePayload struct {
+		ID           string             `json:"id"`
+		Name         *string            `json:"name,omitempty"`
+		URL          *string            `json:"url,omitempty"`
+		Method       *string            `json:"method,omitempty"`
+		Headers      *map[string]string `json:"headers,omitempty"`
+		HeaderList   *[]QueryParam      `json:"headerList,omitempty"`
+		BodyType     *string            `json:"bodyType,omitempty"`
+		BodyText     *string            `json:"bodyText,omitempty"`
+		BodyFile     *string            `json:"bodyFile,omitempty"`
+		BodyBase64   *string            `json:"bodyBase64,omitempty"`
+		BodyJson     *[]BodyField       `json:"bodyJson,omitempty"`
+		BodyForm     *[]BodyField       `json:"bodyForm,omitempty"`
+		Params       *[]QueryParam      `json:"params,omitempty"`
+		PathParams   *[]QueryParam      `json:"pathParams,omitempty"`
+		Group        *string            `json:"group,omitempty"`
+		Description  *string            `json:"description,omitempty"`
+		Tags         *[]string          `json:"tags,omitempty"`
+		Overrides    *[]Variable        `json:"overrides,omitempty"`
+		PreRequest   *[]PreRequestStep  `json:"preRequest,omitempty"`
+		Template     *bool              `json:"template,omitempty"`
+		Strict       *bool              `json:"strictTemplates,omitempty"`
+		Auth         *AuthConfig        `json:"auth,omitempty"`
+		LastResponse *ProxyResponse     `json:"lastResponse,omitempty"`
+		Version      *int               `json:"version,omitempty"`   // Client's known version
+		UpdatedAt    *string            `json:"updatedAt,omitempty"` // Client's known UpdatedAt
+	}
+
+	var req UpdatePayload
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("invalid request body for update", "error", err)
+		respondWithError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate required identifier
+	if req.ID == "" {
+		respondWithError(w, "Request ID is required", http.StatusBadRequest)
+		return
+	}
+	// Validate if present
+	if req.Name != nil {
+		name, err := normalizeName("request", *req.Name)
+		if err != nil {
+			respondWithError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Name = &name
+	}
+	if req.URL != nil && *req.URL == "" {
+		respondWithError(w, "URL cannot be empty", http.StatusBadRequest)
+		return
+	}
+	if req.Group != nil && *req.Group == "" {
+		respondWithError(w, "Group cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	// Load existing requests
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load saved requests", "error", err)
+		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
+		return
+	}
+
+	// Check for duplicate names (case-sensitive, excluding the current request)
+	if req.Name != nil {
+		for _, existing := range data.Requests {
+			if existing.ID != req.ID && existing.Name == *req.Name {
+				respondWithError(w, fmt.Sprintf("Request name '%s' already exists. Please choose a different name.", *req.Name), http.StatusConflict)
+				return
+			}
+		}
+	}
+
+	// Find and update the request
+	found := false
+	var updated SavedRequest
+	for i, existing := range data.Requests {
+		if existing.ID == req.ID {
+			if err := checkPrecondition(r, req.Version, req.UpdatedAt, existing.Version, existing.UpdatedAt); err != nil {
+				respondWithError(w, err.Error(), preconditionStatus(err))
+				return
+			}
+			if req.Name != nil {
+				data.Requests[i].Name = *req.Name
+			}
+			if req.URL != nil {
+				data.Requests[i].URL = *req.URL
+			}
+			if req.Method != nil {
+				data.Requests[i].Method = *req.Method
+			}
+			if req.HeaderList != nil {
+				data.Requests[i].Headers, data.Requests[i].HeaderList = savedHeaders(nil, *req.HeaderList)
+			} else if req.Headers != nil && !maps.Equal(*req.Headers, headerMapFromList(data.Requests[i].HeaderList)) {
+				// A client that only knows the map sends it back unchanged on every save;
+				// only an actual change replaces the list, and with it any repeats
+				data.Requests[i].Headers, data.Requests[i].HeaderList = savedHeaders(*req.Headers, nil)
+			}
+			if req.BodyType != nil {
+				data.Requests[i].BodyType = *req.BodyType
+			}
+			if req.BodyText != nil {
+				data.Requests[i].BodyText = *req.BodyText
+			}
+			if req.BodyText != nil || req.BodyType != nil || req.Headers != nil || req.HeaderList != nil {
+				updated := &data.Requests[i]
+				updated.BodyText = prettifyJSONBody(updated.BodyType, updated.Headers, updated.BodyText)
+			}
+			if req.BodyFile != nil {
+				data.Requests[i].BodyFile = *req.BodyFile
+			}
+			if req.BodyBase64 != nil {
+				data.Requests[i].BodyBase64 = *req.BodyBase64
+			}
+			if req.BodyJson != nil {
+				data.Requests[i].BodyJson = *req.BodyJson
+			}
+			if req.BodyForm != nil {
+				data.Requests[i].BodyForm = *req.BodyForm
+			}
+			if req.Params != nil {
+				data.Requests[i].Params = *req.Params
+			}
+			if req.PathParams != nil {
+				data.Requests[i].PathParams = *req.PathParams
+			}
+			if req.Group != nil {
+				data.Requests[i].Group = *req.Group
+			}
+			if req.Description != nil {
+				data.Requests[i].Description = *req.Description
+			}
+			if req.Tags != nil {
+				data.Requests[i].Tags = normalizeTags(*req.Tags)
+			}
+			if req.Overrides != nil {
+				data.Requests[i].Overrides = *req.Overrides
+			}
+			if req.PreRequest != nil {
+				data.Requests[i].PreRequest = *req.PreRequest
+			}
+			if req.Template != nil {
+				data.Requests[i].Template = *req.Template
+			}
+			if req.Strict != nil {
+				data.Requests[i].Strict = *req.Strict
+			}
+			if req.Auth != nil {
+				// An auth object without a type removes authentication
+				if req.Auth.Type == "" {
+					data.Requests[i].Auth = nil
+				} else {
+					data.Requests[i].Auth = req.Auth
+				}
+			}
+			if req.LastResponse != nil {
+				data.Requests[i].LastResponse = req.LastResponse
+			}
+			data.Requests[i].UpdatedAt = time.Now().Format(time.RFC3339)
+			data.Requests[i].Version++
+			updated = data.Requests[i]
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		respondWithError(w, "Request not found", http.StatusNotFound)
+		return
+	}
+	delete(data.Drafts, updated.ID)
+
+	// Save to file
+	if err := api.store.Save(data); err != nil {
+		slog.Error("failed to save updated request", "error", err)
+		respondWithError(w, "Failed to save updated request", http.StatusInternalServerError)
+		return
+	}
+
+	recordAudit(r, "update", "request", updated.ID, updated.Name)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"status":    "updated",
+		"version":   updated.Version,
+		"updatedAt": updated.UpdatedAt,
+	})
+}
+
+// deleteRequest handles DELETE requests to delete a request
+func (api *API) deleteRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		ID string `json:"id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("invalid request body for delete", "error", err)
+		respondWithError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.ID == "" {
+		respondWithError(w, "Request ID is required", http.StatusBadRequest)
+		return
+	}
+
+	// Move the request to the trash
+	deleted, err := api.store.DeleteRequest(req.ID)
+	if errors.Is(err, errRequestNotFound) {
+		slog.Error("request not found", "id", req.ID)
+		respondWithError(w, "Request not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.Error("failed to save after deletion", "error", err)
+		respondWithError(w, "Failed to save after deletion", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("moved request to trash", "name", deleted.Name, "id", deleted.ID)
+
+	recordAudit(r, "delete", "request", deleted.ID, deleted.Name)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "deleted"})
+}
+
+// duplicateRequest handles POST requests to duplicate a request
+func (api *API) duplicateRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		ID           string `json:"id"`
+		TargetGroup  string `json:"targetGroup,omitempty"`  // Defaults to the original's group
+		NameTemplate string `json:"nameTemplate,omitempty"` // e.g. "{{original}} - v2"; defaults to "{{original}} (Copy)"
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("invalid request body for duplicate", "error", err)
+		respondWithError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.ID == "" {
+		respondWithError(w, "Request ID is required", http.StatusBadRequest)
+		return
+	}
+
+	// Load existing requests
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load saved requests", "error", err)
+		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
+		return
+	}
+
+	// Find the request to duplicate
+	var originalRequest *SavedRequest
+	for i := range data.Requests {
+		if data.Requests[i].ID == req.ID {
+			originalRequest = &data.Requests[i]
+			break
+		}
+	}
+
+	if originalRequest == nil {
+		respondWithError(w, "Request not found", http.StatusNotFound)
+		return
+	}
+
+	group := originalRequest.Group
+	if req.TargetGroup != "" {
+		exists := false
+		for _, g := range data.Groups {
+			if g.Name == req.TargetGroup {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			respondWithError(w, fmt.Sprintf("Target group not found: %s", req.TargetGroup), http.StatusNotFound)
+			return
+		}
+		group = req.TargetGroup
+	}
+
+	nameTemplate := req.NameTemplate
+	if strings.TrimSpace(nameTemplate) == "" {
+		nameTemplate = "{{original}} (Copy)"
+	}
+	baseName, err := normalizeName("request", strings.ReplaceAll(nameTemplate, "{{original}}", originalRequest.Name))
+	if err != nil {
+		respondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Create duplicate with unique name
+	now := time.Now().Format(time.RFC3339)
+	uniqueName := uniqueName(baseName, data.Requests)
+	duplicatedReq := SavedRequest{
+		ID:           generateID(),
+		Name:         uniqueName,
+		URL:          originalRequest.URL,
+		Method:       originalRequest.Method,
+		Headers:      make(map[string]string),
+		BodyType:     originalRequest.BodyType,
+		BodyText:     originalRequest.BodyText,
+		BodyFile:     originalRequest.BodyFile,
+		BodyBase64:   originalRequest.BodyBase64,
+		BodyJson:     make([]BodyField, len(originalRequest.BodyJson)),
+		BodyForm:     make([]BodyField, len(originalRequest.BodyForm)),
+		Params:       make([]QueryParam, len(originalRequest.Params)),
+		PathParams:   append([]QueryParam(nil), originalRequest.PathParams...),
+		Group:        group,
+		Description:  originalRequest.Description,
+		Tags:         append([]string(nil), originalRequest.Tags...),
+		Overrides:    append([]Variable(nil), originalRequest.Overrides...),
+		PreRequest:   make([]PreRequestStep, len(originalRequest.PreRequest)),
+		Template:     originalRequest.Template,
+		Strict:       originalRequest.Strict,
+		Auth:         copyAuthConfig(originalRequest.Auth),
+		LastResponse: nil, // Don't copy response
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	// Deep copy headers
+	for k, v := range originalRequest.Headers {
+		duplicatedReq.Headers[k] = v
+	}
+	duplicatedReq.HeaderList = append([]QueryParam(nil), originalRequest.HeaderList...)
+
+	// Deep copy params
+	copy(duplicatedReq.Params, originalRequest.Params)
+
+	// Deep copy body fields
+	copy(duplicatedReq.BodyJson, originalRequest.BodyJson)
+	copy(duplicatedReq.BodyForm, originalRequest.BodyForm)
+	copy(duplicatedReq.PreRequest, originalRequest.PreRequest)
+
+	// Add to requests list
+	data.Requests = append(data.Requests, duplicatedReq)
+
+	// Save to file
+	if err := api.store.Save(data); err != nil {
+		slog.Error("failed to save duplicated request", "error", err)
+		respondWithError(w, "Failed to save duplicated request", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("duplicated request", "from", originalRequest.Name, "to", duplicatedReq.Name)
+	recordAudit(r, "create", "request", duplicatedReq.ID, duplicatedReq.Name)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(duplicatedReq); err != nil {
+		slog.Error("failed to encode duplicated request response", "error", err)
+	}
+}
+
+// pinRequest handles POST requests toggling whether a saved request is pinned
+//
+// Pinning only changes where the request is listed, so it leaves the version and
+// updatedAt alone: a client editing the request can still save its changes.
+func (api *API) pinRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var saved SavedRequest
+	err := api.store.UpdateRequest(chi.URLParam(r, "id"), func(request *SavedRequest) error {
+		request.Pinned = !request.Pinned
+		saved = *request
+		return nil
+	})
+	if errors.Is(err, errRequestNotFound) {
+		respondWithError(w, "Request not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.Error("failed to save pinned state", "error", err)
+		respondWithError(w, "Failed to save request", http.StatusInternalServerError)
+		return
+	}
+
+	action := "unpin"
+	if saved.Pinned {
+		action = "pin"
+		slog.Info("pinned request", "name", saved.Name)
+	} else {
+		slog.Info("unpinned request", "name", saved.Name)
+	}
+	recordAudit(r, action, "request", saved.ID, saved.Name)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(saved); err != nil {
+		slog.Error("failed to encode pinned request", "error", err)
+	}
+}
+
+// fillTemplate replaces {{name}} placeholders with the supplied values
+func fillTemplate(input string, values map[string]string) string {
+	for key, value := range values {
+		input = strings.ReplaceAll(input, "{{"+key+"}}", value)
+	}
+	return input
+}
+
+// instantiateTemplate handles POST requests to create a concrete request from a template
+//
+// Placeholders listed in values are filled in; any others are left as {{var}} so they
+// still resolve from the active environment when the request is sent.
+func (api *API) instantiateTemplate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	templateID := chi.URLParam(r, "id")
+
+	var req struct {
+		Name   string            `json:"name"`
+		Group  string            `json:"group"`
+		Values map[string]string `json:"values"`
+	}
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load saved requests", "error", err)
+		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
+		return
+	}
+
+	var tmpl *SavedRequest
+	for i := range data.Requests {
+		if data.Requests[i].ID == templateID {
+			tmpl = &data.Requests[i]
+			break
+		}
+	}
+
+	if tmpl == nil {
+		respondWithError(w, "Request not found", http.StatusNotFound)
+		return
+	}
+	if !tmpl.Template {
+		respondWithError(w, "Request is not a template", http.StatusBadRequest)
+		return
+	}
+
+	name := req.Name
+	if name == "" {
+		name = tmpl.Name
+	}
+	group := req.Group
+	if group == "" {
+		group = tmpl.Group
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	instance := SavedRequest{
+		ID:          generateID(),
+		Name:        uniqueName(name, data.Requests),
+		URL:         fillTemplate(tmpl.URL, req.Values),
+		Method:      tmpl.Method,
+		Headers:     make(map[string]string, len(tmpl.Headers)),
+		BodyType:    tmpl.BodyType,
+		BodyText:    fillTemplate(tmpl.BodyText, req.Values),
+		BodyFile:    fillTemplate(tmpl.BodyFile, req.Values),
+		BodyBase64:  tmpl.BodyBase64,
+		Group:       group,
+		Description: tmpl.Description,
+		Tags:        append([]string(nil), tmpl.Tags...),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	for k, v := range tmpl.Headers {
+		instance.Headers[fillTemplate(k, req.Values)] = fillTemplate(v, req.Values)
+	}
+	for _, h := range tmpl.HeaderList {
+		h.Key = fillTemplate(h.Key, req.Values)
+		h.Value = fillTemplate(h.Value, req.Values)
+		instance.HeaderList = append(instance.HeaderList, h)
+	}
+	for _, p := range tmpl.Params {
+		p.Key = fillTemplate(p.Key, req.Values)
+		p.Value = fillTemplate(p.Value, req.Values)
+		instance.Params = append(instance.Params, p)
+	}
+	for _, p := range tmpl.PathParams {
+		p.Value = fillTemplate(p.Value, req.Values)
+		instance.PathParams = append(instance.PathParams, p)
+	}
+	for _, f := range tmpl.BodyJson {
+		f.Key = fillTemplate(f.Key, req.Values)
+		f.Value = fillTemplate(f.Value, req.Values)
+		instance.BodyJson = append(instance.BodyJson, f)
+	}
+	for _, f := range tmpl.BodyForm {
+		f.Key = fillTemplate(f.Key, req.Values)
+		f.Value = fillTemplate(f.Value, req.Values)
+		instance.BodyForm = append(instance.BodyForm, f)
+	}
+	for _, v := range tmpl.Overrides {
+		v.Value = fillTemplate(v.Value, req.Values)
+		instance.Overrides = append(instance.Overrides, v)
+	}
+	for _, step := range tmpl.PreRequest {
+		step.Value = fillTemplate(step.Value, req.Values)
+		instance.PreRequest = append(instance.PreRequest, step)
+	}
+	if instance.Auth = copyAuthConfig(tmpl.Auth); instance.Auth != nil && instance.Auth.OAuth2 != nil {
+		oauth := instance.Auth.OAuth2
+		oauth.TokenURL = fillTemplate(oauth.TokenURL, req.Values)
+		oauth.ClientID = fillTemplate(oauth.ClientID, req.Values)
+		oauth.ClientSecret = fillTemplate(oauth.ClientSecret, req.Values)
+		oauth.Scope = fillTemplate(oauth.Scope, req.Values)
+	}
+
+	data.Requests = append(data.Requests, instance)
+
+	if err := api.store.Save(data); err != nil {
+		slog.Error("failed to save instantiated request", "error", err)
+		respondWithError(w, "Failed to save instantiated request", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("instantiated template", "template", tmpl.Name, "name", instance.Name)
+	recordAudit(r, "create", "request", instance.ID, instance.Name)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(instance); err != nil {
+		slog.Error("failed to encode instantiated request response", "error", err)
+	}
+}
+
+// VariableUsage describes one variable a saved request depends on
+type VariableUsage struct {
+	Name       string `json:"name"`
+	Defined    bool   `json:"defined"`
+	Source     string `json:"source,omitempty"`     // Defining layer: override, preRequest, environment, parent:<name> or global
+	HasDefault bool   `json:"hasDefault,omitempty"` // Every use has a {{name|default}} fallback
+	Via        string `json:"via,omitempty"`        // Variable whose value refers to this one
+	Note       string `json:"note,omitempty"`
+}
+
+// requestVariables handles GET requests listing the variables a saved request uses
+//
+// Placeholders are collected from everything templates apply to (URL, path parameters,
+// headers, bodies, OAuth2 settings and pre-request step values) and checked against the
+// request's overrides, its setVariable steps and the environment's scope: ?environment=
+// (an ID) or the active one. Variables referenced from the values of used variables are
+// listed too, with the referring variable in "via".
+func (api *API) requestVariables(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load saved requests", "error", err)
+		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
+		return
+	}
+
+	requestID := chi.URLParam(r, "id")
+	var saved *SavedRequest
+	for i := range data.Requests {
+		if data.Requests[i].ID == requestID {
+			saved = &data.Requests[i]
+			break
+		}
+	}
+	if saved == nil {
+		respondWithError(w, "Request not found", http.StatusNotFound)
+		return
+	}
+
+	var env *Environment
+	if envID := r.URL.Query().Get("environment"); envID != "" {
+		if env = findEnvironment(data, envID); env == nil {
+			respondWithError(w, "Environment not found", http.StatusNotFound)
+			return
+		}
+	} else if env, err = getActiveEnvironment(r, data); err != nil {
+		slog.Error("failed to get current environment", "error", err)
+		respondWithError(w, "Failed to get current environment", http.StatusInternalServerError)
+		return
+	}
+
+	req := proxyRequestFromSaved(*saved, nil)
+	placeholders := collectPlaceholders(req)
+	var stepVariables []Variable
+	for _, step := range saved.PreRequest {
+		if step.Type == "runRequest" {
+			continue
+		}
+		placeholders = append(placeholders, responseVarPattern.FindAllString(step.Value, -1)...)
+		if step.Type == "setVariable" {
+			stepVariables = append(stepVariables, Variable{Key: step.Key, Value: step.Value})
+		}
+	}
+
+	usages := []VariableUsage{}
+	index := make(map[string]int)
+	dynamic, responseRefs := []string{}, []string{}
+	seen := make(map[string]bool)
+	for _, placeholder := range placeholders {
+		content := strings.TrimSpace(placeholder[2 : len(placeholder)-2])
+		switch {
+		case seen[placeholder]:
+			continue
+		case strings.HasPrefix(content, "$"):
+			dynamic = append(dynamic, placeholder)
+		case strings.Contains(content, "\"") || isIDReference(content):
+			responseRefs = append(responseRefs, placeholder)
+		default:
+			name, _, hasDefault, _, piped := parsePipes(content)
+			if !piped {
+				name = content
+			}
+			if name == "" || strings.ContainsAny(name, "{}\"") {
+				continue
+			}
+			if i, ok := index[name]; ok {
+				usages[i].HasDefault = usages[i].HasDefault && hasDefault
+			} else {
+				index[name] = len(usages)
+				usages = append(usages, VariableUsage{Name: name, HasDefault: hasDefault})
+			}
+		}
+		seen[placeholder] = true
+	}
+
+	layers := append([]variableLayer{{"override", saved.Overrides}, {"preRequest", stepVariables}}, scopeLayers(data, env)...)
+	undefined := []string{}
+	// usages grows as values referring to other variables are found
+	for i := 0; i < len(usages); i++ {
+		usage := &usages[i]
+		for _, layer := range layers {
+			value, found := "", false
+			for _, variable := range layer.variables {
+				if variable.Key == usage.Name {
+					value, found = variable.Value, true
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+			usage.Source = layer.source
+			if _, ok := lookupVariable(usage.Name, layer.variables); !ok {
+				usage.Note = fmt.Sprintf("%s is not set in the server's environment", value)
+				break
+			}
+			usage.Defined = true
+			for _, ref := range variableRefPattern.FindAllStringSubmatch(value, -1) {
+				name := strings.TrimSpace(ref[1])
+				if _, listed := index[name]; !listed {
+					index[name] = len(usages)
+					usages = append(usages, VariableUsage{Name: name, Via: usages[i].Name})
+					usage = &usages[i] // append may have moved the slice
+				}
+			}
+			break
+		}
+		if !usage.Defined && !usage.HasDefault {
+			undefined = append(undefined, usage.Name)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]any{
+		"requestId":          saved.ID,
+		"name":               saved.Name,
+		"environment":        env.Name,
+		"variables":          usages,
+		"undefined":          undefined,
+		"responseReferences": responseRefs,
+		"dynamic":            dynamic,
+	}); err != nil {
+		slog.Error("failed to encode request variables", "error", err)
+	}
+}
+
+// =============================================================================
+// HAR EXPORT
+// =============================================================================
+
+// HARLog is the top-level "log" object of an HTTP Archive (HAR 1.2) document
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+// HARCreator identifies the application that produced the archive
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry is a single request/response exchange
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            int64       `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Comment         string      `json:"comment,omitempty"` // Saved request name
+}
+
+// HARRequest describes the outbound request of an entry
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARResponse describes the response of an entry
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARNameValue is a name/value pair used for headers, cookies and query strings
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARPostData describes a request body
+type HARPostData struct {
+	MimeType string         `json:"mimeType"`
+	Text     string         `json:"text"`
+	Params   []HARNameValue `json:"params,omitempty"`
+}
+
+// HARContent describes a response body
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// HARTimings breaks down the entry time; phases that weren't measured are -1
+type HARTimings struct {
+	Blocked int64 `json:"blocked"`
+	DNS     int64 `json:"dns"`
+	Connect int64 `json:"connect"`
+	Send    int64 `json:"send"`
+	Wait    int64 `json:"wait"`
+	Receive int64 `json:"receive"`
+	SSL     int64 `json:"ssl"`
+}
+
+// harHTTPVersion is reported for responses recorded before the protocol was; newer ones
+// use the response's httpVersion for both sides, as they share a connection
+const harHTTPVersion = "HTTP/1.1"
+
+// harNameValues converts a header map into a HAR list sorted by name
+func harNameValues(m map[string]string) []HARNameValue {
+	pairs := make([]HARNameValue, 0, len(m))
+	for name, value := range m {
+		pairs = append(pairs, HARNameValue{Name: name, Value: value})
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Name < pairs[j].Name })
+	return pairs
+}
+
+// harQueryString extracts the query parameters of a URL in the order they appear
+func harQueryString(rawURL string) []HARNameValue {
+	pairs := []HARNameValue{}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.RawQuery == "" {
+		return pairs
+	}
+	for _, part := range strings.Split(u.RawQuery, "&") {
+		if part == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(part, "=")
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			name = key
+		}
+		decoded, err := url.QueryUnescape(value)
+		if err != nil {
+			decoded = value
+		}
+		pairs = append(pairs, HARNameValue{Name: name, Value: decoded})
+	}
+	return pairs
+}
+
+// harPostData rebuilds the request body the way makeHTTPRequest sends it
+func harPostData(req ProxyRequest) *HARPostData {
+	mimeType := headerValue(req.Headers, "Content-Type")
+
+	switch {
+	case req.BodyType == "json" && len(req.BodyJson) > 0:
+		jsonObj, err := buildJSONFromBodyFields(req.BodyJson)
+		if err != nil {
+			return nil
+		}
+		jsonBytes, err := json.Marshal(jsonObj)
+		if err != nil {
+			return nil
+		}
+		if mimeType == "" {
+			mimeType = "application/json"
+		}
+		return &HARPostData{MimeType: mimeType, Text: string(jsonBytes)}
+	case req.BodyType == "form" && len(req.BodyForm) > 0:
+		if mimeType == "" {
+			mimeType = "application/x-www-form-urlencoded"
+		}
+		postData := &HARPostData{MimeType: mimeType, Text: buildFormEncoded(req.BodyForm)}
+		for _, f := range req.BodyForm {
+			if f.Enabled && f.Key != "" {
+				postData.Params = append(postData.Params, HARNameValue{Name: f.Key, Value: f.Value})
+			}
+		}
+		return postData
+	case req.BodyType == "binary":
+		if mimeType == "" {
+			mimeType = "application/octet-stream"
+		}
+		// Binary payloads aren't embedded; the source is noted instead
+		text := req.BodyFile
+		if text == "" && req.BodyBase64 != "" {
+			text = "(base64 payload)"
+		}
+		return &HARPostData{MimeType: mimeType, Text: text}
+	case req.BodyType != "json" && req.Body != "":
+		return &HARPostData{MimeType: mimeType, Text: req.Body}
+	}
+	return nil
+}
+
+// harContentText renders a parsed response body back to text
+func harContentText(body any) string {
+	switch v := body.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(data)
+	}
+}
+
+// buildHAREntry converts a saved request and its last response into a HAR entry
+//
+// The request echoed with the response is preferred since it reflects resolved
+// variables; older responses without an echo fall back to the saved definition.
+func buildHAREntry(saved SavedRequest, variables []Variable) HAREntry {
+	resp := saved.LastResponse
+
+	req := proxyRequestFromSaved(saved, nil)
+	if resp.Request != nil {
+		req = *resp.Request
+	}
+	// Echoes saved with ?reveal=true still hold secret values
+	req = redactRequest(req, variables)
+
+	requestHeaders := harNameValues(req.Headers)
+	postData := harPostData(req)
+	bodySize := 0
+	if postData != nil {
+		bodySize = len(postData.Text)
+	}
+
+	text := resp.RawBody
+	if text == "" {
+		text = harContentText(resp.Body)
+	}
+	size := resp.SizeBytes
+	if size == 0 {
+		size = len(text)
+	}
+	mimeType := headerValue(resp.Headers, "Content-Type")
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+
+	startedAt := resp.StartedAt
+	if startedAt == "" {
+		startedAt = saved.UpdatedAt
+	}
+	var serverIP string
+	if resp.Connection != nil {
+		serverIP, _, _ = net.SplitHostPort(resp.Connection.RemoteAddr)
+	}
+	httpVersion := resp.HTTPVersion
+	if httpVersion == "" {
+		httpVersion = harHTTPVersion
+	}
+
+	return HAREntry{
+		StartedDateTime: startedAt,
+		Time:            resp.DurationMs,
+		Request: HARRequest{
+			Method:      req.Method,
+			URL:         req.URL,
+			HTTPVersion: httpVersion,
+			Cookies:     []HARNameValue{},
+			Headers:     requestHeaders,
+			QueryString: harQueryString(req.URL),
+			PostData:    postData,
+			HeadersSize: -1,
+			BodySize:    bodySize,
+		},
+		Response: HARResponse{
+			Status:      resp.StatusCode,
+			StatusText:  strings.TrimSpace(strings.TrimPrefix(resp.Status, strconv.Itoa(resp.StatusCode))),
+			HTTPVersion: httpVersion,
+			Cookies:     []HARNameValue{},
+			Headers:     harNameValues(resp.Headers),
+			Content: HARContent{
+				Size:     size,
+				MimeType: mimeType,
+				Text:     text,
+			},
+			RedirectURL: headerValue(resp.Headers, "Location"),
+			HeadersSize: -1,
+			BodySize:    size,
+		},
+		Timings:         harTimings(resp),
+		ServerIPAddress: serverIP,
+		Comment:         saved.Name,
+	}
+}
+
+// harTimings fills in HAR timings from a response's phase breakdown, when it has one;
+// responses saved before timings were recorded count all their time as waiting
+func harTimings(resp *ProxyResponse) HARTimings {
+	t := resp.Timings
+	if t == nil {
+		return HARTimings{Blocked: -1, DNS: -1, Connect: -1, Send: 0, Wait: resp.DurationMs, Receive: 0, SSL: -1}
+	}
+	// HAR's connect includes the TLS handshake, and wait is what's left of the first byte
+	setup := t.DNSMs + t.ConnectMs + t.TLSMs
+	return HARTimings{
+		Blocked: -1,
+		DNS:     int64(math.Round(t.DNSMs)),
+		Connect: int64(math.Round(t.ConnectMs + t.TLSMs)),
+		SSL:     int64(math.Round(t.TLSMs)),
+		Send:    0,
+		Wait:    int64(math.Round(max(t.TTFBMs-setup, 0))),
+		Receive: int64(math.Round(max(t.TotalMs-t.TTFBMs, 0))),
+	}
+}
+
+// newHARLog wraps entries in a HAR 1.2 log
+func newHARLog(entries []HAREntry) map[string]HARLog {
+	return map[string]HARLog{
+		"log": {
+			Version: "1.2",
+			Creator: HARCreator{Name: "go-rest", Version: "1.0"},
+			Entries: entries,
+		},
+	}
+}
+
+// requestHAR handles GET requests to export a request's last exchange as HAR
+func (api *API) requestHAR(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	requestID := chi.URLParam(r, "id")
+
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load saved requests", "error", err)
+		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
+		return
+	}
+
+	var saved *SavedRequest
+	for i := range data.Requests {
+		if data.Requests[i].ID == requestID {
+			saved = &data.Requests[i]
+			break
+		}
+	}
+
+	if saved == nil {
+		respondWithError(w, "Request not found", http.StatusNotFound)
+		return
+	}
+	if saved.LastResponse == nil {
+		respondWithError(w, "Request has no recorded response", http.StatusNotFound)
+		return
+	}
+
+	slog.Info("exporting HAR", "name", saved.Name, "id", saved.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(newHARLog([]HAREntry{buildHAREntry(*saved, workspaceSecrets(data))})); err != nil {
+		slog.Error("failed to encode HAR response", "error", err)
+	}
+}
+
+// exportHAR handles GET requests to export every recorded exchange as a HAR log
+func (api *API) exportHAR(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load saved requests", "error", err)
+		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
+		return
+	}
+
+	entries := []HAREntry{}
+	secrets := workspaceSecrets(data)
+	for _, saved := range data.Requests {
+		if saved.LastResponse == nil || saved.LastResponse.Error != "" {
+			continue
+		}
+		entries = append(entries, buildHAREntry(saved, secrets))
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].StartedDateTime < entries[j].StartedDateTime
+	})
+
+	slog.Info("exporting HAR log", "entries", len(entries))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="go-rest.har"`)
+	if err := json.NewEncoder(w).Encode(newHARLog(entries)); err != nil {
+		slog.Error("failed to encode HAR export", "error", err)
+	}
+}
+
+// =============================================================================
+// CODE SNIPPETS
+// =============================================================================
+
+// snippetBody is a request body in a form the snippet generators can render idiomatically
+type snippetBody struct {
+	Kind   string         // "", "text", "json", "form", "file" or "base64"
+	Text   string         // Raw text body
+	JSON   any            // Parsed JSON body
+	Form   []HARNameValue // Form fields in order
+	Path   string         // File streamed from disk
+	Base64 string         // Base64-encoded binary content
+}
+
+// snippetGenerator renders a resolved request as source code in one language
+type snippetGenerator func(req ProxyRequest, body snippetBody) string
+
+// snippetGenerators maps a language name (and its library-qualified alias) to its generator
+var snippetGenerators = map[string]snippetGenerator{
+	"python":           pythonSnippet,
+	"python-requests":  pythonSnippet,
+	"javascript":       javascriptSnippet,
+	"javascript-fetch": javascriptSnippet,
+	"go":               goSnippet,
+	"go-nethttp":       goSnippet,
+}
+
+// newSnippetBody extracts the body of a resolved request, adding the Content-Type
+// header makeHTTPRequest would set when it's missing
+func newSnippetBody(req *ProxyRequest) (snippetBody, error) {
+	setDefault := func(contentType string) {
+		setDefaultHeader(req.Headers, "Content-Type", contentType)
+	}
+
+	switch {
+	case req.BodyType == "json" && len(req.BodyJson) > 0:
+		jsonObj, err := buildJSONFromBodyFields(req.BodyJson)
+		if err != nil {
+			return snippetBody{}, err
+		}
+		setDefault("application/json")
+		return snippetBody{Kind: "json", JSON: jsonObj}, nil
+	case req.BodyType == "form" && len(req.BodyForm) > 0:
+		body := snippetBody{Kind: "form"}
+		for _, f := range req.BodyForm {
+			if f.Enabled && f.Key != "" {
+				body.Form = append(body.Form, HARNameValue{Name: f.Key, Value: f.Value})
+			}
+		}
+		setDefault("application/x-www-form-urlencoded")
+		return body, nil
+	case req.BodyType == "binary" && req.BodyFile != "":
+		setDefault("application/octet-stream")
+		return snippetBody{Kind: "file", Path: req.BodyFile}, nil
+	case req.BodyType == "binary" && req.BodyBase64 != "":
+		setDefault("application/octet-stream")
+		return snippetBody{Kind: "base64", Base64: req.BodyBase64}, nil
+	case req.BodyType != "json" && req.BodyType != "binary" && req.Body != "":
+		return snippetBody{Kind: "text", Text: req.Body}, nil
+	}
+	return snippetBody{}, nil
+}
+
+// sortedHeaderNames returns header names in a stable order for generated code
+func sortedHeaderNames(headers map[string]string) []string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// quoteString renders a double-quoted string literal valid in Python, JavaScript and JSON
+func quoteString(value string) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// indentJSON renders a JSON value over several lines with the given indent on
+// continuation lines
+func indentJSON(value any, indent string) string {
+	data, err := json.MarshalIndent(value, indent, "  ")
+	if err != nil {
+		return "null"
+	}
+	return string(data)
+}
+
+// pythonLiteral renders a decoded JSON value as a Python literal
+func pythonLiteral(value any, indent string) string {
+	inner := indent + "    "
+	switch v := value.(type) {
+	case nil:
+		return "None"
+	case bool:
+		if v {
+			return "True"
+		}
+		return "False"
+	case string:
+		return quoteString(v)
+	case float64, int, int64:
+		return fmt.Sprintf("%v", v)
+	case []any:
+		if len(v) == 0 {
+			return "[]"
+		}
+		var sb strings.Builder
+		sb.WriteString("[\n")
+		for _, item := range v {
+			sb.WriteString(inner + pythonLiteral(item, inner) + ",\n")
+		}
+		sb.WriteString(indent + "]")
+		return sb.String()
+	case orderedObject:
+		if len(v) == 0 {
+			return "{}"
+		}
+		var sb strings.Builder
+		sb.WriteString("{\n")
+		for _, member := range v {
+			sb.WriteString(inner + quoteString(member.Key) + ": " + pythonLiteral(member.Value, inner) + ",\n")
+		}
+		sb.WriteString(indent + "}")
+		return sb.String()
+	case map[string]any:
+		if len(v) == 0 {
+			return "{}"
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var sb strings.Builder
+		sb.WriteString("{\n")
+		for _, k := range keys {
+			sb.WriteString(inner + quoteString(k) + ": " + pythonLiteral(v[k], inner) + ",\n")
+		}
+		sb.WriteString(indent + "}")
+		return sb.String()
+	default:
+		// Values not produced by encoding/json (e.g. typed slices) are normalized first
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "None"
+		}
+		var decoded any
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			return "None"
+		}
+		return pythonLiteral(decoded, indent)
+	}
+}
+
+// pythonSnippet generates code using the requests library
+func pythonSnippet(req ProxyRequest, body snippetBody) string {
+	var sb strings.Builder
+	if body.Kind == "base64" {
+		sb.WriteString("import base64\n\n")
+	}
+	sb.WriteString("import requests\n\n")
+	sb.WriteString("url = " + quoteString(req.URL) + "\n")
+
+	args := []string{"url"}
+	if len(req.Headers) > 0 {
+		sb.WriteString("headers = {\n")
+		for _, name := range sortedHeaderNames(req.Headers) {
+			sb.WriteString("    " + quoteString(name) + ": " + quoteString(req.Headers[name]) + ",\n")
+		}
+		sb.WriteString("}\n")
+		args = append(args, "headers=headers")
+	}
+
+	switch body.Kind {
+	case "json":
+		sb.WriteString("payload = " + pythonLiteral(body.JSON, "") + "\n")
+		args = append(args, "json=payload")
+	case "form":
+		sb.WriteString("payload = [\n")
+		for _, f := range body.Form {
+			sb.WriteString("    (" + quoteString(f.Name) + ", " + quoteString(f.Value) + "),\n")
+		}
+		sb.WriteString("]\n")
+		args = append(args, "data=payload")
+	case "text":
+		sb.WriteString("payload = " + quoteString(body.Text) + "\n")
+		args = append(args, "data=payload")
+	case "base64":
+		sb.WriteString("payload = base64.b64decode(" + quoteString(body.Base64) + ")\n")
+		args = append(args, "data=payload")
+	}
+
+	sb.WriteString("\n")
+	call := "requests.request(" + quoteString(req.Method) + ", " + strings.Join(args, ", ") + ")"
+	switch req.Method {
+	case "GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS":
+		call = "requests." + strings.ToLower(req.Method) + "(" + strings.Join(args, ", ") + ")"
+	}
+	if body.Kind == "file" {
+		sb.WriteString("with open(" + quoteString(body.Path) + ", \"rb\") as payload:\n")
+		call = strings.TrimSuffix(call, ")") + ", data=payload)"
+		sb.WriteString("    response = " + call + "\n")
+	} else {
+		sb.WriteString("response = " + call + "\n")
+	}
+
+	sb.WriteString("\nprint(response.status_code)\nprint(response.text)\n")
+	return sb.String()
+}
+
+// javascriptSnippet generates code using fetch (Node.js 18+ as an ES module)
+func javascriptSnippet(req ProxyRequest, body snippetBody) string {
+	var sb strings.Builder
+	if body.Kind == "file" {
+		sb.WriteString("import { readFileSync } from \"node:fs\";\n\n")
+	}
+
+	sb.WriteString("const response = await fetch(" + quoteString(req.URL) + ", {\n")
+	sb.WriteString("  method: " + quoteString(req.Method))
+	if len(req.Headers) > 0 {
+		sb.WriteString(",\n  headers: {\n")
+		names := sortedHeaderNames(req.Headers)
+		for i, name := range names {
+			sb.WriteString("    " + quoteString(name) + ": " + quoteString(req.Headers[name]))
+			if i < len(names)-1 {
+				sb.WriteString(",")
+			}
+			sb.WriteString("\n")
+		}
+		sb.WriteString("  }")
+	}
+
+	switch body.Kind {
+	case "json":
+		sb.WriteString(",\n  body: JSON.stringify(" + indentJSON(body.JSON, "  ") + ")")
+	case "form":
+		sb.WriteString(",\n  body: new URLSearchParams([\n")
+		for i, f := range body.Form {
+			sb.WriteString("    [" + quoteString(f.Name) + ", " + quoteString(f.Value) + "]")
+			if i < len(body.Form)-1 {
+				sb.WriteString(",")
+			}
+			sb.WriteString("\n")
+		}
+		sb.WriteString("  ])")
+	case "text":
+		sb.WriteString(",\n  body: " + quoteString(body.Text))
+	case "file":
+		sb.WriteString(",\n  body: readFileSync(" + quoteString(body.Path) + ")")
+	case "base64":
+		sb.WriteString(",\n  body: Buffer.from(" + quoteString(body.Base64) + ", \"base64\")")
+	}
+	sb.WriteString("\n});\n\n")
+
+	sb.WriteString("console.log(response.status);\nconsole.log(await response.text());\n")
+	return sb.String()
+}
+
+// goStringLiteral prefers a raw string literal for readability when the value allows it
+func goStringLiteral(value string) string {
+	if strings.Contains(value, "\n") && !strings.ContainsAny(value, "`\r") {
+		return "`" + value + "`"
+	}
+	return strconv.Quote(value)
+}
+
+// goSnippet generates a standalone program using net/http
+func goSnippet(req ProxyRequest, body snippetBody) string {
+	imports := []string{"fmt", "io", "net/http"}
+	var setup []string
+	bodyExpr := "nil"
+
+	switch body.Kind {
+	case "json":
+		imports = append(imports, "strings")
+		bodyExpr = "strings.NewReader(" + goStringLiteral(indentJSON(body.JSON, "")) + ")"
+	case "form":
+		imports = append(imports, "net/url", "strings")
+		setup = append(setup, "form := url.Values{}")
+		for _, f := range body.Form {
+			setup = append(setup, "form.Add("+strconv.Quote(f.Name)+", "+strconv.Quote(f.Value)+")")
+		}
+		setup = append(setup, "")
+		bodyExpr = "strings.NewReader(form.Encode())"
+	case "text":
+		imports = append(imports, "strings")
+		bodyExpr = "strings.NewReader(" + goStringLiteral(body.Text) + ")"
+	case "file":
+		imports = append(imports, "os")
+		setup = append(setup,
+			"file, err := os.Open("+strconv.Quote(body.Path)+")",
+			"if err != nil {",
+			"\tpanic(err)",
+			"}",
+			"defer file.Close()",
+			"")
+		bodyExpr = "file"
+	case "base64":
+		imports = append(imports, "bytes", "encoding/base64")
+		setup = append(setup,
+			"payload, err := base64.StdEncoding.DecodeString("+strconv.Quote(body.Base64)+")",
+			"if err != nil {",
+			"\tpanic(err)",
+			"}",
+			"")
+		bodyExpr = "bytes.NewReader(payload)"
+	}
+	sort.Strings(imports)
+
+	var sb strings.Builder
+	sb.WriteString("package main\n\nimport (\n")
+	for _, imp := range imports {
+		sb.WriteString("\t" + strconv.Quote(imp) + "\n")
+	}
+	sb.WriteString(")\n\nfunc main() {\n")
+	for _, line := range setup {
+		if line == "" {
+			sb.WriteString("\n")
+			continue
+		}
+		sb.WriteString("\t" + line + "\n")
+	}
+
+	sb.WriteString("\treq, err := http.NewRequest(" + strconv.Quote(req.Method) + ", " + strconv.Quote(req.URL) + ", " + bodyExpr + ")\n")
+	sb.WriteString("\tif err != nil {\n\t\tpanic(err)\n\t}\n")
+	for _, name := range sortedHeaderNames(req.Headers) {
+		sb.WriteString("\treq.Header.Set(" + strconv.Quote(name) + ", " + strconv.Quote(req.Headers[name]) + ")\n")
+	}
+
+	sb.WriteString(`
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		panic(err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(resp.Status)
+	fmt.Println(string(data))
+}
+`)
+	return sb.String()
+}
+
+// requestSnippet handles GET requests to generate a code snippet for a saved request
+//
+// Variables from the current environment are substituted first. Pre-request steps are
+// not run; an OAuth2 token is included only when one is already cached.
+func (api *API) requestSnippet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	requestID := chi.URLParam(r, "id")
+	lang := strings.ToLower(r.URL.Query().Get("lang"))
+
+	generator, ok := snippetGenerators[lang]
+	if !ok {
+		langs := make([]string, 0, len(snippetGenerators))
+		for name := range snippetGenerators {
+			langs = append(langs, name)
+		}
+		sort.Strings(langs)
+		respondWithError(w, fmt.Sprintf("Unsupported language %q; use one of: %s", lang, strings.Join(langs, ", ")), http.StatusBadRequest)
+		return
+	}
+
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load saved requests", "error", err)
+		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
+		return
+	}
+
+	var saved *SavedRequest
+	for i := range data.Requests {
+		if data.Requests[i].ID == requestID {
+			saved = &data.Requests[i]
+			break
+		}
+	}
+
+	if saved == nil {
+		respondWithError(w, "Request not found", http.StatusNotFound)
+		return
+	}
+
+	currentEnv, err := getActiveEnvironment(r, data)
+	if err != nil {
+		slog.Error("failed to get current environment", "error", err)
+		respondWithError(w, "Failed to get current environment", http.StatusInternalServerError)
+		return
+	}
+
+	snippetReq := proxyRequestFromSaved(*saved, scopeVariables(data, currentEnv))
+	applyEnvironmentHeaders(&snippetReq, currentEnv)
+	if err := applyEnvironmentBaseURL(&snippetReq, currentEnv); err != nil {
+		respondWithError(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	applyInheritedAuth(&snippetReq, data)
+	processedReq, err := api.processTemplates(snippetReq)
+	if err != nil {
+		respondWithError(w, fmt.Sprintf("Template processing failed: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+	if auth := processedReq.Auth; auth != nil && auth.Type == "oauth2" && auth.OAuth2 != nil {
+		if token, ok := cachedOAuth2Token(currentEnv.ID, auth.OAuth2); ok {
+			processedReq.Headers["Authorization"] = "Bearer " + token
+		}
+	}
+
+	body, err := newSnippetBody(&processedReq)
+	if err != nil {
+		respondWithError(w, fmt.Sprintf("Failed to build body: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+
+	slog.Info("generated snippet", "lang", lang, "name", saved.Name, "id", saved.ID)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(generator(processedReq, body)))
+}
+
+// =============================================================================
+// BUNDLE EXPORT & IMPORT
+// =============================================================================
+
+// bundleFormatVersion is bumped when the bundle layout changes incompatibly
+const bundleFormatVersion = 1
+
+// Bundle is a portable backup of every request, environment, group and global variable
+type Bundle struct {
+	FormatVersion      int            `json:"formatVersion"`
+	ExportedAt         string         `json:"exportedAt"`
+	Requests           []SavedRequest `json:"requests"`
+	Environments       []Environment  `json:"environments"`
+	CurrentEnvironment string         `json:"currentEnvironment,omitempty"`
+	Groups             []Group        `json:"groups"`
+	Globals            []Variable     `json:"globals"`
+}
+
+// uniqueEnvironmentName creates a unique environment name by appending a counter if needed
+func uniqueEnvironmentName(baseName string, environments []Environment) string {
+	candidateName := baseName
+	counter := 1
+
+	for {
+		isUnique := true
+		for _, env := range environments {
+			if env.Name == candidateName {
+				isUnique = false
+				break
+			}
+		}
+
+		if isUnique {
+			return candidateName
+		}
+
+		counter++
+		candidateName = baseName + " (" + strconv.Itoa(counter) + ")"
+	}
+}
+
+// backupRequestsFile copies a data file to a timestamped backup next to it
+//
+// It returns the backup's file name, or "" when there is nothing to back up yet.
+func backupRequestsFile(path string) (string, error) {
+	fileAccessMutex.RLock()
+	contents, err := os.ReadFile(path)
+	fileAccessMutex.RUnlock()
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read requests file: %v", err)
+	}
+
+	backupName := strings.TrimSuffix(path, ".json") + ".backup-" + time.Now().Format("20060102-150405") + ".json"
+	if err := os.WriteFile(backupName, contents, 0644); err != nil {
+		return "", fmt.Errorf("failed to write backup: %v", err)
+	}
+	return backupName, nil
+}
+
+// mergeBundle adds a bundle's contents to existing data
+//
+// Requests and environments whose names are taken are renamed with a counter, IDs that
+// collide are regenerated, and groups are matched by name. Globals already defined keep
+// their current values.
+func mergeBundle(data *SavedRequestsData, bundle Bundle) {
+	ids := make(map[string]bool, len(data.Requests)+len(data.Trash))
+	for _, req := range data.Requests {
+		ids[req.ID] = true
+	}
+	for _, req := range data.Trash {
+		ids[req.ID] = true
+	}
+
+	for _, req := range bundle.Requests {
+		if req.ID == "" || ids[req.ID] {
+			req.ID = generateID()
+		}
+		ids[req.ID] = true
+		req.Name = uniqueName(req.Name, data.Requests)
+		req.DeletedAt = ""
+		data.Requests = append(data.Requests, req)
+	}
+
+	// Environment IDs may be regenerated, so parent IDs are mapped to the new IDs
+	envIDs := make(map[string]string, len(bundle.Environments))
+	addedEnvs := len(data.Environments)
+	for _, env := range bundle.Environments {
+		bundleID := env.ID
+		if env.ID == "" || findEnvironment(data, env.ID) != nil {
+			env.ID = generateID()
+		}
+		if bundleID != "" {
+			envIDs[bundleID] = env.ID
+		}
+		env.Name = uniqueEnvironmentName(env.Name, data.Environments)
+		data.Environments = append(data.Environments, env)
+	}
+	for i := addedEnvs; i < len(data.Environments); i++ {
+		if parent, ok := envIDs[data.Environments[i].ParentID]; ok {
+			data.Environments[i].ParentID = parent
+		}
+	}
+
+	// Groups are matched by name, so parent IDs from the bundle are mapped to local IDs
+	groupIDs := make(map[string]string, len(bundle.Groups))
+	added := len(data.Groups)
+	for _, group := range bundle.Groups {
+		bundleID := group.ID
+		localID := ""
+		for i := range data.Groups {
+			existing := &data.Groups[i]
+			if existing.Name == group.Name {
+				// Local metadata wins; the bundle only fills in what's missing
+				if existing.Description == "" {
+					existing.Description = group.Description
+				}
+				if existing.Color == "" {
+					existing.Color = group.Color
+				}
+				localID = existing.ID
+				break
+			}
+		}
+		if localID == "" {
+			if group.ID == "" || findGroup(data, group.ID) != nil {
+				group.ID = generateID()
+			}
+			group.Order = nextGroupOrder(data.Groups)
+			data.Groups = append(data.Groups, group)
+			localID = group.ID
+		}
+		if bundleID != "" {
+			groupIDs[bundleID] = localID
+		}
+	}
+	for i := added; i < len(data.Groups); i++ {
+		if parent, ok := groupIDs[data.Groups[i].ParentID]; ok {
+			data.Groups[i].ParentID = parent
+		}
+	}
+	repairGroupTree(data)
+
+	for _, global := range bundle.Globals {
+		if _, found := lookupVariable(global.Key, data.Globals); !found {
+			data.Globals = append(data.Globals, global)
+		}
+	}
+}
+
+// replaceWithBundle overwrites requests, environments, groups and globals with a bundle's
+// contents; settings and the trash are kept
+func replaceWithBundle(data *SavedRequestsData, bundle Bundle) {
+	data.Requests = bundle.Requests
+	data.Environments = bundle.Environments
+	data.Groups = bundle.Groups
+	data.Globals = bundle.Globals
+
+	if data.Requests == nil {
+		data.Requests = []SavedRequest{}
+	}
+	if data.Globals == nil {
+		data.Globals = []Variable{}
+	}
+	if data.Groups == nil {
+		data.Groups = []Group{}
+	}
+	ensureDefaultGroup(data)
+
+	if len(data.Environments) == 0 {
+		initEnv(data)
+		return
+	}
+	data.CurrentEnvironment = bundle.CurrentEnvironment
+	if findEnvironment(data, data.CurrentEnvironment) == nil {
+		data.CurrentEnvironment = data.Environments[0].ID
+	}
+}
+
+// exportBundle handles GET requests to download everything as a single bundle
+func (api *API) exportBundle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load saved requests", "error", err)
+		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
+		return
+	}
+
+	bundle := Bundle{
+		FormatVersion:      bundleFormatVersion,
+		ExportedAt:         time.Now().Format(time.RFC3339),
+		Requests:           data.Requests,
+		Environments:       data.Environments,
+		CurrentEnvironment: data.CurrentEnvironment,
+		Groups:             data.Groups,
+		Globals:            data.Globals,
+	}
+
+	// Secret values stay out of the file unless explicitly requested
+	if r.URL.Query().Get("includeSecrets") != "true" {
+		bundle.Globals = stripSecrets(bundle.Globals)
+		bundle.Environments = make([]Environment, len(data.Environments))
+		for i, env := range data.Environments {
+			bundle.Environments[i] = env
+			bundle.Environments[i].Variables = stripSecrets(env.Variables)
+		}
+		bundle.Requests = make([]SavedRequest, len(data.Requests))
+		for i, req := range data.Requests {
+			bundle.Requests[i] = req
+			bundle.Requests[i].Overrides = stripSecrets(req.Overrides)
+			bundle.Requests[i].Auth = stripAuthSecret(req.Auth)
+		}
+		bundle.Groups = make([]Group, len(data.Groups))
+		for i, group := range data.Groups {
+			bundle.Groups[i] = group
+			bundle.Groups[i].Auth = stripAuthSecret(group.Auth)
+		}
+	}
+
+	slog.Info("exporting bundle", "requests", len(bundle.Requests),
+		"environments", len(bundle.Environments), "groups", len(bundle.Groups))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="go-rest-bundle.json"`)
+	if err := json.NewEncoder(w).Encode(bundle); err != nil {
+		slog.Error("failed to encode bundle", "error", err)
+	}
+}
+
+// importBundle handles POST requests to restore a bundle
+//
+// ?mode=merge (the default) adds the bundle's contents alongside existing data;
+// ?mode=replace overwrites it after backing up the current file.
+func (api *API) importBundle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = "merge"
+	}
+	if mode != "merge" && mode != "replace" {
+		respondWithError(w, "Mode must be \"merge\" or \"replace\"", http.StatusBadRequest)
+		return
+	}
+
+	var bundle Bundle
+	if !decodeJSONRequest(w, r, &bundle) {
+		return
+	}
+	if bundle.FormatVersion > bundleFormatVersion {
+		respondWithError(w, fmt.Sprintf("Unsupported bundle format version %d", bundle.FormatVersion), http.StatusBadRequest)
+		return
+	}
+	for i, req := range bundle.Requests {
+		name, err := validateSavedRequest(req.Name, req.URL)
+		if err != nil {
+			respondWithError(w, fmt.Sprintf("Invalid request in bundle: %v", err), http.StatusBadRequest)
+			return
+		}
+		bundle.Requests[i].Name = name
+	}
+	for i, env := range bundle.Environments {
+		name, err := normalizeName("environment", env.Name)
+		if err != nil {
+			respondWithError(w, fmt.Sprintf("Invalid environment in bundle: %v", err), http.StatusBadRequest)
+			return
+		}
+		bundle.Environments[i].Name = name
+	}
+	for i, group := range bundle.Groups {
+		color, err := normalizeGroupColor(group.Color)
+		if err != nil {
+			respondWithError(w, fmt.Sprintf("Invalid group in bundle: %v", err), http.StatusBadRequest)
+			return
+		}
+		bundle.Groups[i].Color = color
+	}
+
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load saved requests", "error", err)
+		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
+		return
+	}
+
+	backup := ""
+	if mode == "replace" {
+		if backup, err = backupRequestsFile(data.path); err != nil {
+			slog.Error("failed to back up before import", "error", err)
+			respondWithError(w, "Failed to back up existing data", http.StatusInternalServerError)
+			return
+		}
+		replaceWithBundle(data, bundle)
+	} else {
+		mergeBundle(data, bundle)
+	}
+
+	if err := api.store.Save(data); err != nil {
+		slog.Error("failed to save imported bundle", "error", err)
+		respondWithError(w, "Failed to save imported bundle", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("imported bundle", "mode", mode, "requests", len(bundle.Requests),
+		"environments", len(bundle.Environments), "groups", len(bundle.Groups))
+	recordAudit(r, "import", "bundle", "", mode)
+
+	result := map[string]any{
+		"status":       "imported",
+		"mode":         mode,
+		"requests":     len(bundle.Requests),
+		"environments": len(bundle.Environments),
+		"groups":       len(bundle.Groups),
+	}
+	if backup != "" {
+		result["backup"] = backup
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		slog.Error("failed to encode import response", "error", err)
+	}
+}
+
+// =============================================================================
+// .HTTP FILE IMPORT
+// =============================================================================
+
+// maxHTTPFileSize caps the size of an uploaded .http document
+const maxHTTPFileSize = 5 << 20
+
+// HTTPFileRequest is one request parsed from a .http (REST Client) document
+type HTTPFileRequest struct {
+	Name    string // From "# @name", else the ### separator's text, else empty
+	Method  string
+	URL     string
+	Headers map[string]string
+	Body    string
+	Line    int // Line of the request line, for warnings
+}
+
+// HTTPFile is a parsed .http document
+type HTTPFile struct {
+	Variables []Variable // File variables (@key = value)
+	Requests  []HTTPFileRequest
+	Warnings  []string
+}
+
+var (
+	httpFileVariablePattern = regexp.MustCompile(`^@([A-Za-z_][\w.-]*)\s*=\s*(.*)$`)
+	httpFileNamePattern     = regexp.MustCompile(`^(?:#|//)\s*@name(?:\s*=\s*|\s+)(\S.*)$`)
+	httpFileVersionPattern  = regexp.MustCompile(`\s+HTTP/\d(?:\.\d)?$`)
+
+	// Request variables such as {{login.response.body.$.token}}
+	httpFileResponseRefPattern = regexp.MustCompile(`\{\{\s*([\w-]+)\.response\.(body|headers)(?:\.([^}]*?))?\s*\}\}`)
+)
+
+// httpFileMethods lists the methods recognized at the start of a request line
+var httpFileMethods = map[string]bool{
+	"GET": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true,
+	"HEAD": true, "OPTIONS": true, "TRACE": true, "CONNECT": true,
+}
+
+// parseHTTPFile parses a REST Client .http document
+//
+// Requests are separated by lines starting with ###. Within a block, file variables
+// and comments may precede the request line ("METHOD url [HTTP/1.1]", or just a URL
+// for GET). Lines starting with ? or & continue the URL's query string, header lines
+// follow, and everything after the first blank line is the body.
+func parseHTTPFile(content string) HTTPFile {
+	var file HTTPFile
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+
+	var current *HTTPFileRequest
+	var body []string
+	title, pendingName := "", ""
+	state := "preamble"
+
+	finish := func() {
+		if current != nil {
+			current.Body = strings.TrimRight(strings.Join(body, "\n"), " \t\n")
+			file.Requests = append(file.Requests, *current)
+		}
+		current, body = nil, nil
+		title, pendingName = "", ""
+		state = "preamble"
+	}
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "###") {
+			finish()
+			title = strings.TrimSpace(strings.TrimLeft(trimmed, "#"))
+			continue
+		}
+
+		switch state {
+		case "preamble":
+			if trimmed == "" {
+				continue
+			}
+			if m := httpFileVariablePattern.FindStringSubmatch(trimmed); m != nil {
+				file.Variables = append(file.Variables, Variable{Key: m[1], Value: strings.TrimSpace(m[2])})
+				continue
+			}
+			if strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "//") {
+				if m := httpFileNamePattern.FindStringSubmatch(trimmed); m != nil {
+					pendingName = strings.TrimSpace(m[1])
+				}
+				continue
+			}
+
+			method, target := http.MethodGet, trimmed
+			if first, rest, ok := strings.Cut(trimmed, " "); ok && httpFileMethods[strings.ToUpper(first)] {
+				method, target = strings.ToUpper(first), strings.TrimSpace(rest)
+			}
+			target = httpFileVersionPattern.ReplaceAllString(target, "")
+			name := pendingName
+			if name == "" {
+				name = title
+			}
+			current = &HTTPFileRequest{Name: name, Method: method, URL: target, Headers: map[string]string{}, Line: i + 1}
+			state = "query"
+
+		case "query", "headers":
+			if state == "query" && (strings.HasPrefix(trimmed, "?") || strings.HasPrefix(trimmed, "&")) {
+				current.URL += trimmed
+				continue
+			}
+			state = "headers"
+			if trimmed == "" {
+				state = "body"
+				continue
+			}
+			if strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "//") {
+				continue
+			}
+			name, value, ok := strings.Cut(trimmed, ":")
+			if !ok || strings.TrimSpace(name) == "" {
+				file.Warnings = append(file.Warnings, fmt.Sprintf("line %d: expected a header (Name: value), skipped %q", i+1, trimmed))
+				continue
+			}
+			current.Headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+
+		case "body":
+			body = append(body, line)
+		}
+	}
+	finish()
+
+	return file
+}
+
+// convertHTTPFileTemplates rewrites REST Client placeholders into go-rest syntax:
+// {{$guid}} and {{$datetime iso8601}} become {{$uuid}} and {{$isoTimestamp}}, and
+// request variables ({{login.response.body.$.token}}) become response variables
+// ({{"login".token}}) using the imported request names. Anything that has no
+// equivalent is left as-is and reported.
+func convertHTTPFileTemplates(value string, names map[string]string, warnings *[]string, where string) string {
+	value = strings.ReplaceAll(value, "{{$guid}}", "{{$uuid}}")
+	value = strings.ReplaceAll(value, "{{$datetime iso8601}}", "{{$isoTimestamp}}")
+
+	value = httpFileResponseRefPattern.ReplaceAllStringFunc(value, func(match string) string {
+		m := httpFileResponseRefPattern.FindStringSubmatch(match)
+		name, ok := names[m[1]]
+		if !ok {
+			*warnings = append(*warnings, fmt.Sprintf("%s: %s refers to an unknown request", where, match))
+			return match
+		}
+		if m[2] != "body" {
+			*warnings = append(*warnings, fmt.Sprintf("%s: %s reads a response header, which isn't supported", where, match))
+			return match
+		}
+		path := strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(m[3]), "$"), ".")
+		if path == "" || path == "*" {
+			path = "response"
+		}
+		return fmt.Sprintf(`{{"%s".%s}}`, name, path)
+	})
+
+	for _, unsupported := range []string{"{{$processEnv", "{{$dotenv", "{{$aadToken", "{{$localDatetime", "{{$randomInt}}"} {
+		if strings.Contains(value, unsupported) {
+			*warnings = append(*warnings, fmt.Sprintf("%s: %s...}} has no equivalent and was left as-is", where, unsupported))
+		}
+	}
+	return value
+}
+
+// importHTTPFile handles POST requests that import a REST Client .http document
+//
+// The body is the file's text. Requests are added to ?group= (default "default")
+// with de-duplicated names. File variables are added to ?environment= (an ID,
+// defaulting to the active environment); variables it already has are kept.
+func (api *API) importHTTPFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	content, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxHTTPFileSize))
+	if err != nil {
+		respondWithError(w, fmt.Sprintf("Failed to read .http file (max %d MB)", maxHTTPFileSize>>20), http.StatusBadRequest)
+		return
+	}
+	file := parseHTTPFile(string(content))
+	if len(file.Requests) == 0 && len(file.Variables) == 0 {
+		respondWithError(w, "No requests or variables found in the .http file", http.StatusBadRequest)
+		return
+	}
+
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load saved requests", "error", err)
+		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
+		return
+	}
+
+	group := r.URL.Query().Get("group")
+	if group == "" {
+		group = "default"
+	}
+	ensureDefaultGroup(data)
+	groupExists := false
+	for _, g := range data.Groups {
+		if g.Name == group {
+			groupExists = true
+			break
+		}
+	}
+	if !groupExists {
+		respondWithError(w, fmt.Sprintf("Group %q not found", group), http.StatusBadRequest)
+		return
+	}
+
+	var env *Environment
+	if envID := r.URL.Query().Get("environment"); envID != "" {
+		env = findEnvironment(data, envID)
+	} else {
+		env, _ = getActiveEnvironment(r, data)
+	}
+	if env == nil && len(file.Variables) > 0 {
+		respondWithError(w, "Environment not found for the file's variables", http.StatusBadRequest)
+		return
+	}
+
+	// Name every request first so request variables can refer to later requests too
+	warnings := file.Warnings
+	taken := append([]SavedRequest(nil), data.Requests...)
+	names := make(map[string]string)
+	imported := make([]SavedRequest, 0, len(file.Requests))
+	now := time.Now().Format(time.RFC3339)
+	for _, parsed := range file.Requests {
+		name := parsed.Name
+		if name == "" {
+			name = parsed.Method + " " + parsed.URL
+			if runes := []rune(name); len(runes) > 100 {
+				name = string(runes[:100])
+			}
+		}
+		name, err := validateSavedRequest(name, parsed.URL)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("line %d: skipped: %v", parsed.Line, err))
+			continue
+		}
+
+		saved := newSavedRequest(SaveRequestPayload{
+			Name:    name,
+			URL:     parsed.URL,
+			Method:  parsed.Method,
+			Headers: parsed.Headers,
+			Group:   group,
+		}, now)
+		saved.Name = uniqueName(saved.Name, taken)
+		if parsed.Body != "" {
+			if path, ok := strings.CutPrefix(parsed.Body, "<"); ok && !strings.Contains(parsed.Body, "\n") {
+				saved.BodyType = "binary"
+				saved.BodyFile = strings.TrimSpace(strings.TrimPrefix(path, "@"))
+			} else {
+				saved.BodyType = "text"
+				saved.BodyText = parsed.Body
+			}
+		}
+		if _, seen := names[parsed.Name]; parsed.Name != "" && !seen {
+			names[parsed.Name] = saved.Name
+		}
+		taken = append(taken, saved)
+		imported = append(imported, saved)
+	}
+
+	for i := range imported {
+		req := &imported[i]
+		where := fmt.Sprintf("request %q", req.Name)
+		req.URL = convertHTTPFileTemplates(req.URL, names, &warnings, where)
+		headers := make(map[string]string, len(req.Headers))
+		for key, value := range req.Headers {
+			headers[key] = convertHTTPFileTemplates(value, names, &warnings, where)
+		}
+		req.Headers = headers
+		req.BodyText = convertHTTPFileTemplates(req.BodyText, names, &warnings, where)
+	}
+
+	addedVariables, skippedVariables := []string{}, []string{}
+	if env != nil {
+		for _, v := range file.Variables {
+			v.Value = convertHTTPFileTemplates(v.Value, names, &warnings, "variable "+v.Key)
+			exists := false
+			for _, existing := range env.Variables {
+				if existing.Key == v.Key {
+					exists = true
+					break
+				}
+			}
+			if exists {
+				skippedVariables = append(skippedVariables, v.Key)
+				continue
+			}
+			env.Variables = append(env.Variables, v)
+			addedVariables = append(addedVariables, v.Key)
+		}
+		if len(addedVariables) > 0 {
+			env.UpdatedAt = now
+			env.Version++
+		}
+	}
+
+	if len(imported) == 0 && len(addedVariables) == 0 {
+		respondWithError(w, "Nothing to import: "+strings.Join(warnings, "; "), http.StatusBadRequest)
+		return
+	}
+
+	data.Requests = append(data.Requests, imported...)
+	if err := api.store.Save(data); err != nil {
+		slog.Error("failed to save imported requests", "error", err)
+		respondWithError(w, "Failed to save imported requests", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("imported .http file", "requests", len(imported), "variables", len(addedVariables), "warnings", len(warnings))
+	summaries := make([]map[string]string, 0, len(imported))
+	for _, req := range imported {
+		recordAudit(r, "create", "request", req.ID, req.Name)
+		summaries = append(summaries, map[string]string{"id": req.ID, "name": req.Name, "method": req.Method, "url": req.URL})
+	}
+	envName := ""
+	if env != nil {
+		envName = env.Name
+		if len(addedVariables) > 0 {
+			recordAudit(r, "update", "environment", env.ID, env.Name)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]any{
+		"imported":         summaries,
+		"environment":      envName,
+		"variablesAdded":   addedVariables,
+		"variablesSkipped": skippedVariables,
+		"warnings":         append([]string{}, warnings...),
+	}); err != nil {
+		slog.Error("failed to encode import response", "error", err)
+	}
+}
+
+// =============================================================================
+// WORKSPACES
+// =============================================================================
+
+// workspaceIndexFileName lists the workspaces and which one is active
+const workspaceIndexFileName = "workspaces.json"
+
+// workspacesDir holds the data files of workspaces other than the default one
+const workspacesDir = "workspaces"
+
+// defaultWorkspaceID is the workspace backed by the legacy saved_requests.json
+const defaultWorkspaceID = "default"
+
+// Mutex guarding read-modify-write of the workspace index and cachedWorkspace
+var workspaceMutex sync.Mutex
+
+// cachedWorkspace is the active workspace as last read from the index; nil until read
+// and whenever the index is written
+var cachedWorkspace *Workspace
+
+// Workspace is an isolated set of requests, environments and groups in its own data file
+type Workspace struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	File      string `json:"file"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// sideFile returns the path of a file kept alongside the workspace's data, such as its
+// audit log. The default workspace keeps them in the working directory, where they were
+// before workspaces existed; other workspaces keep them in the workspaces directory.
+func (ws Workspace) sideFile(name string) string {
+	if ws.ID == defaultWorkspaceID {
+		return name
+	}
+	return filepath.Join(workspacesDir, ws.ID+"."+name)
+}
+
+// WorkspaceIndex is the contents of workspaces.json
+type WorkspaceIndex struct {
+	Workspaces []Workspace `json:"workspaces"`
+	Active     string      `json:"active"`
+}
+
+// readWorkspaceIndex loads the workspace index; callers must hold workspaceMutex
+//
+// Installations from before workspaces have no index file, so saved_requests.json is
+// registered as the "Default" workspace. The default workspace is always present and
+// an unknown active ID falls back to it.
+func readWorkspaceIndex() (*WorkspaceIndex, error) {
+	index := &WorkspaceIndex{}
+	contents, err := os.ReadFile(workspaceIndexFileName)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read workspace index: %v", err)
+	}
+	if len(contents) > 0 {
+		if err := json.Unmarshal(contents, index); err != nil {
+			return nil, fmt.Errorf("failed to parse workspace index: %v", err)
+		}
+	}
+
+	if findWorkspace(index, defaultWorkspaceID) == nil {
+		index.Workspaces = append([]Workspace{{
+			ID:   defaultWorkspaceID,
+			Name: "Default",
+			File: requestsFileName,
+		}}, index.Workspaces...)
+	}
+	if findWorkspace(index, index.Active) == nil {
+		index.Active = defaultWorkspaceID
+	}
+	return index, nil
+}
+
+// writeWorkspaceIndex saves the workspace index; callers must hold workspaceMutex
+func writeWorkspaceIndex(index *WorkspaceIndex) error {
+	// The next activeWorkspace reads the index again, even if this write fails halfway
+	cachedWorkspace = nil
+
+	jsonData, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal workspace index: %v", err)
+	}
+	tempFileName := workspaceIndexFileName + ".tmp"
+	if err := os.WriteFile(tempFileName, jsonData, 0644); err != nil {
+		return fmt.Errorf("failed to write workspace index: %v", err)
+	}
+	if err := os.Rename(tempFileName, workspaceIndexFileName); err != nil {
+		os.Remove(tempFileName)
+		return fmt.Errorf("failed to write workspace index: %v", err)
+	}
+	return nil
+}
+
+// findWorkspace returns a pointer to the workspace with the given ID, or nil
+func findWorkspace(index *WorkspaceIndex, workspaceID string) *Workspace {
+	for i := range index.Workspaces {
+		if index.Workspaces[i].ID == workspaceID {
+			return &index.Workspaces[i]
+		}
+	}
+	return nil
+}
+
+// activeWorkspace returns the workspace every data endpoint currently operates on
+//
+// The index is read once and cached until this server writes it again. If it can't be
+// read, the legacy data file is used so the server keeps working, and the next call
+// tries again.
+func activeWorkspace() Workspace {
+	workspaceMutex.Lock()
+	defer workspaceMutex.Unlock()
+
+	if cachedWorkspace != nil {
+		return *cachedWorkspace
+	}
+	index, err := readWorkspaceIndex()
+	if err != nil {
+		slog.Warn("falling back to the default data file", "path", requestsFileName, "error", err)
+		return Workspace{ID: defaultWorkspaceID, Name: "Default", File: requestsFileName}
+	}
+	active := *findWorkspace(index, index.Active)
+	cachedWorkspace = &active
+	return active
+}
+
+// workspaces handles GET requests to list workspaces
+func (api *API) workspaces(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	workspaceMutex.Lock()
+	index, err := readWorkspaceIndex()
+	workspaceMutex.Unlock()
+	if err != nil {
+		slog.Error("failed to load workspaces", "error", err)
+		respondWithError(w, "Failed to load workspaces", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(index); err != nil {
+		slog.Error("failed to encode workspaces", "error", err)
+	}
+}
+
+// createWorkspace handles POST requests to create an empty workspace
+//
+// The new workspace's data file is created on its first save; until then it loads
+// with a default environment like a fresh installation.
+func (api *API) createWorkspace(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+	name, err := normalizeName("workspace", req.Name)
+	if err != nil {
+		respondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	workspaceMutex.Lock()
+	defer workspaceMutex.Unlock()
+
+	index, err := readWorkspaceIndex()
+	if err != nil {
+		slog.Error("failed to load workspaces", "error", err)
+		respondWithError(w, "Failed to load workspaces", http.StatusInternalServerError)
+		return
+	}
+	for _, existing := range index.Workspaces {
+		if existing.Name == name {
+			respondWithError(w, "Workspace already exists", http.StatusConflict)
+			return
+		}
+	}
+
+	if err := os.MkdirAll(workspacesDir, 0755); err != nil {
+		slog.Error("failed to create workspaces directory", "error", err)
+		respondWithError(w, "Failed to create workspace", http.StatusInternalServerError)
+		return
+	}
+
+	id := generateID()
+	workspace := Workspace{
+		ID:        id,
+		Name:      name,
+		File:      filepath.Join(workspacesDir, id+".json"),
+		CreatedAt: time.Now().Format(time.RFC3339),
+	}
+	index.Workspaces = append(index.Workspaces, workspace)
+
+	if err := writeWorkspaceIndex(index); err != nil {
+		slog.Error("failed to save workspaces", "error", err)
+		respondWithError(w, "Failed to create workspace", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("created workspace", "name", workspace.Name, "id", workspace.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(workspace); err != nil {
+		slog.Error("failed to encode workspace response", "error", err)
+	}
+}
+
+// deleteWorkspace handles DELETE requests to remove a workspace and its data file
+//
+// The default workspace and the active workspace can't be deleted.
+func (api *API) deleteWorkspace(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	workspaceID := chi.URLParam(r, "id")
+
+	workspaceMutex.Lock()
+	defer workspaceMutex.Unlock()
+
+	index, err := readWorkspaceIndex()
+	if err != nil {
+		slog.Error("failed to load workspaces", "error", err)
+		respondWithError(w, "Failed to load workspaces", http.StatusInternalServerError)
+		return
+	}
+	workspace := findWorkspace(index, workspaceID)
+	if workspace == nil {
+		respondWithError(w, "Workspace not found", http.StatusNotFound)
+		return
+	}
+	if workspace.ID == defaultWorkspaceID {
+		respondWithError(w, "Cannot delete the default workspace", http.StatusBadRequest)
+		return
+	}
+	if workspace.ID == index.Active {
+		respondWithError(w, "Cannot delete the active workspace; activate another one first", http.StatusBadRequest)
+		return
+	}
+
+	removed := *workspace
+	kept := make([]Workspace, 0, len(index.Workspaces)-1)
+	for _, existing := range index.Workspaces {
+		if existing.ID != workspaceID {
+			kept = append(kept, existing)
+		}
+	}
+	index.Workspaces = kept
+
+	if err := writeWorkspaceIndex(index); err != nil {
+		slog.Error("failed to save workspaces", "error", err)
+		respondWithError(w, "Failed to delete workspace", http.StatusInternalServerError)
+		return
+	}
+	for _, file := range []string{removed.File, removed.sideFile(auditLogFileName), removed.sideFile(executionHistoryFileName)} {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			slog.Warn("failed to remove workspace file", "path", file, "error", err)
+		}
+	}
+
+	slog.Info("deleted workspace", "name", removed.Name, "id", removed.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "deleted"})
+}
+
+// activateWorkspace handles POST requests to switch the workspace all endpoints operate on
+//
+// Cached OAuth2 tokens are dropped so nothing fetched for one workspace is used in another.
+func (api *API) activateWorkspace(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	workspaceID := chi.URLParam(r, "id")
+
+	workspaceMutex.Lock()
+	defer workspaceMutex.Unlock()
+
+	index, err := readWorkspaceIndex()
+	if err != nil {
+		slog.Error("failed to load workspaces", "error", err)
+		respondWithError(w, "Failed to load workspaces", http.StatusInternalServerError)
+		return
+	}
+	workspace := findWorkspace(index, workspaceID)
+	if workspace == nil {
+		respondWithError(w, "Workspace not found", http.StatusNotFound)
+		return
+	}
+
+	index.Active = workspace.ID
+	if err := writeWorkspaceIndex(index); err != nil {
+		slog.Error("failed to save workspaces", "error", err)
+		respondWithError(w, "Failed to activate workspace", http.StatusInternalServerError)
+		return
+	}
+	clearOAuth2TokenCache()
+
+	slog.Info("activated workspace", "name", workspace.Name, "id", workspace.ID)
+	api.saveLoadRepairs()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]any{"status": "activated", "workspace": workspace}); err != nil {
+		slog.Error("failed to encode activation response", "error", err)
+	}
+}
+
+// =============================================================================
+// AUDIT LOG
+// =============================================================================
+
+// auditLogFileName is the name of each workspace's audit log; see Workspace.sideFile
+const auditLogFileName = "audit_log.jsonl"
+
+// defaultAuditLimit and maxAuditLimit bound how many events GET /api/audit returns
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// Mutex serializing appends to and reads of the audit log
+var auditMutex sync.Mutex
+
+// AuditEvent records a single successful change to requests, environments or groups
+type AuditEvent struct {
+	Timestamp string `json:"timestamp"`
+	Action    string `json:"action"` // create, update, delete, restore, purge, ...
+	Entity    string `json:"entity"` // request, environment, group, bundle
+	EntityID  string `json:"entityId,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Workspace string `json:"workspace"`
+	ClientIP  string `json:"clientIp,omitempty"`
+}
+
+// clientIP returns the address of the client that made the request, without the port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// recordAudit appends an event to the audit log
+//
+// It is called after the change has been saved. The log is append-only: one JSON
+// object per line in a file kept separate from saved_requests.json, so it survives
+// imports and doesn't bloat the data file. Failing to write it doesn't fail the request.
+// A read-only server records nothing.
+func recordAudit(r *http.Request, action, entity, entityID, name string) {
+	if readOnly {
+		return
+	}
+	workspace := activeWorkspace()
+	event := AuditEvent{
+		Timestamp: time.Now().Format(time.RFC3339),
+		Action:    action,
+		Entity:    entity,
+		EntityID:  entityID,
+		Name:      name,
+		Workspace: workspace.ID,
+		ClientIP:  clientIP(r),
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("failed to encode audit event", "error", err)
+		return
+	}
+
+	auditMutex.Lock()
+	defer auditMutex.Unlock()
+
+	file, err := os.OpenFile(workspace.sideFile(auditLogFileName), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		slog.Error("failed to open audit log", "error", err)
+		return
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		slog.Error("failed to write audit event", "error", err)
+	}
+}
+
+// loadAuditEvents returns the most recent events of the active workspace, newest first
+//
+// Lines that can't be parsed (e.g. a write cut short by a crash) are skipped.
+func loadAuditEvents(limit int) ([]AuditEvent, error) {
+	path := activeWorkspace().sideFile(auditLogFileName)
+
+	auditMutex.Lock()
+	defer auditMutex.Unlock()
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []AuditEvent{}, nil
+		}
+		return nil, err
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+	events := []AuditEvent{}
+	for i := len(lines) - 1; i >= 0 && len(events) < limit; i-- {
+		if strings.TrimSpace(lines[i]) == "" {
+			continue
+		}
+		var event AuditEvent
+		if err := json.Unmarshal([]byte(lines[i]), &event); err != nil {
+			slog.Warn("skipping unreadable audit log line", "line", i+1, "error", err)
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// audit handles GET requests to list recent audit events
+func (api *API) audit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := defaultAuditLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			respondWithError(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(parsed, maxAuditLimit)
+	}
+
+	events, err := loadAuditEvents(limit)
+	if err != nil {
+		slog.Error("failed to read audit log", "error", err)
+		respondWithError(w, "Failed to read audit log", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string][]AuditEvent{"events": events}); err != nil {
+		slog.Error("failed to encode audit log", "error", err)
+	}
+}
+
+// =============================================================================
+// EXECUTION HISTORY
+// =============================================================================
+
+// The execution history keeps the last maxExecutionHistory requests sent through the
+// proxy, saved or not, one JSON object per line
+const (
+	executionHistoryFileName = "execution_history.jsonl"
+	maxExecutionHistory      = 1000
+	defaultHistoryLimit      = 100
+)
+
+// ExecutionRecord describes one request sent by the proxy, as it was actually sent
+type ExecutionRecord struct {
+	Timestamp   string            `json:"timestamp"` // When the request was sent (RFC3339)
+	Method      string            `json:"method"`
+	URL         string            `json:"url"` // Resolved URL, with secret values masked
+	Host        string            `json:"host"`
+	Headers     map[string]string `json:"headers,omitempty"` // Credential headers masked
+	Status      int               `json:"status"`            // 0 when no response arrived
+	ErrorKind   string            `json:"errorKind,omitempty"`
+	Error       string            `json:"error,omitempty"`
+	DurationMs  int64             `json:"durationMs"`
+	SizeBytes   int               `json:"sizeBytes"`
+	RequestID   string            `json:"requestId,omitempty"` // Saved request, if the call came from one
+	RequestName string            `json:"requestName,omitempty"`
+	Workspace   string            `json:"workspace"`
+}
+
+var (
+	historyMutex sync.Mutex
+	// executionHistory is the ring in memory, oldest first; nil until read from disk
+	executionHistory []ExecutionRecord
+	// historyFile is the file executionHistory was read from, which changes with the
+	// active workspace
+	historyFile string
+	// historyFileLines counts lines in the file, which is rewritten once it holds
+	// twice the cap so appends stay cheap
+	historyFileLines int
+)
+
+// loadExecutionHistoryLocked reads a workspace's history file the first time it is
+// needed, replacing the records of any other workspace held in memory
+//
+// Lines that can't be parsed are skipped. The caller holds historyMutex.
+func loadExecutionHistoryLocked(workspace Workspace) {
+	path := workspace.sideFile(executionHistoryFileName)
+	if executionHistory != nil && historyFile == path {
+		return
+	}
+	executionHistory = []ExecutionRecord{}
+	historyFile = path
+	historyFileLines = 0
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Error("failed to read execution history", "error", err)
+		}
+		return
+	}
+	for i, line := range strings.Split(strings.TrimSpace(string(contents)), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		historyFileLines++
+		var record ExecutionRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			slog.Warn("skipping unreadable execution history line", "line", i+1, "error", err)
+			continue
+		}
+		executionHistory = append(executionHistory, record)
+	}
+	if len(executionHistory) > maxExecutionHistory {
+		executionHistory = executionHistory[len(executionHistory)-maxExecutionHistory:]
+	}
+}
+
+// recordExecution adds a sent request to the execution history
+//
+// It is called for every request the proxy sends, including ones that failed to
+// connect, with the request as it went out after templates and auth. Failing to write
+// the file doesn't fail the request. A read-only server keeps its records in memory only.
+func recordExecution(sent ProxyRequest, response ProxyResponse, requestID, requestName string) {
+	workspace := activeWorkspace()
+	timestamp := time.Now().Format(time.RFC3339Nano)
+	if response.StartedAt != "" {
+		timestamp = response.StartedAt
+	}
+	record := ExecutionRecord{
+		Timestamp:   timestamp,
+		Method:      sent.Method,
+		URL:         redactSecrets(sent.URL, sent.Variables),
+		Host:        targetHost(sent.URL),
+		Headers:     redactHeaders(sent.Headers, sent.Variables),
+		Status:      response.StatusCode,
+		ErrorKind:   response.ErrorKind,
+		Error:       redactSecrets(response.Error, sent.Variables),
+		DurationMs:  response.DurationMs,
+		SizeBytes:   response.SizeBytes,
+		RequestID:   requestID,
+		RequestName: requestName,
+		Workspace:   workspace.ID,
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		slog.Error("failed to encode execution record", "error", err)
+		return
+	}
+
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+
+	loadExecutionHistoryLocked(workspace)
+	executionHistory = append(executionHistory, record)
+	if len(executionHistory) > maxExecutionHistory {
+		executionHistory = append([]ExecutionRecord(nil), executionHistory[len(executionHistory)-maxExecutionHistory:]...)
+	}
+	if readOnly {
+		return
+	}
+
+	if historyFileLines+1 >= 2*maxExecutionHistory {
+		if err := writeExecutionHistoryLocked(); err != nil {
+			slog.Error("failed to compact execution history", "error", err)
+		}
+		return
+	}
+
+	file, err := os.OpenFile(historyFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		slog.Error("failed to open execution history", "error", err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		slog.Error("failed to write execution record", "error", err)
+		return
+	}
+	historyFileLines++
+}
+
+// writeExecutionHistoryLocked replaces the history file with the records in memory; the
+// caller holds historyMutex
+func writeExecutionHistoryLocked() error {
+	var buf bytes.Buffer
+	for _, record := range executionHistory {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	tempFileName := historyFile + ".tmp"
+	if err := os.WriteFile(tempFileName, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tempFileName, historyFile); err != nil {
+		os.Remove(tempFileName)
+		return err
+	}
+	historyFileLines = len(executionHistory)
+	return nil
+}
+
+// HistoryFilter selects execution records; zero fields match everything
+type HistoryFilter struct {
+	Host      string
+	Status    int // Exact status code
+	Class     int // Status class, e.g. 4 for 4xx
+	Since     time.Time
+	Until     time.Time
+	RequestID string
+}
+
+// parseHistoryFilter reads ?host=, ?status= (404 or 4xx), ?since=, ?until= (RFC3339)
+// and ?requestId=
+func parseHistoryFilter(query url.Values) (HistoryFilter, error) {
+	filter := HistoryFilter{Host: strings.TrimSpace(query.Get("host")), RequestID: query.Get("requestId")}
+
+	if status := strings.ToLower(strings.TrimSpace(query.Get("status"))); status != "" {
+		if len(status) == 3 && status[0] >= '1' && status[0] <= '5' && status[1:] == "xx" {
+			filter.Class = int(status[0] - '0')
+		} else if code, err := strconv.Atoi(status); err == nil && code >= 0 && code <= 999 {
+			filter.Status = code
+		} else {
+			return filter, fmt.Errorf("status must be a code like 404 or a class like 4xx, got %q", status)
+		}
+		if filter.Status == 0 && filter.Class == 0 {
+			filter.Status = -1 // status=0 asks for requests that got no response
+		}
+	}
+
+	for _, bound := range []struct {
+		name   string
+		target *time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}} {
+		if raw := query.Get(bound.name); raw != "" {
+			parsed, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				return filter, fmt.Errorf("%s must be an RFC3339 time such as 2024-05-01T15:00:00Z, got %q", bound.name, raw)
+			}
+			*bound.target = parsed
+		}
+	}
+	return filter, nil
+}
+
+// matches reports whether a record passes the filter. A host filter without a port
+// matches the host on any port.
+func (f HistoryFilter) matches(record ExecutionRecord) bool {
+	if f.Host != "" && !strings.EqualFold(record.Host, f.Host) {
+		hostname, _, err := net.SplitHostPort(record.Host)
+		if err != nil || !strings.EqualFold(hostname, f.Host) {
+			return false
+		}
+	}
+	switch {
+	case f.Status == -1 && record.Status != 0,
+		f.Status > 0 && record.Status != f.Status,
+		f.Class > 0 && record.Status/100 != f.Class:
+		return false
+	}
+	if f.RequestID != "" && record.RequestID != f.RequestID {
+		return false
+	}
+	if !f.Since.IsZero() || !f.Until.IsZero() {
+		sent, err := time.Parse(time.RFC3339, record.Timestamp)
+		if err != nil || (!f.Since.IsZero() && sent.Before(f.Since)) || (!f.Until.IsZero() && sent.After(f.Until)) {
+			return false
+		}
+	}
+	return true
+}
+
+// history handles GET requests to list the execution history, newest first, and
+// DELETE requests to clear it
+func (api *API) history(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		filter, err := parseHistoryFilter(r.URL.Query())
+		if err != nil {
+			respondWithError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		limit := defaultHistoryLimit
+		if raw := r.URL.Query().Get("limit"); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < 1 {
+				respondWithError(w, "limit must be a positive integer", http.StatusBadRequest)
+				return
+			}
+			limit = min(parsed, maxExecutionHistory)
+		}
+
+		workspace := activeWorkspace()
+		historyMutex.Lock()
+		loadExecutionHistoryLocked(workspace)
+		entries := []ExecutionRecord{}
+		for i := len(executionHistory) - 1; i >= 0 && len(entries) < limit; i-- {
+			if filter.matches(executionHistory[i]) {
+				entries = append(entries, executionHistory[i])
+			}
+		}
+		historyMutex.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string][]ExecutionRecord{"entries": entries}); err != nil {
+			slog.Error("failed to encode execution history", "error", err)
+		}
+
+	case http.MethodDelete:
+		workspace := activeWorkspace()
+		historyMutex.Lock()
+		loadExecutionHistoryLocked(workspace)
+		cleared := len(executionHistory)
+		executionHistory = []ExecutionRecord{}
+		err := os.Remove(historyFile)
+		if err == nil || os.IsNotExist(err) {
+			historyFileLines = 0
+			err = nil
+		}
+		historyMutex.Unlock()
+		if err != nil {
+			slog.Error("failed to clear execution history", "error", err)
+			respondWithError(w, "Failed to clear execution history", http.StatusInternalServerError)
+			return
+		}
+
+		slog.Info("cleared execution history", "entries", cleared)
+		recordAudit(r, "purge", "history", "", "")
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]int{"cleared": cleared}); err != nil {
+			slog.Error("failed to encode history response", "error", err)
+		}
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// =============================================================================
+// WEBHOOK CAPTURE
+// =============================================================================
+
+// Registered hooks live in memory only and are dropped after hookIdleTTL without a
+// delivery or a read
+const (
+	maxHooks           = 50
+	maxHookCaptures    = 100     // Deliveries kept per hook, oldest dropped first
+	maxHookBodyBytes   = 1 << 20 // Larger bodies are truncated
+	hookIdleTTL        = 24 * time.Hour
+	hookDeliveryPrefix = "/api/hooks/"
+)
+
+var hookSlugPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// CapturedRequest is one delivery received by a webhook
+type CapturedRequest struct {
+	ID         string            `json:"id"`
+	ReceivedAt string            `json:"receivedAt"`
+	Method     string            `json:"method"`
+	Query      string            `json:"query,omitempty"`
+	Headers    map[string]string `json:"headers"` // Repeated headers joined with ", "
+	Body       string            `json:"body"`
+	BodyBase64 bool              `json:"bodyBase64,omitempty"` // Body wasn't UTF-8 and is base64-encoded
+	SizeBytes  int64             `json:"sizeBytes"`            // Full body size, even when truncated
+	Truncated  bool              `json:"truncated,omitempty"`
+	RemoteAddr string            `json:"remoteAddr"`
+}
+
+// hookInbox holds a registered hook's most recent deliveries, oldest first
+type hookInbox struct {
+	CreatedAt    time.Time
+	LastActivity time.Time
+	Captured     []CapturedRequest
+}
+
+var (
+	hooksMutex  sync.Mutex
+	hookInboxes = make(map[string]*hookInbox)
+)
+
+// isHookDelivery reports whether a path is a delivery to a hook rather than one of the
+// endpoints that manage hooks; deliveries skip access control, the slug being the secret
+func isHookDelivery(path string) bool {
+	slug, ok := strings.CutPrefix(path, hookDeliveryPrefix)
+	return ok && hookSlugPattern.MatchString(slug)
+}
+
+// pruneIdleHooksLocked drops hooks without activity for hookIdleTTL; the caller holds hooksMutex
+func pruneIdleHooksLocked(now time.Time) {
+	for slug, inbox := range hookInboxes {
+		if now.Sub(inbox.LastActivity) > hookIdleTTL {
+			delete(hookInboxes, slug)
+			slog.Info("webhook expired without activity", "slug", slug, "idle", hookIdleTTL)
+		}
+	}
+}
+
+// hookSummary describes a registered hook for API responses
+func hookSummary(r *http.Request, slug string, inbox *hookInbox) map[string]any {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return map[string]any{
+		"slug":         slug,
+		"url":          fmt.Sprintf("%s://%s%s%s", scheme, r.Host, hookDeliveryPrefix, slug),
+		"captured":     len(inbox.Captured),
+		"createdAt":    inbox.CreatedAt.Format(time.RFC3339),
+		"lastActivity": inbox.LastActivity.Format(time.RFC3339),
+		"expiresAt":    inbox.LastActivity.Add(hookIdleTTL).Format(time.RFC3339),
+	}
+}
+
+// hooks handles GET requests to list registered webhooks
+func (api *API) hooks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	hooksMutex.Lock()
+	pruneIdleHooksLocked(time.Now())
+	slugs := make([]string, 0, len(hookInboxes))
+	for slug := range hookInboxes {
+		slugs = append(slugs, slug)
+	}
+	sort.Strings(slugs)
+	list := make([]map[string]any, 0, len(slugs))
+	for _, slug := range slugs {
+		list = append(list, hookSummary(r, slug, hookInboxes[slug]))
+	}
+	hooksMutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]any{"hooks": list}); err != nil {
+		slog.Error("failed to encode webhooks", "error", err)
+	}
+}
+
+// registerHook handles POST requests to register a webhook
+//
+// The body may name the slug ({"slug": "stripe-test"}); without one a random slug is
+// generated, which is harder to guess since anyone can deliver to a known slug.
+func (api *API) registerHook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Slug string `json:"slug"`
+	}
+	if r.ContentLength != 0 {
+		if !decodeJSONRequest(w, r, &req) {
+			return
+		}
+	}
+	slug := strings.TrimSpace(req.Slug)
+	if slug == "" {
+		slug = generateID()
+	} else if !hookSlugPattern.MatchString(slug) {
+		respondWithError(w, "slug must be 1-64 letters, digits, '-' or '_'", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	hooksMutex.Lock()
+	pruneIdleHooksLocked(now)
+	if _, exists := hookInboxes[slug]; exists {
+		hooksMutex.Unlock()
+		respondWithError(w, fmt.Sprintf("Webhook %q is already registered", slug), http.StatusConflict)
+		return
+	}
+	if len(hookInboxes) >= maxHooks {
+		hooksMutex.Unlock()
+		respondWithError(w, fmt.Sprintf("At most %d webhooks can be registered at once", maxHooks), http.StatusConflict)
+		return
+	}
+	inbox := &hookInbox{CreatedAt: now, LastActivity: now, Captured: []CapturedRequest{}}
+	hookInboxes[slug] = inbox
+	summary := hookSummary(r, slug, inbox)
+	hooksMutex.Unlock()
+
+	slog.Info("registered webhook", "slug", slug)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(summary); err != nil {
+		slog.Error("failed to encode webhook", "error", err)
+	}
+}
+
+// captureHook records a delivery to a registered webhook, whatever its method
+func (api *API) captureHook(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxHookBodyBytes+1))
+	if err != nil {
+		respondWithError(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	size := int64(len(body))
+	truncated := size > maxHookBodyBytes
+	if truncated {
+		body = body[:maxHookBodyBytes]
+		extra, _ := io.Copy(io.Discard, r.Body)
+		size += extra
+	}
+
+	headers := make(map[string]string, len(r.Header))
+	for name, values := range r.Header {
+		headers[name] = strings.Join(values, ", ")
+	}
+	captured := CapturedRequest{
+		ID:         generateID(),
+		ReceivedAt: time.Now().Format(time.RFC3339Nano),
+		Method:     r.Method,
+		Query:      r.URL.RawQuery,
+		Headers:    headers,
+		Body:       string(body),
+		SizeBytes:  size,
+		Truncated:  truncated,
+		RemoteAddr: r.RemoteAddr,
+	}
+	if !utf8.Valid(body) {
+		captured.Body = base64.StdEncoding.EncodeToString(body)
+		captured.BodyBase64 = true
+	}
+
+	now := time.Now()
+	hooksMutex.Lock()
+	pruneIdleHooksLocked(now)
+	inbox, ok := hookInboxes[slug]
+	if ok {
+		inbox.Captured = append(inbox.Captured, captured)
+		if len(inbox.Captured) > maxHookCaptures {
+			inbox.Captured = append([]CapturedRequest(nil), inbox.Captured[len(inbox.Captured)-maxHookCaptures:]...)
+		}
+		inbox.LastActivity = now
+	}
+	hooksMutex.Unlock()
+
+	if !ok {
+		respondWithError(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	slog.Info("webhook delivery", "slug", slug, "method", r.Method, "bytes", size)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]any{"received": true, "id": captured.ID}); err != nil {
+		slog.Error("failed to encode webhook receipt", "error", err)
+	}
+}
+
+// capturedHooks handles GET requests for a webhook's deliveries, newest first, and
+// DELETE requests to clear them
+func (api *API) capturedHooks(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	now := time.Now()
+	hooksMutex.Lock()
+	pruneIdleHooksLocked(now)
+	inbox, ok := hookInboxes[slug]
+	var captured []CapturedRequest
+	if ok {
+		inbox.LastActivity = now
+		captured = make([]CapturedRequest, 0, len(inbox.Captured))
+		for i := len(inbox.Captured) - 1; i >= 0; i-- {
+			captured = append(captured, inbox.Captured[i])
+		}
+		if r.Method == http.MethodDelete {
+			inbox.Captured = []CapturedRequest{}
+		}
+	}
+	hooksMutex.Unlock()
+
+	if !ok {
+		respondWithError(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodDelete {
+		slog.Info("cleared webhook deliveries", "slug", slug, "deliveries", len(captured))
+		if err := json.NewEncoder(w).Encode(map[string]int{"cleared": len(captured)}); err != nil {
+			slog.Error("failed to encode webhook response", "error", err)
+		}
+		return
+	}
+	if err := json.NewEncoder(w).Encode(map[string]any{"slug": slug, "captured": captured}); err != nil {
+		slog.Error("failed to encode webhook deliveries", "error", err)
+	}
+}
+
+// =============================================================================
+// DRAFTS
+// =============================================================================
+
+// Drafts hold in-progress edits so they survive a closed browser tab. Each saved
+// request has at most one, and a request that hasn't been saved yet uses "new".
+const (
+	newRequestDraftID       = "new"
+	defaultDraftIdleSeconds = 5
+	maxDraftIdleSeconds     = 3600
+)
+
+// Draft is an unsaved edit of a request, stored apart from the committed definition
+type Draft struct {
+	RequestID string             `json:"requestId"` // Saved request ID, or "new"
+	Request   SaveRequestPayload `json:"request"`   // Definition as last edited, not validated
+	SavedAt   string             `json:"savedAt"`
+}
+
+// pruneOrphanDrafts drops drafts whose request no longer exists, e.g. after it was
+// deleted or replaced by a bundle import
+func pruneOrphanDrafts(data *SavedRequestsData) {
+	if len(data.Drafts) == 0 {
+		return
+	}
+	live := make(map[string]bool, len(data.Requests))
+	for _, request := range data.Requests {
+		live[request.ID] = true
+	}
+	for id := range data.Drafts {
+		if id != newRequestDraftID && !live[id] {
+			delete(data.Drafts, id)
+			noteRepair(data, repairDroppedDraft, id, "", "its request no longer exists")
+		}
+	}
+}
+
+// saveDraft handles POST requests to store an in-progress edit of a request
+//
+// The body is {"requestId": "<id>", "request": {...}}, where request takes the same
+// fields as /api/requests/save. Omit requestId for a request that hasn't been saved
+// yet. Nothing is validated beyond the request existing, since drafts are often
+// incomplete; a later save or update of the request discards its draft.
+func (api *API) saveDraft(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		RequestID string             `json:"requestId"`
+		Request   SaveRequestPayload `json:"request"`
+	}
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+	if req.RequestID == "" {
+		req.RequestID = newRequestDraftID
+	}
+
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load saved requests", "error", err)
+		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
+		return
+	}
+
+	if req.RequestID != newRequestDraftID {
+		found := false
+		for _, existing := range data.Requests {
+			if existing.ID == req.RequestID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			respondWithError(w, "Request not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	// A draft is a definition only; responses belong to the committed request
+	req.Request.LastResponse = nil
+	draft := Draft{
+		RequestID: req.RequestID,
+		Request:   req.Request,
+		SavedAt:   time.Now().Format(time.RFC3339),
+	}
+	if data.Drafts == nil {
+		data.Drafts = make(map[string]Draft)
+	}
+	data.Drafts[req.RequestID] = draft
+
+	if err := api.store.Save(data); err != nil {
+		slog.Error("failed to save draft", "error", err)
+		respondWithError(w, "Failed to save draft", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("saved draft", "request", req.RequestID)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{
+		"status":    "saved",
+		"requestId": draft.RequestID,
+		"savedAt":   draft.SavedAt,
+	}); err != nil {
+		slog.Error("failed to encode draft response", "error", err)
+	}
+}
+
+// requestDraft handles GET requests to restore the draft of a request ("new" for an unsaved one)
+func (api *API) requestDraft(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load saved requests", "error", err)
+		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
+		return
+	}
+
+	draft, ok := data.Drafts[chi.URLParam(r, "id")]
+	if !ok {
+		respondWithError(w, "No draft for this request", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(draft); err != nil {
+		slog.Error("failed to encode draft", "error", err)
+	}
+}
+
+// discardDraft handles DELETE requests to throw away the draft of a request
+func (api *API) discardDraft(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load saved requests", "error", err)
+		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
+		return
+	}
+
+	if _, ok := data.Drafts[id]; !ok {
+		respondWithError(w, "No draft for this request", http.StatusNotFound)
+		return
+	}
+	delete(data.Drafts, id)
+
+	if err := api.store.Save(data); err != nil {
+		slog.Error("failed to discard draft", "error", err)
+		respondWithError(w, "Failed to discard draft", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("discarded draft", "request", id)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "discarded"})
+}
+
+// =============================================================================
+// TRASH HANDLERS
+// =============================================================================
+
+// trashRetention is how long soft-deleted requests are kept before being purged
+const trashRetention = 30 * 24 * time.Hour
+
+// purgeExpiredTrash removes trash entries deleted longer ago than trashRetention
+func purgeExpiredTrash(data *SavedRequestsData) {
+	cutoff := time.Now().Add(-trashRetention)
+	kept := make([]SavedRequest, 0, len(data.Trash))
+	for _, item := range data.Trash {
+		if deletedAt, err := time.Parse(time.RFC3339, item.DeletedAt); err == nil && deletedAt.Before(cutoff) {
+			slog.Info("purging expired trash entry", "name", item.Name, "id", item.ID)
+			noteRepair(data, repairPurgedTrash, item.ID, item.Name, "in the trash since "+item.DeletedAt)
+			continue
+		}
+		kept = append(kept, item)
+	}
+	data.Trash = kept
+}
+
+// trash handles GET requests to list soft-deleted requests
+func (api *API) trash(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load trash", "error", err)
+		respondWithError(w, "Failed to load trash", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string][]SavedRequest{"trash": data.Trash}); err != nil {
+		slog.Error("failed to encode trash", "error", err)
+	}
+}
+
+// restoreTrash handles POST requests to move a request from the trash back into the collection
+func (api *API) restoreTrash(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	requestID := chi.URLParam(r, "id")
+	if requestID == "" {
+		respondWithError(w, "Request ID is required", http.StatusBadRequest)
+		return
+	}
+
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load saved requests", "error", err)
+		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
+		return
+	}
+
+	index := -1
+	for i, item := range data.Trash {
+		if item.ID == requestID {
+			index = i
+			break
+		}
+	}
+
+	if index == -1 {
+		respondWithError(w, "Request not found in trash", http.StatusNotFound)
+		return
+	}
+
+	restored := data.Trash[index]
+	data.Trash = append(data.Trash[:index], data.Trash[index+1:]...)
+
+	// Avoid collisions with requests created since the deletion
+	restored.Name = uniqueName(restored.Name, data.Requests)
+	restored.DeletedAt = ""
+	restored.UpdatedAt = time.Now().Format(time.RFC3339)
+	restored.Version++
+
+	// Fall back to the default group if the original group was removed
+	groupExists := false
+	for _, group := range data.Groups {
+		if group.Name == restored.Group {
+			groupExists = true
+			break
+		}
+	}
+	if !groupExists {
+		restored.Group = "default"
+	}
+
+	data.Requests = append(data.Requests, restored)
+
+	if err := api.store.Save(data); err != nil {
+		slog.Error("failed to save restored request", "error", err)
+		respondWithError(w, "Failed to save restored request", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("restored request from trash", "name", restored.Name, "id", restored.ID)
+	recordAudit(r, "restore", "request", restored.ID, restored.Name)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(restored); err != nil {
+		slog.Error("failed to encode restored request response", "error", err)
+	}
+}
+
+// purgeTrash handles DELETE requests to permanently remove a request from the trash
+func (api *API) purgeTrash(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	requestID := chi.URLParam(r, "id")
+	if requestID == "" {
+		respondWithError(w, "Request ID is required", http.StatusBadRequest)
+		return
+	}
+
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load saved requests", "error", err)
+		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
+		return
+	}
+
+	var purged SavedRequest
+	found := false
+	for i, item := range data.Trash {
+		if item.ID == requestID {
+			data.Trash = append(data.Trash[:i], data.Trash[i+1:]...)
+			purged = item
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		respondWithError(w, "Request not found in trash", http.StatusNotFound)
+		return
+	}
+
+	if err := api.store.Save(data); err != nil {
+		slog.Error("failed to save after purging trash", "error", err)
+		respondWithError(w, "Failed to purge request", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("permanently deleted request", "id", requestID)
+	recordAudit(r, "purge", "request", purged.ID, purged.Name)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "deleted"})
+}
+
+// =============================================================================
+// SECRET VARIABLES
+// =============================================================================
+
+// secretMask replaces secret values in API responses and logs
+const secretMask = "••••"
+
+// revealSecrets reports whether the client asked for secret values in plain text
+func revealSecrets(r *http.Request) bool {
+	return r.URL.Query().Get("reveal") == "true"
+}
+
+// maskSecret hides a secret value, keeping the last four characters of long values as a hint
+func maskSecret(value string) string {
+	runes := []rune(value)
+	if len(runes) < 8 {
+		return secretMask
+	}
+	return secretMask + string(runes[len(runes)-4:])
+}
+
+// maskVariables returns a copy of variables with secret values masked
+func maskVariables(variables []Variable) []Variable {
+	masked := make([]Variable, len(variables))
+	for i, variable := range variables {
+		masked[i] = variable
+		if variable.Secret {
+			masked[i].Value = maskSecret(variable.Value)
+		}
+	}
+	return masked
+}
+
+// maskEffectiveVariables returns a copy of an effective variable set with secret values masked
+func maskEffectiveVariables(variables []EffectiveVariable) []EffectiveVariable {
+	masked := make([]EffectiveVariable, len(variables))
+	for i, variable := range variables {
+		masked[i] = variable
+		if variable.Secret {
+			masked[i].Value = maskSecret(variable.Value)
+		}
+	}
+	return masked
+}
+
+// maskEnvironments returns a copy of environments with secret values masked
+func maskEnvironments(environments []Environment) []Environment {
+	masked := make([]Environment, len(environments))
+	for i, env := range environments {
+		masked[i] = env
+		masked[i].Variables = maskVariables(env.Variables)
+	}
+	return masked
+}
+
+// stripSecrets returns a copy of variables with secret values removed, for exports
+func stripSecrets(variables []Variable) []Variable {
+	stripped := make([]Variable, len(variables))
+	for i, variable := range variables {
+		stripped[i] = variable
+		if variable.Secret {
+			stripped[i].Value = ""
+		}
+	}
+	return stripped
+}
+
+// restoreMaskedSecrets keeps the stored value of secret variables that a client sent
+// back still masked, so saving a masked listing doesn't overwrite the real values
+func restoreMaskedSecrets(incoming, stored []Variable) []Variable {
+	for i, variable := range incoming {
+		for _, existing := range stored {
+			if existing.Key == variable.Key && existing.Secret && variable.Value == maskSecret(existing.Value) {
+				incoming[i].Value = existing.Value
+				break
+			}
+		}
+	}
+	return incoming
+}
+
+// redactRequest returns a copy of a resolved request with secret values masked in the
+// URL, headers, body and variables
+func redactRequest(req ProxyRequest, variables []Variable) ProxyRequest {
+	req.URL = redactSecrets(req.URL, variables)
+	req.Body = redactSecrets(req.Body, variables)
+
+	headers := make(map[string]string, len(req.Headers))
+	for key, value := range req.Headers {
+		headers[key] = redactSecrets(value, variables)
+	}
+	req.Headers = headers
+
+	redactFields := func(fields []BodyField) []BodyField {
+		if fields == nil {
+			return nil
+		}
+		redacted := make([]BodyField, len(fields))
+		for i, f := range fields {
+			f.Value = redactSecrets(f.Value, variables)
+			redacted[i] = f
+		}
+		return redacted
+	}
+	req.BodyJson = redactFields(req.BodyJson)
+	req.BodyForm = redactFields(req.BodyForm)
+	req.Variables = maskVariables(req.Variables)
+	req.Overrides = maskVariables(req.Overrides)
+
+	if req.Auth != nil && req.Auth.OAuth2 != nil {
+		req.Auth = copyAuthConfig(req.Auth)
+		req.Auth.OAuth2.ClientSecret = redactSecrets(req.Auth.OAuth2.ClientSecret, variables)
+	}
+	return req
+}
+
+// workspaceSecrets returns the secret variables of every environment and the globals, for
+// redacting exchanges whose environment isn't known
+func workspaceSecrets(data *SavedRequestsData) []Variable {
+	var secrets []Variable
+	for _, env := range data.Environments {
+		for _, variable := range env.Variables {
+			if variable.Secret {
+				secrets = append(secrets, variable)
+			}
+		}
+	}
+	for _, variable := range data.Globals {
+		if variable.Secret {
+			secrets = append(secrets, variable)
+		}
+	}
+	return secrets
+}
+
+// redactResponse masks secret values in a response's request echo, the header fields
+// written on the wire and the error message
+func redactResponse(resp *ProxyResponse, variables []Variable) {
+	if resp.Request != nil {
+		echo := redactRequest(*resp.Request, variables)
+		resp.Request = &echo
+	}
+	if resp.SentHeaders != nil {
+		sent := make([]HARNameValue, len(resp.SentHeaders))
+		for i, field := range resp.SentHeaders {
+			sent[i] = HARNameValue{Name: field.Name, Value: redactSecrets(field.Value, variables)}
+		}
+		resp.SentHeaders = sent
+	}
+	resp.Error = redactSecrets(resp.Error, variables)
+}
+
+// minRedactLength is the shortest secret value redacted from text; shorter values would
+// match unrelated parts of URLs and headers
+const minRedactLength = 4
+
+// redactSecrets replaces the resolved values of secret variables in text, for logging
+//
+// Longer values are replaced first so a secret containing another isn't left half-masked.
+func redactSecrets(text string, variables []Variable) string {
+	var values []string
+	for _, variable := range variables {
+		if !variable.Secret {
+			continue
+		}
+		if value := resolveEnvVar(variable.Value); len(value) >= minRedactLength {
+			values = append(values, value)
+		}
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+
+	for _, value := range values {
+		text = strings.ReplaceAll(text, value, secretMask)
+	}
+	return text
+}
+
+// =============================================================================
+// VARIABLE & ENVIRONMENT HANDLERS
+// =============================================================================
+
+// VariableWithResolved represents a variable with its raw and resolved values
+type VariableWithResolved struct {
+	Key           string `json:"key"`
+	Value         string `json:"value"`         // Raw value (e.g., "$HOME")
+	ResolvedValue string `json:"resolvedValue"` // Resolved value (e.g., "/Users/jeremiah.zink")
+	IsEnvVar      bool   `json:"isEnvVar"`      // Whether this is an environment variable reference
+	Secret        bool   `json:"secret"`        // Values are masked unless ?reveal=true
+}
+
+// EffectiveVariable is one entry of the merged variable set used for a request
+type EffectiveVariable struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"` // "preRequest", "override", "environment", or "global"
+	Secret bool   `json:"secret,omitempty"`
+}
+
+// variableLayer is a named set of variables used to build an effective variable set
+type variableLayer struct {
+	source    string
+	variables []Variable
+}
+
+// applyEnvironmentHeaders adds the environment's headers to a request, keeping any
+// header the request already sets (compared case-insensitively)
+func applyEnvironmentHeaders(req *ProxyRequest, env *Environment) {
+	if len(env.Headers) == 0 {
+		return
+	}
+	if req.Headers == nil {
+		req.Headers = make(map[string]string, len(env.Headers))
+	}
+
+	for _, name := range sortedHeaderNames(env.Headers) {
+		if headerValue(req.Headers, name) != "" {
+			slog.Debug("environment header overridden by request", "header", name)
+			continue
+		}
+		req.Headers[name] = env.Headers[name]
+		slog.Debug("applied environment header", "header", name, "environment", env.Name)
+	}
+}
+
+// applyEnvironmentTimeout gives a request without its own timeout the environment's
+// default; a request with neither uses proxyTimeout
+func applyEnvironmentTimeout(req *ProxyRequest, env *Environment) {
+	if req.TimeoutMs == 0 {
+		req.TimeoutMs = env.DefaultTimeoutMs
+	}
+}
+
+// normalizeHostOverrides checks host overrides and returns them with lower-case keys
+//
+// Keys are a host name or IP, optionally with a port to override only that port. Values
+// are the address to connect to instead, with or without a port; without one, the
+// request's port is kept.
+func normalizeHostOverrides(overrides map[string]string) (map[string]string, error) {
+	if len(overrides) == 0 {
+		return nil, nil
+	}
+	normalized := make(map[string]string, len(overrides))
+	for key, value := range overrides {
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if err := checkOverrideAddress(key); err != nil {
+			return nil, fmt.Errorf("hostOverrides: invalid host %q: %v", key, err)
+		}
+		if err := checkOverrideAddress(value); err != nil {
+			return nil, fmt.Errorf("hostOverrides: invalid address %q for %s: %v", value, key, err)
+		}
+		if _, dup := normalized[key]; dup {
+			return nil, fmt.Errorf("hostOverrides: %s appears more than once", key)
+		}
+		normalized[key] = value
+	}
+	return normalized, nil
+}
+
+// checkOverrideAddress accepts a bare host or IP, or host:port (IPv6 in brackets)
+func checkOverrideAddress(address string) error {
+	if address == "" {
+		return errors.New("must not be empty")
+	}
+	if strings.ContainsAny(address, "/?#@ ") {
+		return errors.New("expected a host name or IP, with an optional port, not a URL")
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		// No port: a host name, IPv4 address or bracketed IPv6 address
+		host = strings.TrimSuffix(strings.TrimPrefix(address, "["), "]")
+		if strings.Contains(host, ":") && net.ParseIP(host) == nil || host == "" {
+			return errors.New("expected a host name or IP, with an optional port")
+		}
+		return nil
+	}
+	if host == "" {
+		return errors.New("host is missing")
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("port must be a number from 1 to 65535, got %q", port)
+	}
+	return nil
+}
+
+// applyEnvironmentHostOverrides adds the environment's host overrides to a request's
+// own, which win for the same key
+func applyEnvironmentHostOverrides(req *ProxyRequest, env *Environment) {
+	if len(env.HostOverrides) == 0 {
+		return
+	}
+	merged := maps.Clone(env.HostOverrides)
+	maps.Copy(merged, req.HostOverrides)
+	req.HostOverrides = merged
+}
+
+// hostOverrideTarget returns where to connect for addr (host:port): an override for
+// host:port wins over one for the bare host, which keeps addr's port unless it names one
+func hostOverrideTarget(overrides map[string]string, addr string) (string, bool) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", false
+	}
+	host = strings.ToLower(host)
+	target, ok := overrides[net.JoinHostPort(host, port)]
+	if !ok {
+		if target, ok = overrides[host]; !ok && strings.Contains(host, ":") {
+			target, ok = overrides["["+host+"]"]
+		}
+	}
+	if !ok {
+		return "", false
+	}
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		target = net.JoinHostPort(strings.TrimSuffix(strings.TrimPrefix(target, "["), "]"), port)
+	}
+	return target, true
+}
+
+// urlSchemePattern matches the scheme at the start of an absolute URL, such as "https://"
+var urlSchemePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]*://`)
+
+// applyEnvironmentBaseURL prefixes a relative request URL (one without a scheme, such as
+// "/v2/users" or "v2/users") with the environment's base URL; absolute URLs and URLs
+// starting with a placeholder like {{baseUrl}} are left untouched
+func applyEnvironmentBaseURL(req *ProxyRequest, env *Environment) error {
+	if urlSchemePattern.MatchString(req.URL) || strings.HasPrefix(req.URL, "{{") {
+		return nil
+	}
+	if env.BaseURL == "" {
+		return fmt.Errorf("relative URL %q needs a base URL: set baseUrl on environment %q or use an absolute URL", req.URL, env.Name)
+	}
+	req.URL = strings.TrimRight(env.BaseURL, "/") + "/" + strings.TrimLeft(req.URL, "/")
+	slog.Debug("applied base URL", "environment", env.Name)
+	return nil
+}
+
+// scopeVariables returns the variables available to every request in an environment:
+// the environment's own, then those of its parent chain, then globals
+func scopeVariables(data *SavedRequestsData, env *Environment) []Variable {
+	var result []Variable
+	for _, layer := range scopeLayers(data, env) {
+		result = append(result, layer.variables...)
+	}
+	return result
+}
+
+// scopeLayers returns the named layers behind scopeVariables, highest precedence first
+//
+// Inherited layers are named "parent:<environment name>".
+func scopeLayers(data *SavedRequestsData, env *Environment) []variableLayer {
+	chain := environmentChain(data, env)
+	layers := make([]variableLayer, 0, len(chain)+1)
+	layers = append(layers, variableLayer{"environment", env.Variables})
+	for _, parent := range chain[1:] {
+		layers = append(layers, variableLayer{"parent:" + parent.Name, parent.Variables})
+	}
+	return append(layers, variableLayer{"global", data.Globals})
+}
+
+// environmentChain returns an environment followed by its ancestors, nearest first
+//
+// A parent that no longer exists or that leads back into the chain ends it, so a
+// broken parentId never stops variables from resolving.
+func environmentChain(data *SavedRequestsData, env *Environment) []*Environment {
+	chain := []*Environment{env}
+	seen := map[string]bool{env.ID: true}
+	for id := env.ParentID; id != ""; {
+		parent := findEnvironment(data, id)
+		if parent == nil {
+			slog.Warn("parent environment not found", "parent", id, "environment", chain[len(chain)-1].Name)
+			break
+		}
+		if seen[id] {
+			slog.Warn("environment parent cycle", "environment", parent.Name)
+			break
+		}
+		seen[id] = true
+		chain = append(chain, parent)
+		id = parent.ParentID
+	}
+	return chain
+}
+
+// checkEnvironmentParent validates making parentID the parent of envID; an empty
+// parentID means no parent
+func checkEnvironmentParent(data *SavedRequestsData, envID, parentID string) error {
+	if parentID == "" {
+		return nil
+	}
+	if parentID == envID {
+		return fmt.Errorf("an environment can't be its own parent")
+	}
+	// Walk up from the new parent; reaching the environment itself means a cycle
+	for id, depth := parentID, 0; id != "" && depth <= len(data.Environments); depth++ {
+		parent := findEnvironment(data, id)
+		if parent == nil {
+			return fmt.Errorf("parent environment not found: %s", id)
+		}
+		if parent.ID == envID {
+			return fmt.Errorf("environment parents can't form a cycle")
+		}
+		id = parent.ParentID
+	}
+	return nil
+}
+
+// layerVariables concatenates variable sets from highest to lowest precedence
+//
+// Template resolution uses the first definition of a key, so earlier layers win.
+func layerVariables(layers ...[]Variable) []Variable {
+	var result []Variable
+	for _, layer := range layers {
+		result = append(result, layer...)
+	}
+	return result
+}
+
+// effectiveVariables merges layers (highest precedence first) into one entry per key,
+// recording which layer each value came from
+func effectiveVariables(layers ...variableLayer) []EffectiveVariable {
+	result := []EffectiveVariable{}
+	seen := make(map[string]bool)
+	for _, layer := range layers {
+		for _, variable := range layer.variables {
+			if variable.Key == "" || seen[variable.Key] {
+				continue
+			}
+			seen[variable.Key] = true
+			result = append(result, EffectiveVariable{Key: variable.Key, Value: variable.Value, Source: layer.source, Secret: variable.Secret})
+		}
+	}
+	return result
+}
+
+// ResponseVariableRef advertises both reference forms for a saved request's response
+type ResponseVariableRef struct {
+	RequestID   string `json:"requestId"`
+	RequestName string `json:"requestName"`
+	ByName      string `json:"byName"`      // e.g. {{"Login".}}
+	ByID        string `json:"byId"`        // e.g. {{#a1b2c3d4.}}
+	HasResponse bool   `json:"hasResponse"` // Whether a LastResponse is available to reference
+}
+
+// variables handles GET requests to retrieve variables from current environment
+func (api *API) variables(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load variables", "error", err)
+		respondWithError(w, "Failed to load variables", http.StatusInternalServerError)
+		return
+	}
+
+	// Get current environment
+	currentEnv, err := getActiveEnvironment(r, data)
+	if err != nil {
+		slog.Error("failed to get current environment", "error", err)
+		respondWithError(w, "Failed to get current environment", http.StatusInternalServerError)
+		return
+	}
+
+	// Return raw values with resolved values for display
+	reveal := revealSecrets(r)
+	variablesWithResolved := make([]VariableWithResolved, len(currentEnv.Variables))
+	for i, variable := range currentEnv.Variables {
+		isEnvVar := strings.HasPrefix(variable.Value, "$")
+		resolvedValue := variable.Value
+		if isEnvVar {
+			resolvedValue = resolveEnvVar(variable.Value)
+		}
+
+		value := variable.Value
+		if variable.Secret && !reveal {
+			value = maskSecret(value)
+			resolvedValue = maskSecret(resolvedValue)
+		}
+
+		variablesWithResolved[i] = VariableWithResolved{
+			Key:           variable.Key,
+			Value:         value,         // Keep raw value like "$HOME"
+			ResolvedValue: resolvedValue, // Show resolved value like "/Users/jeremiah.zink"
+			IsEnvVar:      isEnvVar,
+			Secret:        variable.Secret,
+		}
+	}
+
+	// Advertise response variable references in both name and ID forms for autocomplete
+	responseVariables := make([]ResponseVariableRef, len(data.Requests))
+	for i, request := range data.Requests {
+		responseVariables[i] = ResponseVariableRef{
+			RequestID:   request.ID,
+			RequestName: request.Name,
+			ByName:      fmt.Sprintf("{{%q.}}", request.Name),
+			ByID:        fmt.Sprintf("{{#%s.}}", request.ID),
+			HasResponse: request.LastResponse != nil,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]any{
+		"variables":         variablesWithResolved,
+		"responseVariables": responseVariables,
+	}
+
+	// With ?requestId=, also show the merged set that request would use
+	if requestID := r.URL.Query().Get("requestId"); requestID != "" {
+		var overrides []Variable
+		found := false
+		for _, request := range data.Requests {
+			if request.ID == requestID {
+				overrides = request.Overrides
+				found = true
+				break
+			}
+		}
+		if !found {
+			respondWithError(w, "Request not found", http.StatusNotFound)
+			return
+		}
+		effective := effectiveVariables(
+			append([]variableLayer{{"override", overrides}}, scopeLayers(data, currentEnv)...)...,
+		)
+		if !reveal {
+			effective = maskEffectiveVariables(effective)
+		}
+		response["effectiveVariables"] = effective
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.Error("failed to encode variables", "error", err)
+	}
+}
+
+// ReplaceResult counts the variable values a find-and-replace changed in one environment
+type ReplaceResult struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Changed int    `json:"changed"`
+}
+
+// replaceVariables handles POST requests to find and replace text in variable values
+//
+// The scope is the session's active environment ("current", the default) or every
+// environment ("all"). All replacements are made in memory and written with a single
+// save, so either every environment is updated or none is.
+func (api *API) replaceVariables(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Find    string `json:"find"`
+		Replace string `json:"replace"`
+		Scope   string `json:"scope"`
+	}
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+	if req.Find == "" {
+		respondWithError(w, "find is required", http.StatusBadRequest)
+		return
+	}
+	if req.Scope == "" {
+		req.Scope = "current"
+	}
+	if req.Scope != "current" && req.Scope != "all" {
+		respondWithError(w, "scope must be \"current\" or \"all\"", http.StatusBadRequest)
+		return
+	}
+
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load saved data", "error", err)
+		respondWithError(w, "Failed to load saved data", http.StatusInternalServerError)
+		return
+	}
+
+	var targets []*Environment
+	if req.Scope == "all" {
+		for i := range data.Environments {
+			targets = append(targets, &data.Environments[i])
+		}
+	} else {
+		activeEnv, err := getActiveEnvironment(r, data)
+		if err != nil {
+			slog.Error("current environment not found", "environment", data.CurrentEnvironment)
+			respondWithError(w, "Current environment not found", http.StatusInternalServerError)
+			return
+		}
+		targets = []*Environment{activeEnv}
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	results := []ReplaceResult{}
+	total := 0
+	for _, env := range targets {
+		changed := 0
+		for i := range env.Variables {
+			if strings.Contains(env.Variables[i].Value, req.Find) {
+				env.Variables[i].Value = strings.ReplaceAll(env.Variables[i].Value, req.Find, req.Replace)
+				changed++
+			}
+		}
+		if changed > 0 {
+			env.UpdatedAt = now
+			env.Version++
+			results = append(results, ReplaceResult{ID: env.ID, Name: env.Name, Changed: changed})
+			total += changed
+		}
+	}
+
+	if total > 0 {
+		if err := api.store.Save(data); err != nil {
+			slog.Error("failed to save replaced variables", "error", err)
+			respondWithError(w, "Failed to save variables", http.StatusInternalServerError)
+			return
+		}
+		for _, result := range results {
+			recordAudit(r, "update", "environment", result.ID, result.Name)
+		}
+	}
+
+	slog.Info("replaced text in variable values", "values", total, "environments", len(results), "scope", req.Scope)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]any{
+		"changed":      total,
+		"environments": results,
+	}); err != nil {
+		slog.Error("failed to encode replace response", "error", err)
+	}
 }
 
-// getCurrentEnvironment returns the current active environment
-func getCurrentEnvironment(data *SavedRequestsData) (*Environment, error) {
-	if data.CurrentEnvironment == "" && len(data.Environments) > 0 {
-		data.CurrentEnvironment = data.Environments[0].ID
+// saveVariables handles POST requests to save variables to current environment
+func (api *API) saveVariables(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Variables []Variable `json:"variables"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("invalid request body for save variables", "error", err)
+		respondWithError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Load existing data
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load saved data", "error", err)
+		respondWithError(w, "Failed to load saved data", http.StatusInternalServerError)
+		return
+	}
+
+	// Update the session's active environment
+	activeEnv, err := getActiveEnvironment(r, data)
+	if err != nil {
+		slog.Error("current environment not found", "environment", data.CurrentEnvironment)
+		respondWithError(w, "Current environment not found", http.StatusInternalServerError)
+		return
+	}
+	activeEnv.Variables = restoreMaskedSecrets(req.Variables, activeEnv.Variables)
+	activeEnv.UpdatedAt = time.Now().Format(time.RFC3339)
+	activeEnv.Version++
+
+	// Save to file
+	if err := api.store.Save(data); err != nil {
+		slog.Error("failed to save variables", "error", err)
+		respondWithError(w, "Failed to save variables", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("saved variables", "count", len(req.Variables), "environment", activeEnv.ID)
+	recordAudit(r, "update", "environment", activeEnv.ID, activeEnv.Name)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{"status": "saved"}); err != nil {
+		slog.Error("failed to encode variables response", "error", err)
+	}
+}
+
+// globals handles GET requests to list global variables
+func (api *API) globals(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load globals", "error", err)
+		respondWithError(w, "Failed to load globals", http.StatusInternalServerError)
+		return
+	}
+
+	globalVars := data.Globals
+	if !revealSecrets(r) {
+		globalVars = maskVariables(globalVars)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string][]Variable{"globals": globalVars}); err != nil {
+		slog.Error("failed to encode globals", "error", err)
+	}
+}
+
+// saveGlobals handles POST requests to replace the global variables
+func (api *API) saveGlobals(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Globals []Variable `json:"globals"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("invalid request body for save globals", "error", err)
+		respondWithError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load saved data", "error", err)
+		respondWithError(w, "Failed to load saved data", http.StatusInternalServerError)
+		return
+	}
+
+	data.Globals = restoreMaskedSecrets(req.Globals, data.Globals)
+	if data.Globals == nil {
+		data.Globals = []Variable{}
+	}
+
+	if err := api.store.Save(data); err != nil {
+		slog.Error("failed to save globals", "error", err)
+		respondWithError(w, "Failed to save globals", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("saved global variables", "count", len(data.Globals))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{"status": "saved"}); err != nil {
+		slog.Error("failed to encode globals response", "error", err)
+	}
+}
+
+// environments handles GET requests to list all environments
+func (api *API) environments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load environments", "error", err)
+		respondWithError(w, "Failed to load environments", http.StatusInternalServerError)
+		return
+	}
+
+	envs := data.Environments
+	if !revealSecrets(r) {
+		envs = maskEnvironments(envs)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]any{
+		"environments":       envs,
+		"currentEnvironment": data.CurrentEnvironment,
+	}
+	if envID := sessionEnvironmentID(r); envID != "" && findEnvironment(data, envID) != nil {
+		response["sessionEnvironment"] = envID
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.Error("failed to encode environments", "error", err)
+	}
+}
+
+// createEnvironment handles POST requests to create a new environment
+func (api *API) createEnvironment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Name             string            `json:"name"`
+		Headers          map[string]string `json:"headers,omitempty"`
+		BaseURL          string            `json:"baseUrl,omitempty"`
+		ParentID         string            `json:"parentId,omitempty"`
+		DefaultTimeoutMs int               `json:"defaultTimeoutMs,omitempty"`
+		HostOverrides    map[string]string `json:"hostOverrides,omitempty"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("invalid request body for create environment", "error", err)
+		respondWithError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	name, err := normalizeName("environment", req.Name)
+	if err != nil {
+		respondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Name = name
+	if err := validateTimeoutMs("defaultTimeoutMs", req.DefaultTimeoutMs); err != nil {
+		respondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.HostOverrides, err = normalizeHostOverrides(req.HostOverrides); err != nil {
+		respondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Load existing data
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load saved data", "error", err)
+		respondWithError(w, "Failed to load saved data", http.StatusInternalServerError)
+		return
+	}
+
+	// Check if environment name already exists
+	for _, env := range data.Environments {
+		if env.Name == req.Name {
+			respondWithError(w, "Environment name already exists", http.StatusConflict)
+			return
+		}
+	}
+	if req.ParentID != "" && findEnvironment(data, req.ParentID) == nil {
+		respondWithError(w, fmt.Sprintf("Parent environment not found: %s", req.ParentID), http.StatusNotFound)
+		return
+	}
+
+	// Create new environment
+	now := time.Now().Format(time.RFC3339)
+	newEnv := Environment{
+		ID:               generateID(),
+		Name:             req.Name,
+		Variables:        []Variable{},
+		Headers:          req.Headers,
+		BaseURL:          strings.TrimSpace(req.BaseURL),
+		ParentID:         req.ParentID,
+		DefaultTimeoutMs: req.DefaultTimeoutMs,
+		HostOverrides:    req.HostOverrides,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+
+	data.Environments = append(data.Environments, newEnv)
+
+	// Save to file
+	if err := api.store.Save(data); err != nil {
+		slog.Error("failed to save environment", "error", err)
+		respondWithError(w, "Failed to save environment", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("created environment", "name", newEnv.Name, "id", newEnv.ID)
+	recordAudit(r, "create", "environment", newEnv.ID, newEnv.Name)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(newEnv); err != nil {
+		slog.Error("failed to encode environment response", "error", err)
+	}
+}
+
+// updateEnvironment handles PUT requests to update an environment
+func (api *API) updateEnvironment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	envID := chi.URLParam(r, "id")
+	if envID == "" {
+		respondWithError(w, "Environment ID is required", http.StatusBadRequest)
+		return
 	}
 
+	var req struct {
+		Name             string             `json:"name"`
+		Variables        []Variable         `json:"variables"`
+		Headers          *map[string]string `json:"headers,omitempty"`
+		BaseURL          *string            `json:"baseUrl,omitempty"`
+		ParentID         *string            `json:"parentId,omitempty"`         // "" removes the parent
+		DefaultTimeoutMs *int               `json:"defaultTimeoutMs,omitempty"` // 0 removes the default
+		HostOverrides    *map[string]string `json:"hostOverrides,omitempty"`    // {} removes every override
+		Version          *int               `json:"version,omitempty"`          // Client's known version
+		UpdatedAt        *string            `json:"updatedAt,omitempty"`        // Client's known UpdatedAt
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("invalid request body for update environment", "error", err)
+		respondWithError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// An empty name leaves the current name unchanged; anything else must be valid
+	if req.Name != "" {
+		name, err := normalizeName("environment", req.Name)
+		if err != nil {
+			respondWithError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Name = name
+	}
+	if req.DefaultTimeoutMs != nil {
+		if err := validateTimeoutMs("defaultTimeoutMs", *req.DefaultTimeoutMs); err != nil {
+			respondWithError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.HostOverrides != nil {
+		overrides, err := normalizeHostOverrides(*req.HostOverrides)
+		if err != nil {
+			respondWithError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.HostOverrides = &overrides
+	}
+
+	// Load existing data
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load saved data", "error", err)
+		respondWithError(w, "Failed to load saved data", http.StatusInternalServerError)
+		return
+	}
+
+	// Find and update environment
+	found := false
+	var updated Environment
 	for i := range data.Environments {
-		if data.Environments[i].ID == data.CurrentEnvironment {
-			return &data.Environments[i], nil
+		if data.Environments[i].ID == envID {
+			if err := checkPrecondition(r, req.Version, req.UpdatedAt, data.Environments[i].Version, data.Environments[i].UpdatedAt); err != nil {
+				respondWithError(w, err.Error(), preconditionStatus(err))
+				return
+			}
+			if req.Name != "" {
+				// Check if new name conflicts with existing environments
+				for j, env := range data.Environments {
+					if j != i && env.Name == req.Name {
+						respondWithError(w, "Environment name already exists", http.StatusConflict)
+						return
+					}
+				}
+				data.Environments[i].Name = req.Name
+			}
+			if req.Variables != nil {
+				data.Environments[i].Variables = restoreMaskedSecrets(req.Variables, data.Environments[i].Variables)
+			}
+			if req.BaseURL != nil {
+				data.Environments[i].BaseURL = strings.TrimSpace(*req.BaseURL)
+			}
+			if req.Headers != nil {
+				data.Environments[i].Headers = *req.Headers
+			}
+			if req.ParentID != nil {
+				if err := checkEnvironmentParent(data, envID, *req.ParentID); err != nil {
+					respondWithError(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				data.Environments[i].ParentID = *req.ParentID
+			}
+			if req.DefaultTimeoutMs != nil {
+				data.Environments[i].DefaultTimeoutMs = *req.DefaultTimeoutMs
+			}
+			if req.HostOverrides != nil {
+				data.Environments[i].HostOverrides = *req.HostOverrides
+			}
+			data.Environments[i].UpdatedAt = time.Now().Format(time.RFC3339)
+			data.Environments[i].Version++
+			updated = data.Environments[i]
+			found = true
+			break
 		}
 	}
 
-	return nil, fmt.Errorf("current environment not found")
-}
+	if !found {
+		respondWithError(w, "Environment not found", http.StatusNotFound)
+		return
+	}
+
+	// Save to file
+	if err := api.store.Save(data); err != nil {
+		slog.Error("failed to save environment", "error", err)
+		respondWithError(w, "Failed to save environment", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("updated environment", "id", envID)
+	recordAudit(r, "update", "environment", updated.ID, updated.Name)
 
-// loadRequests reads saved requests from JSON file
-func loadRequests() (*SavedRequestsData, error) {
-	fileAccessMutex.RLock()
-	defer fileAccessMutex.RUnlock()
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]any{
+		"status":    "updated",
+		"version":   updated.Version,
+		"updatedAt": updated.UpdatedAt,
+	}); err != nil {
+		slog.Error("failed to encode environment response", "error", err)
+	}
+}
 
-	data := &SavedRequestsData{
-		Requests:     []SavedRequest{},
-		Variables:    []Variable{},
-		Environments: []Environment{},
+// deleteEnvironment handles DELETE requests to delete an environment
+func (api *API) deleteEnvironment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
-	if _, err := os.Stat(requestsFileName); os.IsNotExist(err) {
-		// File doesn't exist, create default environment
-		data = initEnv(data)
-		return data, nil
+	envID := chi.URLParam(r, "id")
+	if envID == "" {
+		respondWithError(w, "Environment ID is required", http.StatusBadRequest)
+		return
 	}
 
-	file, err := os.ReadFile(requestsFileName)
+	// Load existing data
+	data, err := api.store.Load()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read requests file: %v", err)
+		slog.Error("failed to load saved data", "error", err)
+		respondWithError(w, "Failed to load saved data", http.StatusInternalServerError)
+		return
 	}
 
-	if len(file) == 0 {
-		// Empty file, create default environment
-		data = initEnv(data)
-		return data, nil
+	// Don't allow deleting the last environment
+	if len(data.Environments) <= 1 {
+		respondWithError(w, "Cannot delete the last environment", http.StatusBadRequest)
+		return
 	}
 
-	if err := json.Unmarshal(file, data); err != nil {
-		log.Printf("⚠️  JSON parse error in %s: %v", requestsFileName, err)
-		log.Printf("🔧 Attempting to recover by creating new empty file")
-		// If JSON is corrupted, create a new file with default environment
-		data = initEnv(data)
-		return data, nil
+	// Find and remove environment
+	found := false
+	deletedName, deletedParent := "", ""
+	newEnvironments := []Environment{}
+	for _, env := range data.Environments {
+		if env.ID != envID {
+			newEnvironments = append(newEnvironments, env)
+		} else {
+			deletedName, deletedParent = env.Name, env.ParentID
+			found = true
+		}
 	}
 
-	// Ensure variables array is not nil
-	if data.Variables == nil {
-		data.Variables = []Variable{}
+	if !found {
+		respondWithError(w, "Environment not found", http.StatusNotFound)
+		return
 	}
 
-	// Ensure environments array is not nil
-	if data.Environments == nil {
-		data.Environments = []Environment{}
-	}
+	data.Environments = newEnvironments
 
-	// Ensure we have at least a default environment
-	if len(data.Environments) == 0 {
-		data = initEnv(data)
+	// Environments that inherited from the deleted one inherit from its parent instead
+	for i := range data.Environments {
+		if data.Environments[i].ParentID == envID {
+			data.Environments[i].ParentID = deletedParent
+		}
 	}
 
-	// Ensure current environment is set
-	if data.CurrentEnvironment == "" && len(data.Environments) > 0 {
+	// If we deleted the current environment, switch to the first available
+	if data.CurrentEnvironment == envID {
 		data.CurrentEnvironment = data.Environments[0].ID
 	}
 
-	// Ensure groups array is not nil
-	if data.Groups == nil {
-		data.Groups = []Group{}
+	// Save to file
+	if err := api.store.Save(data); err != nil {
+		slog.Error("failed to save environments", "error", err)
+		respondWithError(w, "Failed to save environments", http.StatusInternalServerError)
+		return
 	}
 
-	// Ensure default group exists
-	ensureDefaultGroup(data)
+	slog.Info("deleted environment", "id", envID)
+	recordAudit(r, "delete", "environment", envID, deletedName)
 
-	return data, nil
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{"status": "deleted"}); err != nil {
+		slog.Error("failed to encode environment response", "error", err)
+	}
 }
 
-// saveSavedRequests writes saved requests to JSON file
-func saveSavedRequests(data *SavedRequestsData) error {
-	fileAccessMutex.Lock()
-	defer fileAccessMutex.Unlock()
+// environmentFileFormatVersion is written to environment exports; bump it when the
+// file layout changes incompatibly
+const environmentFileFormatVersion = 1
 
-	// Marshal data to JSON
-	jsonData, err := json.MarshalIndent(data, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal requests data: %v", err)
-	}
+// EnvironmentFile is a single environment exported for sharing
+//
+// IDs and the parent link are left out since they only mean something in the data
+// file the environment came from.
+type EnvironmentFile struct {
+	FormatVersion    int               `json:"formatVersion"`
+	ExportedAt       string            `json:"exportedAt,omitempty"`
+	Name             string            `json:"name"`
+	Variables        []Variable        `json:"variables"`
+	Headers          map[string]string `json:"headers,omitempty"`
+	BaseURL          string            `json:"baseUrl,omitempty"`
+	DefaultTimeoutMs int               `json:"defaultTimeoutMs,omitempty"`
+	HostOverrides    map[string]string `json:"hostOverrides,omitempty"`
+}
 
-	// On Windows, try direct write first (simpler approach)
-	// If that fails, fall back to atomic write with retries
-	if err := tryDirectWrite(jsonData); err == nil {
-		log.Printf("💾 Saved %d requests to %s", len(data.Requests), requestsFileName)
-		return nil
+// environmentFileName turns an environment name into a download file name
+func environmentFileName(name string) string {
+	slug := strings.Trim(strings.Map(func(c rune) rune {
+		if c >= 'a' && c <= 'z' || c >= '0' && c <= '9' {
+			return c
+		}
+		return '-'
+	}, strings.ToLower(name)), "-")
+	if slug == "" {
+		slug = "environment"
 	}
+	return "go-rest-env-" + slug + ".json"
+}
 
-	// Fallback: atomic write with retry logic for Windows file locking issues
-	tempFileName := requestsFileName + ".tmp"
-	if err := os.WriteFile(tempFileName, jsonData, 0644); err != nil {
-		return fmt.Errorf("failed to write temporary file: %v", err)
+// exportEnvironment handles GET requests to download one environment as a file
+//
+// Values are exported as stored; ?maskSecrets=true leaves secret values empty.
+func (api *API) exportEnvironment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
-	// Retry rename operation with backoff for Windows file locking
-	maxRetries := 5
-	baseDelay := 50 * time.Millisecond
-
-	for attempt := 1; attempt <= maxRetries; attempt++ {
-		// Try to remove target file first (Windows sometimes requires this)
-		if _, err := os.Stat(requestsFileName); err == nil {
-			os.Remove(requestsFileName)
-			time.Sleep(10 * time.Millisecond) // Small delay after removal
-		}
-
-		// Attempt rename
-		if err := os.Rename(tempFileName, requestsFileName); err == nil {
-			log.Printf("💾 Saved %d requests to %s (attempt %d)", len(data.Requests), requestsFileName, attempt)
-			return nil
-		} else {
-			log.Printf("⚠️  Rename attempt %d failed: %v", attempt, err)
-			if attempt < maxRetries {
-				delay := time.Duration(attempt) * baseDelay
-				time.Sleep(delay)
-			}
-		}
+	env, err := api.store.GetEnvironment(chi.URLParam(r, "id"))
+	if errors.Is(err, errEnvironmentNotFound) {
+		respondWithError(w, "Environment not found", http.StatusNotFound)
+		return
 	}
-
-	// If all retries failed, clean up and return error
-	os.Remove(tempFileName)
-	return fmt.Errorf("failed to save after %d attempts - file may be locked by another process", maxRetries)
-}
-
-// tryDirectWrite attempts a direct write to the file (simpler, works most of the time)
-func tryDirectWrite(jsonData []byte) error {
-	// Try to write directly to the file
-	file, err := os.OpenFile(requestsFileName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
-		return err
+		slog.Error("failed to load saved data", "error", err)
+		respondWithError(w, "Failed to load saved data", http.StatusInternalServerError)
+		return
 	}
-	defer file.Close()
 
-	_, err = file.Write(jsonData)
-	if err != nil {
-		return err
+	file := EnvironmentFile{
+		FormatVersion:    environmentFileFormatVersion,
+		ExportedAt:       time.Now().Format(time.RFC3339),
+		Name:             env.Name,
+		Variables:        env.Variables,
+		Headers:          env.Headers,
+		BaseURL:          env.BaseURL,
+		DefaultTimeoutMs: env.DefaultTimeoutMs,
+		HostOverrides:    env.HostOverrides,
+	}
+	if r.URL.Query().Get("maskSecrets") == "true" {
+		file.Variables = stripSecrets(env.Variables)
 	}
 
-	return file.Sync() // Ensure data is written to disk
+	slog.Info("exporting environment", "name", env.Name, "variables", len(file.Variables))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, environmentFileName(env.Name)))
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(file); err != nil {
+		slog.Error("failed to encode environment export", "error", err)
+	}
 }
 
-// requests handles GET requests to retrieve all saved requests
-func requests(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+// importEnvironment handles POST requests to add an exported environment
+//
+// The environment is always added as a new one; a name already in use gets a
+// " (2)"-style suffix.
+func (api *API) importEnvironment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	data, err := loadRequests()
+	var file EnvironmentFile
+	if !decodeJSONRequest(w, r, &file) {
+		return
+	}
+	if file.FormatVersion > environmentFileFormatVersion {
+		respondWithError(w, fmt.Sprintf("Unsupported environment file version %d (this server reads up to %d)", file.FormatVersion, environmentFileFormatVersion), http.StatusBadRequest)
+		return
+	}
+	name, err := normalizeName("environment", file.Name)
 	if err != nil {
-		log.Printf("❌ Failed to load saved requests: %v", err)
-		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
+		respondWithError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(data); err != nil {
-		log.Printf("❌ Failed to encode saved requests: %v", err)
+	if err := validateTimeoutMs("defaultTimeoutMs", file.DefaultTimeoutMs); err != nil {
+		respondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if file.HostOverrides, err = normalizeHostOverrides(file.HostOverrides); err != nil {
+		respondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	seen := make(map[string]bool, len(file.Variables))
+	for _, variable := range file.Variables {
+		if variable.Key == "" {
+			respondWithError(w, "Variable key is required", http.StatusBadRequest)
+			return
+		}
+		if seen[variable.Key] {
+			respondWithError(w, fmt.Sprintf("Variable '%s' appears more than once", variable.Key), http.StatusBadRequest)
+			return
+		}
+		seen[variable.Key] = true
 	}
-}
-
-// =============================================================================
-// REQUEST MANAGEMENT HANDLERS
-// =============================================================================
 
-// Helper function to decode JSON request body with error handling
-func decodeJSONRequest(w http.ResponseWriter, r *http.Request, target interface{}) bool {
-	if err := json.NewDecoder(r.Body).Decode(target); err != nil {
-		log.Printf("❌ Invalid JSON request body: %v", err)
-		respondWithError(w, "Invalid request body", http.StatusBadRequest)
-		return false
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load saved data", "error", err)
+		respondWithError(w, "Failed to load saved data", http.StatusInternalServerError)
+		return
 	}
-	return true
-}
 
-// Helper function to validate required fields for saved requests
-func validateSavedRequest(name, url string) error {
-	if name == "" {
-		return fmt.Errorf("request name is required")
+	now := time.Now().Format(time.RFC3339)
+	env := Environment{
+		ID:               generateID(),
+		Name:             uniqueEnvironmentName(name, data.Environments),
+		Variables:        file.Variables,
+		Headers:          file.Headers,
+		BaseURL:          strings.TrimSpace(file.BaseURL),
+		DefaultTimeoutMs: file.DefaultTimeoutMs,
+		HostOverrides:    file.HostOverrides,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	if env.Variables == nil {
+		env.Variables = []Variable{}
+	}
+	data.Environments = append(data.Environments, env)
+
+	if err := api.store.Save(data); err != nil {
+		slog.Error("failed to save imported environment", "error", err)
+		respondWithError(w, "Failed to save environment", http.StatusInternalServerError)
+		return
 	}
-	if url == "" {
-		return fmt.Errorf("URL is required")
+
+	slog.Info("imported environment", "name", env.Name, "id", env.ID)
+	recordAudit(r, "import", "environment", env.ID, env.Name)
+
+	env.Variables = maskVariables(env.Variables)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(env); err != nil {
+		slog.Error("failed to encode imported environment", "error", err)
 	}
-	return nil
 }
 
-// saveRequest handles POST requests to save a new request
-func saveRequest(w http.ResponseWriter, r *http.Request) {
+// cloneEnvironment handles POST requests to create a new environment from an existing one
+//
+// Unlike copyEnvironment, which fills an existing target, this adds an environment with
+// the source's variables, headers, base URL, parent, timeout and host overrides. The
+// optional name defaults to "<source> (Copy)"; a name in use gets a " (2)"-style suffix.
+func (api *API) cloneEnvironment(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	var req struct {
-		Name         string            `json:"name"`
-		URL          string            `json:"url"`
-		Method       string            `json:"method"`
-		Headers      map[string]string `json:"headers"`
-		Body         any               `json:"body"`
-		BodyType     string            `json:"bodyType,omitempty"`
-		BodyText     string            `json:"bodyText,omitempty"`
-		BodyJson     []BodyField       `json:"bodyJson,omitempty"`
-		BodyForm     []BodyField       `json:"bodyForm,omitempty"`
-		Params       []QueryParam      `json:"params"`
-		Group        string            `json:"group"`
-		Description  string            `json:"description"`
-		LastResponse *ProxyResponse    `json:"lastResponse,omitempty"`
+		Name string `json:"name,omitempty"`
+	}
+	if r.ContentLength != 0 {
+		if !decodeJSONRequest(w, r, &req) {
+			return
+		}
 	}
 
-	if !decodeJSONRequest(w, r, &req) {
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load saved data", "error", err)
+		respondWithError(w, "Failed to load saved data", http.StatusInternalServerError)
 		return
 	}
 
-	// Validate required fields
-	if err := validateSavedRequest(req.Name, req.URL); err != nil {
-		respondWithError(w, err.Error(), http.StatusBadRequest)
+	source := findEnvironment(data, chi.URLParam(r, "id"))
+	if source == nil {
+		respondWithError(w, "Environment not found", http.StatusNotFound)
 		return
 	}
 
-	if req.Method == "" {
-		req.Method = "GET"
-	}
-	if req.Group == "" {
-		req.Group = "default"
+	name := source.Name + " (Copy)"
+	if strings.TrimSpace(req.Name) != "" {
+		name = req.Name
 	}
-
-	// Load existing requests
-	data, err := loadRequests()
-	if err != nil {
-		log.Printf("❌ Failed to load saved requests: %v", err)
-		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
+	if name, err = normalizeName("environment", name); err != nil {
+		respondWithError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Check for duplicate names (case-sensitive)
-	for _, existing := range data.Requests {
-		if existing.Name == req.Name {
-			respondWithError(w, fmt.Sprintf("Request name '%s' already exists. Please choose a different name.", req.Name), http.StatusConflict)
-			return
-		}
-	}
-
-	// Create new saved request
 	now := time.Now().Format(time.RFC3339)
-	savedReq := SavedRequest{
-		ID:           generateID(),
-		Name:         req.Name,
-		URL:          req.URL,
-		Method:       req.Method,
-		Headers:      req.Headers,
-		BodyType:     req.BodyType,
-		BodyText:     req.BodyText,
-		BodyJson:     req.BodyJson,
-		BodyForm:     req.BodyForm,
-		Params:       req.Params,
-		Group:        req.Group,
-		Description:  req.Description,
-		LastResponse: req.LastResponse,
-		CreatedAt:    now,
-		UpdatedAt:    now,
-	}
-
-	// Add to requests list
-	data.Requests = append(data.Requests, savedReq)
-
-	// Save to file
-	if err := saveSavedRequests(data); err != nil {
-		log.Printf("❌ Failed to save requests: %v", err)
-		respondWithError(w, "Failed to save request", http.StatusInternalServerError)
+	env := Environment{
+		ID:               generateID(),
+		Name:             uniqueEnvironmentName(name, data.Environments),
+		Variables:        append([]Variable{}, source.Variables...),
+		Headers:          maps.Clone(source.Headers),
+		BaseURL:          source.BaseURL,
+		ParentID:         source.ParentID,
+		DefaultTimeoutMs: source.DefaultTimeoutMs,
+		HostOverrides:    maps.Clone(source.HostOverrides),
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	data.Environments = append(data.Environments, env)
+
+	if err := api.store.Save(data); err != nil {
+		slog.Error("failed to save cloned environment", "error", err)
+		respondWithError(w, "Failed to save environment", http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("✅ Saved request: %s (%s %s)", savedReq.Name, savedReq.Method, savedReq.URL)
+	slog.Info("cloned environment", "from", source.Name, "name", env.Name, "id", env.ID)
+	recordAudit(r, "create", "environment", env.ID, env.Name)
 
+	env.Variables = maskVariables(env.Variables)
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(savedReq); err != nil {
-		log.Printf("❌ Failed to encode saved request response: %v", err)
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(env); err != nil {
+		slog.Error("failed to encode cloned environment", "error", err)
 	}
 }
 
-// updateRequest handles PUT requests to update an existing request
-func updateRequest(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPut {
+// copyEnvironment handles POST requests to copy variables between environments
+func (api *API) copyEnvironment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	type UpdatePayload struct {
-		ID           string             `json:"id"`
-		Name         *string            `json:"name,omitempty"`
-		URL          *string            `json:"url,omitempty"`
-		Method       *string            `json:"method,omitempty"`
-		Headers      *map[string]string `json:"headers,omitempty"`
-		BodyType     *string            `json:"bodyType,omitempty"`
-		BodyText     *string            `json:"bodyText,omitempty"`
-		BodyJson     *[]BodyField       `json:"bodyJson,omitempty"`
-		BodyForm     *[]BodyField       `json:"bodyForm,omitempty"`
-		Params       *[]QueryParam      `json:"params,omitempty"`
-		Group        *string            `json:"group,omitempty"`
-		Description  *string            `json:"description,omitempty"`
-		LastResponse *ProxyResponse     `json:"lastResponse,omitempty"`
+		return
 	}
 
-	var req UpdatePayload
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("❌ Invalid request body for update: %v", err)
-		respondWithError(w, "Invalid request body", http.StatusBadRequest)
+	targetEnvID := chi.URLParam(r, "id")
+	if targetEnvID == "" {
+		respondWithError(w, "Target environment ID is required", http.StatusBadRequest)
 		return
 	}
 
-	// Validate required identifier
-	if req.ID == "" {
-		respondWithError(w, "Request ID is required", http.StatusBadRequest)
-		return
+	var req struct {
+		SourceEnvironmentID string `json:"sourceEnvironmentId"`
+		Mode                string `json:"mode,omitempty"` // replace (default), merge or overwrite
 	}
-	// Validate if present
-	if req.Name != nil && *req.Name == "" {
-		respondWithError(w, "Request name cannot be empty", http.StatusBadRequest)
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("invalid request body for copy environment", "error", err)
+		respondWithError(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-	if req.URL != nil && *req.URL == "" {
-		respondWithError(w, "URL cannot be empty", http.StatusBadRequest)
+
+	if req.SourceEnvironmentID == "" {
+		respondWithError(w, "Source environment ID is required", http.StatusBadRequest)
 		return
 	}
-	if req.Group != nil && *req.Group == "" {
-		respondWithError(w, "Group cannot be empty", http.StatusBadRequest)
+	if req.Mode == "" {
+		req.Mode = "replace"
+	}
+	if req.Mode != "replace" && req.Mode != "merge" && req.Mode != "overwrite" {
+		respondWithError(w, fmt.Sprintf("Invalid copy mode '%s': use replace, merge or overwrite", req.Mode), http.StatusBadRequest)
 		return
 	}
 
-	// Load existing requests
-	data, err := loadRequests()
+	// Load existing data
+	data, err := api.store.Load()
 	if err != nil {
-		log.Printf("❌ Failed to load saved requests: %v", err)
-		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
+		slog.Error("failed to load saved data", "error", err)
+		respondWithError(w, "Failed to load saved data", http.StatusInternalServerError)
 		return
 	}
 
-	// Check for duplicate names (case-sensitive, excluding the current request)
-	if req.Name != nil {
-		for _, existing := range data.Requests {
-			if existing.ID != req.ID && existing.Name == *req.Name {
-				respondWithError(w, fmt.Sprintf("Request name '%s' already exists. Please choose a different name.", *req.Name), http.StatusConflict)
-				return
-			}
+	// Find source environment
+	var sourceEnv *Environment
+	for i := range data.Environments {
+		if data.Environments[i].ID == req.SourceEnvironmentID {
+			sourceEnv = &data.Environments[i]
+			break
 		}
 	}
 
-	// Find and update the request
+	if sourceEnv == nil {
+		respondWithError(w, "Source environment not found", http.StatusNotFound)
+		return
+	}
+
+	// Find and update target environment
 	found := false
-	for i, existing := range data.Requests {
-		if existing.ID == req.ID {
-			if req.Name != nil {
-				data.Requests[i].Name = *req.Name
-			}
-			if req.URL != nil {
-				data.Requests[i].URL = *req.URL
-			}
-			if req.Method != nil {
-				data.Requests[i].Method = *req.Method
-			}
-			if req.Headers != nil {
-				data.Requests[i].Headers = *req.Headers
-			}
-			if req.BodyType != nil {
-				data.Requests[i].BodyType = *req.BodyType
-			}
-			if req.BodyText != nil {
-				data.Requests[i].BodyText = *req.BodyText
-			}
-			if req.BodyJson != nil {
-				data.Requests[i].BodyJson = *req.BodyJson
-			}
-			if req.BodyForm != nil {
-				data.Requests[i].BodyForm = *req.BodyForm
-			}
-			if req.Params != nil {
-				data.Requests[i].Params = *req.Params
-			}
-			if req.Group != nil {
-				data.Requests[i].Group = *req.Group
-			}
-			if req.Description != nil {
-				data.Requests[i].Description = *req.Description
-			}
-			if req.LastResponse != nil {
-				data.Requests[i].LastResponse = req.LastResponse
-			}
-			data.Requests[i].UpdatedAt = time.Now().Format(time.RFC3339)
+	targetName := ""
+	var summary CopySummary
+	for i := range data.Environments {
+		if data.Environments[i].ID == targetEnvID {
+			// Copy variables from source to target
+			data.Environments[i].Variables, summary = copyVariables(data.Environments[i].Variables, sourceEnv.Variables, req.Mode)
+			data.Environments[i].UpdatedAt = time.Now().Format(time.RFC3339)
+			data.Environments[i].Version++
+			targetName = data.Environments[i].Name
 			found = true
 			break
 		}
 	}
 
 	if !found {
-		respondWithError(w, "Request not found", http.StatusNotFound)
+		respondWithError(w, "Target environment not found", http.StatusNotFound)
 		return
 	}
 
 	// Save to file
-	if err := saveSavedRequests(data); err != nil {
-		log.Printf("❌ Failed to save updated request: %v", err)
-		respondWithError(w, "Failed to save updated request", http.StatusInternalServerError)
+	if err := api.store.Save(data); err != nil {
+		slog.Error("failed to save environment", "error", err)
+		respondWithError(w, "Failed to save environment", http.StatusInternalServerError)
 		return
 	}
 
+	slog.Info("copied variables", "from", req.SourceEnvironmentID, "to", targetEnvID, "mode", req.Mode,
+		"added", summary.Added, "updated", summary.Updated, "untouched", summary.Untouched, "removed", summary.Removed)
+	recordAudit(r, "update", "environment", targetEnvID, targetName)
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "updated"})
+	if err := json.NewEncoder(w).Encode(map[string]any{
+		"status":    "copied",
+		"mode":      req.Mode,
+		"added":     summary.Added,
+		"updated":   summary.Updated,
+		"untouched": summary.Untouched,
+		"removed":   summary.Removed,
+	}); err != nil {
+		slog.Error("failed to encode copy response", "error", err)
+	}
 }
 
-// deleteRequest handles DELETE requests to delete a request
-func deleteRequest(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodDelete {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// CopySummary counts what a variable copy did to the target environment
+type CopySummary struct {
+	Added     int
+	Updated   int
+	Untouched int
+	Removed   int
+}
+
+// copyVariables combines source variables into target according to mode
+//
+// "replace" makes the target an exact copy of the source, "merge" only adds keys the
+// target lacks, and "overwrite" adds and updates keys while keeping target-only keys.
+func copyVariables(target, source []Variable, mode string) ([]Variable, CopySummary) {
+	var summary CopySummary
+	sourceKeys := make(map[string]bool, len(source))
+	for _, v := range source {
+		sourceKeys[v.Key] = true
+	}
+
+	result := make([]Variable, 0, len(target)+len(source))
+	existing := make(map[string]int, len(target))
+	for _, v := range target {
+		if mode == "replace" && !sourceKeys[v.Key] {
+			summary.Removed++
+			continue
+		}
+		existing[v.Key] = len(result)
+		result = append(result, v)
+	}
+
+	for _, v := range source {
+		i, ok := existing[v.Key]
+		switch {
+		case !ok:
+			existing[v.Key] = len(result)
+			result = append(result, v)
+			summary.Added++
+		case mode == "merge" || result[i] == v:
+			// Left as-is; counted below
+		default:
+			result[i] = v
+			summary.Updated++
+		}
 	}
 
-	var req struct {
-		ID string `json:"id"`
+	summary.Untouched = len(result) - summary.Added - summary.Updated
+	if mode == "replace" {
+		// Keep the source's ordering for an exact copy
+		result = append([]Variable(nil), source...)
 	}
+	return result, summary
+}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("❌ Invalid request body for delete: %v", err)
-		respondWithError(w, "Invalid request body", http.StatusBadRequest)
-		return
+// findEnvironment returns a pointer to the environment with the given ID, or nil
+func findEnvironment(data *SavedRequestsData, envID string) *Environment {
+	for i := range data.Environments {
+		if data.Environments[i].ID == envID {
+			return &data.Environments[i]
+		}
 	}
+	return nil
+}
 
-	if req.ID == "" {
-		respondWithError(w, "Request ID is required", http.StatusBadRequest)
-		return
+// variableKeyParam reads and unescapes the {key} URL parameter
+func variableKeyParam(r *http.Request) string {
+	key := chi.URLParam(r, "key")
+	if unescaped, err := url.PathUnescape(key); err == nil {
+		return unescaped
 	}
+	return key
+}
 
-	// Load existing requests
-	data, err := loadRequests()
-	if err != nil {
-		log.Printf("❌ Failed to load saved requests: %v", err)
-		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
+// environmentVariables handles GET requests to list the variables of one environment
+func (api *API) environmentVariables(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Find and remove the request
-	found := false
-	originalCount := len(data.Requests)
-	log.Printf("🗑️  Searching for request ID: %s among %d requests", req.ID, originalCount)
-
-	for i, existing := range data.Requests {
-		if existing.ID == req.ID {
-			log.Printf("🗑️  Found and deleting request: %s (ID: %s)", existing.Name, existing.ID)
-			data.Requests = append(data.Requests[:i], data.Requests[i+1:]...)
-			found = true
-			break
-		}
+	env, err := api.store.GetEnvironment(chi.URLParam(r, "id"))
+	if errors.Is(err, errEnvironmentNotFound) {
+		respondWithError(w, "Environment not found", http.StatusNotFound)
+		return
 	}
-
-	if !found {
-		log.Printf("❌ Request with ID %s not found", req.ID)
-		respondWithError(w, "Request not found", http.StatusNotFound)
+	if err != nil {
+		slog.Error("failed to load saved data", "error", err)
+		respondWithError(w, "Failed to load saved data", http.StatusInternalServerError)
 		return
 	}
 
-	newCount := len(data.Requests)
-	log.Printf("✅ Request deleted. Count: %d -> %d", originalCount, newCount)
-
-	// Save to file
-	if err := saveSavedRequests(data); err != nil {
-		log.Printf("❌ Failed to save after deletion: %v", err)
-		respondWithError(w, "Failed to save after deletion", http.StatusInternalServerError)
-		return
+	envVars := env.Variables
+	if !revealSecrets(r) {
+		envVars = maskVariables(envVars)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "deleted"})
+	if err := json.NewEncoder(w).Encode(map[string][]Variable{"variables": envVars}); err != nil {
+		slog.Error("failed to encode environment variables", "error", err)
+	}
 }
 
-// duplicateRequest handles POST requests to duplicate a request
-func duplicateRequest(w http.ResponseWriter, r *http.Request) {
+// addEnvironmentVariable handles POST requests to add a single variable to an environment
+func (api *API) addEnvironmentVariable(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var req struct {
-		ID string `json:"id"`
-	}
+	envID := chi.URLParam(r, "id")
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("❌ Invalid request body for duplicate: %v", err)
-		respondWithError(w, "Invalid request body", http.StatusBadRequest)
+	var req Variable
+	if !decodeJSONRequest(w, r, &req) {
 		return
 	}
 
-	if req.ID == "" {
-		respondWithError(w, "Request ID is required", http.StatusBadRequest)
+	if req.Key == "" {
+		respondWithError(w, "Variable key is required", http.StatusBadRequest)
 		return
 	}
 
-	// Load existing requests
-	data, err := loadRequests()
+	data, err := api.store.Load()
 	if err != nil {
-		log.Printf("❌ Failed to load saved requests: %v", err)
-		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
+		slog.Error("failed to load saved data", "error", err)
+		respondWithError(w, "Failed to load saved data", http.StatusInternalServerError)
 		return
 	}
 
-	// Find the request to duplicate
-	var originalRequest *SavedRequest
-	for _, existing := range data.Requests {
-		if existing.ID == req.ID {
-			originalRequest = &existing
-			break
-		}
-	}
-
-	if originalRequest == nil {
-		respondWithError(w, "Request not found", http.StatusNotFound)
+	env := findEnvironment(data, envID)
+	if env == nil {
+		respondWithError(w, "Environment not found", http.StatusNotFound)
 		return
 	}
 
-	// Create duplicate with unique name
-	now := time.Now().Format(time.RFC3339)
-	uniqueName := uniqueName(originalRequest.Name+" (Copy)", data.Requests)
-	duplicatedReq := SavedRequest{
-		ID:           generateID(),
-		Name:         uniqueName,
-		URL:          originalRequest.URL,
-		Method:       originalRequest.Method,
-		Headers:      make(map[string]string),
-		BodyType:     originalRequest.BodyType,
-		BodyText:     originalRequest.BodyText,
-		BodyJson:     make([]BodyField, len(originalRequest.BodyJson)),
-		BodyForm:     make([]BodyField, len(originalRequest.BodyForm)),
-		Params:       make([]QueryParam, len(originalRequest.Params)),
-		Group:        originalRequest.Group,
-		Description:  originalRequest.Description,
-		LastResponse: nil, // Don't copy response
-		CreatedAt:    now,
-		UpdatedAt:    now,
-	}
-
-	// Deep copy headers
-	for k, v := range originalRequest.Headers {
-		duplicatedReq.Headers[k] = v
+	for _, variable := range env.Variables {
+		if variable.Key == req.Key {
+			respondWithError(w, fmt.Sprintf("Variable '%s' already exists in this environment", req.Key), http.StatusConflict)
+			return
+		}
 	}
 
-	// Deep copy params
-	copy(duplicatedReq.Params, originalRequest.Params)
-
-	// Deep copy body fields
-	copy(duplicatedReq.BodyJson, originalRequest.BodyJson)
-	copy(duplicatedReq.BodyForm, originalRequest.BodyForm)
-
-	// Add to requests list
-	data.Requests = append(data.Requests, duplicatedReq)
+	env.Variables = append(env.Variables, req)
+	env.UpdatedAt = time.Now().Format(time.RFC3339)
+	env.Version++
 
-	// Save to file
-	if err := saveSavedRequests(data); err != nil {
-		log.Printf("❌ Failed to save duplicated request: %v", err)
-		respondWithError(w, "Failed to save duplicated request", http.StatusInternalServerError)
+	if err := api.store.Save(data); err != nil {
+		slog.Error("failed to save variable", "error", err)
+		respondWithError(w, "Failed to save variable", http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("📋 Duplicated request: %s -> %s", originalRequest.Name, duplicatedReq.Name)
+	slog.Info("added variable", "key", req.Key, "environment", envID)
+	recordAudit(r, "update", "environment", env.ID, env.Name)
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(duplicatedReq); err != nil {
-		log.Printf("❌ Failed to encode duplicated request response: %v", err)
+	if err := json.NewEncoder(w).Encode(maskVariables([]Variable{req})[0]); err != nil {
+		slog.Error("failed to encode variable response", "error", err)
 	}
 }
 
-// VariableWithResolved represents a variable with its raw and resolved values
-type VariableWithResolved struct {
-	Key           string `json:"key"`
-	Value         string `json:"value"`         // Raw value (e.g., "$HOME")
-	ResolvedValue string `json:"resolvedValue"` // Resolved value (e.g., "/Users/jeremiah.zink")
-	IsEnvVar      bool   `json:"isEnvVar"`      // Whether this is an environment variable reference
-}
-
-// variables handles GET requests to retrieve variables from current environment
-func variables(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+// updateEnvironmentVariable handles PUT requests to update (or rename) a single variable
+func (api *API) updateEnvironmentVariable(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	data, err := loadRequests()
-	if err != nil {
-		log.Printf("❌ Failed to load variables: %v", err)
-		respondWithError(w, "Failed to load variables", http.StatusInternalServerError)
+	envID := chi.URLParam(r, "id")
+	key := variableKeyParam(r)
+
+	var req struct {
+		Key    *string `json:"key,omitempty"`
+		Value  *string `json:"value,omitempty"`
+		Secret *bool   `json:"secret,omitempty"`
+	}
+	if !decodeJSONRequest(w, r, &req) {
 		return
 	}
 
-	// Get current environment
-	currentEnv, err := getCurrentEnvironment(data)
+	if req.Key != nil && *req.Key == "" {
+		respondWithError(w, "Variable key cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	data, err := api.store.Load()
 	if err != nil {
-		log.Printf("❌ Failed to get current environment: %v", err)
-		respondWithError(w, "Failed to get current environment", http.StatusInternalServerError)
+		slog.Error("failed to load saved data", "error", err)
+		respondWithError(w, "Failed to load saved data", http.StatusInternalServerError)
 		return
 	}
 
-	// Return raw values with resolved values for display
-	variablesWithResolved := make([]VariableWithResolved, len(currentEnv.Variables))
-	for i, variable := range currentEnv.Variables {
-		isEnvVar := strings.HasPrefix(variable.Value, "$")
-		resolvedValue := variable.Value
-		if isEnvVar {
-			resolvedValue = resolveEnvVar(variable.Value)
+	env := findEnvironment(data, envID)
+	if env == nil {
+		respondWithError(w, "Environment not found", http.StatusNotFound)
+		return
+	}
+
+	index := -1
+	for i, variable := range env.Variables {
+		if variable.Key == key {
+			index = i
+			break
 		}
+	}
+	if index == -1 {
+		respondWithError(w, "Variable not found", http.StatusNotFound)
+		return
+	}
 
-		variablesWithResolved[i] = VariableWithResolved{
-			Key:           variable.Key,
-			Value:         variable.Value, // Keep raw value like "$HOME"
-			ResolvedValue: resolvedValue,  // Show resolved value like "/Users/jeremiah.zink"
-			IsEnvVar:      isEnvVar,
+	// Renaming must not collide with another variable's key
+	if req.Key != nil && *req.Key != key {
+		for _, variable := range env.Variables {
+			if variable.Key == *req.Key {
+				respondWithError(w, fmt.Sprintf("Variable '%s' already exists in this environment", *req.Key), http.StatusConflict)
+				return
+			}
 		}
+		env.Variables[index].Key = *req.Key
+	}
+	if req.Value != nil && !(env.Variables[index].Secret && *req.Value == maskSecret(env.Variables[index].Value)) {
+		env.Variables[index].Value = *req.Value
+	}
+	if req.Secret != nil {
+		env.Variables[index].Secret = *req.Secret
 	}
+	env.UpdatedAt = time.Now().Format(time.RFC3339)
+	env.Version++
+
+	if err := api.store.Save(data); err != nil {
+		slog.Error("failed to save variable", "error", err)
+		respondWithError(w, "Failed to save variable", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("updated variable", "key", key, "environment", envID)
+	recordAudit(r, "update", "environment", env.ID, env.Name)
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string][]VariableWithResolved{"variables": variablesWithResolved}); err != nil {
-		log.Printf("❌ Failed to encode variables: %v", err)
+	if err := json.NewEncoder(w).Encode(maskVariables([]Variable{env.Variables[index]})[0]); err != nil {
+		slog.Error("failed to encode variable response", "error", err)
 	}
 }
 
-// saveVariables handles POST requests to save variables to current environment
-func saveVariables(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
+// deleteEnvironmentVariable handles DELETE requests to remove a single variable
+func (api *API) deleteEnvironmentVariable(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var req struct {
-		Variables []Variable `json:"variables"`
-	}
+	envID := chi.URLParam(r, "id")
+	key := variableKeyParam(r)
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("❌ Invalid request body for save variables: %v", err)
-		respondWithError(w, "Invalid request body", http.StatusBadRequest)
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load saved data", "error", err)
+		respondWithError(w, "Failed to load saved data", http.StatusInternalServerError)
 		return
 	}
 
-	// Load existing data
-	data, err := loadRequests()
-	if err != nil {
-		log.Printf("❌ Failed to load saved data: %v", err)
-		respondWithError(w, "Failed to load saved data", http.StatusInternalServerError)
+	env := findEnvironment(data, envID)
+	if env == nil {
+		respondWithError(w, "Environment not found", http.StatusNotFound)
 		return
 	}
 
-	// Find and update current environment
 	found := false
-	for i := range data.Environments {
-		if data.Environments[i].ID == data.CurrentEnvironment {
-			data.Environments[i].Variables = req.Variables
-			data.Environments[i].UpdatedAt = time.Now().Format(time.RFC3339)
+	for i, variable := range env.Variables {
+		if variable.Key == key {
+			env.Variables = append(env.Variables[:i], env.Variables[i+1:]...)
 			found = true
 			break
 		}
 	}
-
 	if !found {
-		log.Printf("❌ Current environment not found: %s", data.CurrentEnvironment)
-		respondWithError(w, "Current environment not found", http.StatusInternalServerError)
+		respondWithError(w, "Variable not found", http.StatusNotFound)
 		return
 	}
+	env.UpdatedAt = time.Now().Format(time.RFC3339)
+	env.Version++
 
-	// Save to file
-	if err := saveSavedRequests(data); err != nil {
-		log.Printf("❌ Failed to save variables: %v", err)
-		respondWithError(w, "Failed to save variables", http.StatusInternalServerError)
+	if err := api.store.Save(data); err != nil {
+		slog.Error("failed to save after variable deletion", "error", err)
+		respondWithError(w, "Failed to delete variable", http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("✅ Saved %d variables to environment %s", len(req.Variables), data.CurrentEnvironment)
+	slog.Info("deleted variable", "key", key, "environment", envID)
+	recordAudit(r, "update", "environment", env.ID, env.Name)
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string]string{"status": "saved"}); err != nil {
-		log.Printf("❌ Failed to encode variables response: %v", err)
-	}
+	json.NewEncoder(w).Encode(map[string]string{"status": "deleted"})
 }
 
-// environments handles GET requests to list all environments
-func environments(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
+// EnvironmentDiff describes how the variables of two environments differ
+type EnvironmentDiff struct {
+	A         EnvironmentSummary `json:"a"`
+	B         EnvironmentSummary `json:"b"`
+	OnlyInA   []Variable         `json:"onlyInA"`
+	OnlyInB   []Variable         `json:"onlyInB"`
+	Changed   []VariableChange   `json:"changed"`
+	Unchanged []string           `json:"unchanged"` // Keys with the same value in both
+}
 
-	data, err := loadRequests()
-	if err != nil {
-		log.Printf("❌ Failed to load environments: %v", err)
-		respondWithError(w, "Failed to load environments", http.StatusInternalServerError)
-		return
-	}
+// EnvironmentSummary identifies an environment in a diff
+type EnvironmentSummary struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	response := map[string]any{
-		"environments":       data.Environments,
-		"currentEnvironment": data.CurrentEnvironment,
+// VariableChange is a key present in both environments with different values
+type VariableChange struct {
+	Key    string `json:"key"`
+	A      string `json:"a"`
+	B      string `json:"b"`
+	Secret bool   `json:"secret,omitempty"`
+}
+
+// diffEnvironments compares the variables of two environments, listing keys in A's order
+// followed by keys only in B
+func diffEnvironments(a, b *Environment, reveal bool) EnvironmentDiff {
+	diff := EnvironmentDiff{
+		A:         EnvironmentSummary{ID: a.ID, Name: a.Name},
+		B:         EnvironmentSummary{ID: b.ID, Name: b.Name},
+		OnlyInA:   []Variable{},
+		OnlyInB:   []Variable{},
+		Changed:   []VariableChange{},
+		Unchanged: []string{},
+	}
+
+	mask := func(variable Variable) Variable {
+		if reveal {
+			return variable
+		}
+		return maskVariables([]Variable{variable})[0]
 	}
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("❌ Failed to encode environments: %v", err)
+
+	inB := make(map[string]Variable, len(b.Variables))
+	for _, variable := range b.Variables {
+		inB[variable.Key] = variable
 	}
-}
+	inA := make(map[string]bool, len(a.Variables))
 
-// createEnvironment handles POST requests to create a new environment
-func createEnvironment(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+	for _, variable := range a.Variables {
+		inA[variable.Key] = true
+		other, found := inB[variable.Key]
+		switch {
+		case !found:
+			diff.OnlyInA = append(diff.OnlyInA, mask(variable))
+		case other.Value == variable.Value:
+			diff.Unchanged = append(diff.Unchanged, variable.Key)
+		default:
+			secret := variable.Secret || other.Secret
+			change := VariableChange{Key: variable.Key, A: variable.Value, B: other.Value, Secret: secret}
+			if secret && !reveal {
+				change.A = maskSecret(change.A)
+				change.B = maskSecret(change.B)
+			}
+			diff.Changed = append(diff.Changed, change)
+		}
 	}
 
-	var req struct {
-		Name string `json:"name"`
+	for _, variable := range b.Variables {
+		if !inA[variable.Key] {
+			diff.OnlyInB = append(diff.OnlyInB, mask(variable))
+		}
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("❌ Invalid request body for create environment: %v", err)
-		respondWithError(w, "Invalid request body", http.StatusBadRequest)
+	return diff
+}
+
+// environmentDiff handles GET requests to compare the variables of two environments
+func (api *API) environmentDiff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	if req.Name == "" {
-		respondWithError(w, "Environment name is required", http.StatusBadRequest)
+	idA := r.URL.Query().Get("a")
+	idB := r.URL.Query().Get("b")
+	if idA == "" || idB == "" {
+		respondWithError(w, "Both environment IDs (a and b) are required", http.StatusBadRequest)
 		return
 	}
 
-	// Load existing data
-	data, err := loadRequests()
+	data, err := api.store.Load()
 	if err != nil {
-		log.Printf("❌ Failed to load saved data: %v", err)
+		slog.Error("failed to load saved data", "error", err)
 		respondWithError(w, "Failed to load saved data", http.StatusInternalServerError)
 		return
 	}
 
-	// Check if environment name already exists
-	for _, env := range data.Environments {
-		if env.Name == req.Name {
-			respondWithError(w, "Environment name already exists", http.StatusConflict)
-			return
-		}
-	}
-
-	// Create new environment
-	now := time.Now().Format(time.RFC3339)
-	newEnv := Environment{
-		ID:        generateID(),
-		Name:      req.Name,
-		Variables: []Variable{},
-		CreatedAt: now,
-		UpdatedAt: now,
+	envA := findEnvironment(data, idA)
+	if envA == nil {
+		respondWithError(w, fmt.Sprintf("Environment not found: %s", idA), http.StatusNotFound)
+		return
 	}
-
-	data.Environments = append(data.Environments, newEnv)
-
-	// Save to file
-	if err := saveSavedRequests(data); err != nil {
-		log.Printf("❌ Failed to save environment: %v", err)
-		respondWithError(w, "Failed to save environment", http.StatusInternalServerError)
+	envB := findEnvironment(data, idB)
+	if envB == nil {
+		respondWithError(w, fmt.Sprintf("Environment not found: %s", idB), http.StatusNotFound)
 		return
 	}
 
-	log.Printf("✅ Created environment: %s (%s)", newEnv.Name, newEnv.ID)
+	diff := diffEnvironments(envA, envB, revealSecrets(r))
+
+	slog.Info("diffed environments", "a", envA.Name, "b", envB.Name,
+		"onlyInA", len(diff.OnlyInA), "onlyInB", len(diff.OnlyInB), "changed", len(diff.Changed))
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(newEnv); err != nil {
-		log.Printf("❌ Failed to encode environment response: %v", err)
+	if err := json.NewEncoder(w).Encode(diff); err != nil {
+		slog.Error("failed to encode environment diff", "error", err)
 	}
 }
 
-// updateEnvironment handles PUT requests to update an environment
-func updateEnvironment(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPut {
+// activateEnvironment handles POST requests to activate an environment
+func (api *API) activateEnvironment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
@@ -1821,43 +13000,18 @@ func updateEnvironment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req struct {
-		Name      string     `json:"name"`
-		Variables []Variable `json:"variables"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("❌ Invalid request body for update environment: %v", err)
-		respondWithError(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
 	// Load existing data
-	data, err := loadRequests()
+	data, err := api.store.Load()
 	if err != nil {
-		log.Printf("❌ Failed to load saved data: %v", err)
+		slog.Error("failed to load saved data", "error", err)
 		respondWithError(w, "Failed to load saved data", http.StatusInternalServerError)
 		return
 	}
 
-	// Find and update environment
+	// Check if environment exists
 	found := false
-	for i := range data.Environments {
-		if data.Environments[i].ID == envID {
-			if req.Name != "" {
-				// Check if new name conflicts with existing environments
-				for j, env := range data.Environments {
-					if j != i && env.Name == req.Name {
-						respondWithError(w, "Environment name already exists", http.StatusConflict)
-						return
-					}
-				}
-				data.Environments[i].Name = req.Name
-			}
-			if req.Variables != nil {
-				data.Environments[i].Variables = req.Variables
-			}
-			data.Environments[i].UpdatedAt = time.Now().Format(time.RFC3339)
+	for _, env := range data.Environments {
+		if env.ID == envID {
 			found = true
 			break
 		}
@@ -1868,311 +13022,408 @@ func updateEnvironment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A session activation only sets the cookie, leaving the global default alone
+	if r.URL.Query().Get("session") == "true" {
+		http.SetCookie(w, &http.Cookie{
+			Name:     sessionEnvironmentCookie,
+			Value:    envID,
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+		slog.Info("activated environment for this session", "environment", envID)
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]string{"status": "activated", "scope": "session"}); err != nil {
+			slog.Error("failed to encode activation response", "error", err)
+		}
+		return
+	}
+
+	// Set as current environment
+	data.CurrentEnvironment = envID
+
 	// Save to file
-	if err := saveSavedRequests(data); err != nil {
-		log.Printf("❌ Failed to save environment: %v", err)
-		respondWithError(w, "Failed to save environment", http.StatusInternalServerError)
+	if err := api.store.Save(data); err != nil {
+		slog.Error("failed to save current environment", "error", err)
+		respondWithError(w, "Failed to save current environment", http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("✅ Updated environment: %s", envID)
+	slog.Info("activated environment", "environment", envID)
+	recordAudit(r, "activate", "environment", envID, findEnvironment(data, envID).Name)
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string]string{"status": "updated"}); err != nil {
-		log.Printf("❌ Failed to encode environment response: %v", err)
+	if err := json.NewEncoder(w).Encode(map[string]string{"status": "activated"}); err != nil {
+		slog.Error("failed to encode activation response", "error", err)
 	}
 }
 
-// deleteEnvironment handles DELETE requests to delete an environment
-func deleteEnvironment(w http.ResponseWriter, r *http.Request) {
+// clearSessionEnvironment handles DELETE requests to drop the session's environment
+// selection, returning the session to the global current environment
+func (api *API) clearSessionEnvironment(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	envID := chi.URLParam(r, "id")
-	if envID == "" {
-		respondWithError(w, "Environment ID is required", http.StatusBadRequest)
-		return
-	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionEnvironmentCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	slog.Info("cleared session environment")
 
-	// Load existing data
-	data, err := loadRequests()
-	if err != nil {
-		log.Printf("❌ Failed to load saved data: %v", err)
-		respondWithError(w, "Failed to load saved data", http.StatusInternalServerError)
-		return
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{"status": "cleared"}); err != nil {
+		slog.Error("failed to encode clear response", "error", err)
 	}
+}
 
-	// Don't allow deleting the last environment
-	if len(data.Environments) <= 1 {
-		respondWithError(w, "Cannot delete the last environment", http.StatusBadRequest)
+// groups handles GET requests to get all groups
+func (api *API) groups(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Find and remove environment
-	found := false
-	newEnvironments := []Environment{}
-	for _, env := range data.Environments {
-		if env.ID != envID {
-			newEnvironments = append(newEnvironments, env)
-		} else {
-			found = true
-		}
-	}
-
-	if !found {
-		respondWithError(w, "Environment not found", http.StatusNotFound)
+	// Loading ensures the default group exists
+	groups, err := api.store.ListGroups()
+	if err != nil {
+		slog.Error("failed to load saved requests", "error", err)
+		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
 		return
 	}
 
-	data.Environments = newEnvironments
-
-	// If we deleted the current environment, switch to the first available
-	if data.CurrentEnvironment == envID {
-		data.CurrentEnvironment = data.Environments[0].ID
-	}
-
-	// Save to file
-	if err := saveSavedRequests(data); err != nil {
-		log.Printf("❌ Failed to save environments: %v", err)
-		respondWithError(w, "Failed to save environments", http.StatusInternalServerError)
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Query().Get("tree") == "true" {
+		if err := json.NewEncoder(w).Encode(map[string][]GroupNode{"groups": buildGroupTree(groups)}); err != nil {
+			slog.Error("failed to encode groups", "error", err)
+		}
 		return
 	}
-
-	log.Printf("✅ Deleted environment: %s", envID)
-
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string]string{"status": "deleted"}); err != nil {
-		log.Printf("❌ Failed to encode environment response: %v", err)
+	if err := json.NewEncoder(w).Encode(map[string][]Group{"groups": groups}); err != nil {
+		slog.Error("failed to encode groups", "error", err)
 	}
 }
 
-// copyEnvironment handles POST requests to copy variables between environments
-func copyEnvironment(w http.ResponseWriter, r *http.Request) {
+// createGroup handles POST requests to create a new group
+func (api *API) createGroup(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	targetEnvID := chi.URLParam(r, "id")
-	if targetEnvID == "" {
-		respondWithError(w, "Target environment ID is required", http.StatusBadRequest)
-		return
-	}
-
 	var req struct {
-		SourceEnvironmentID string `json:"sourceEnvironmentId"`
+		Name        string      `json:"name"`
+		Description string      `json:"description,omitempty"`
+		Color       string      `json:"color,omitempty"`
+		ParentID    string      `json:"parentId,omitempty"`
+		Auth        *AuthConfig `json:"auth,omitempty"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("❌ Invalid request body for copy environment: %v", err)
+		slog.Error("invalid request body for create group", "error", err)
 		respondWithError(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	if req.SourceEnvironmentID == "" {
-		respondWithError(w, "Source environment ID is required", http.StatusBadRequest)
+	name, err := normalizeName("group", req.Name)
+	if err != nil {
+		respondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Name = name
+	if req.Color, err = normalizeGroupColor(req.Color); err != nil {
+		respondWithError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	// Load existing data
-	data, err := loadRequests()
+	data, err := api.store.Load()
 	if err != nil {
-		log.Printf("❌ Failed to load saved data: %v", err)
-		respondWithError(w, "Failed to load saved data", http.StatusInternalServerError)
+		slog.Error("failed to load saved requests", "error", err)
+		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
 		return
 	}
 
-	// Find source environment
-	var sourceEnv *Environment
-	for _, env := range data.Environments {
-		if env.ID == req.SourceEnvironmentID {
-			sourceEnv = &env
-			break
+	// Check if group already exists
+	for _, group := range data.Groups {
+		if group.Name == req.Name {
+			respondWithError(w, "Group already exists", http.StatusConflict)
+			return
 		}
 	}
-
-	if sourceEnv == nil {
-		respondWithError(w, "Source environment not found", http.StatusNotFound)
+	if req.ParentID != "" && findGroup(data, req.ParentID) == nil {
+		respondWithError(w, fmt.Sprintf("Parent group not found: %s", req.ParentID), http.StatusNotFound)
 		return
 	}
 
-	// Find and update target environment
-	found := false
-	for i := range data.Environments {
-		if data.Environments[i].ID == targetEnvID {
-			// Copy variables from source to target
-			data.Environments[i].Variables = make([]Variable, len(sourceEnv.Variables))
-			copy(data.Environments[i].Variables, sourceEnv.Variables)
-			data.Environments[i].UpdatedAt = time.Now().Format(time.RFC3339)
-			found = true
-			break
-		}
+	// Create new group
+	now := time.Now().Format(time.RFC3339)
+	newGroup := Group{
+		ID:          generateID(),
+		Name:        req.Name,
+		Description: strings.TrimSpace(req.Description),
+		Color:       req.Color,
+		ParentID:    req.ParentID,
+		Order:       nextGroupOrder(data.Groups),
+		Auth:        req.Auth,
+		CreatedAt:   now,
+		UpdatedAt:   now,
 	}
 
-	if !found {
-		respondWithError(w, "Target environment not found", http.StatusNotFound)
-		return
-	}
+	data.Groups = append(data.Groups, newGroup)
 
 	// Save to file
-	if err := saveSavedRequests(data); err != nil {
-		log.Printf("❌ Failed to save environment: %v", err)
-		respondWithError(w, "Failed to save environment", http.StatusInternalServerError)
+	if err := api.store.Save(data); err != nil {
+		slog.Error("failed to save group", "error", err)
+		respondWithError(w, "Failed to save group", http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("✅ Copied %d variables from %s to %s", len(sourceEnv.Variables), req.SourceEnvironmentID, targetEnvID)
+	slog.Info("created group", "name", newGroup.Name)
+	recordAudit(r, "create", "group", newGroup.ID, newGroup.Name)
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string]string{"status": "copied"}); err != nil {
-		log.Printf("❌ Failed to encode copy response: %v", err)
+	if err := json.NewEncoder(w).Encode(newGroup); err != nil {
+		slog.Error("failed to encode group response", "error", err)
 	}
 }
 
-// activateEnvironment handles POST requests to activate an environment
-func activateEnvironment(w http.ResponseWriter, r *http.Request) {
+// reorderGroups handles POST requests to set the display order of groups
+//
+// Groups listed in ids come first, in that order; any groups left out keep their
+// relative order after them.
+func (api *API) reorderGroups(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	envID := chi.URLParam(r, "id")
-	if envID == "" {
-		respondWithError(w, "Environment ID is required", http.StatusBadRequest)
+	var req struct {
+		IDs []string `json:"ids"`
+	}
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+	if len(req.IDs) == 0 {
+		respondWithError(w, "Group IDs are required", http.StatusBadRequest)
 		return
 	}
 
 	// Load existing data
-	data, err := loadRequests()
+	data, err := api.store.Load()
 	if err != nil {
-		log.Printf("❌ Failed to load saved data: %v", err)
-		respondWithError(w, "Failed to load saved data", http.StatusInternalServerError)
+		slog.Error("failed to load saved requests", "error", err)
+		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
 		return
 	}
 
-	// Check if environment exists
-	found := false
-	for _, env := range data.Environments {
-		if env.ID == envID {
-			found = true
-			break
+	position := make(map[string]int, len(req.IDs))
+	for i, id := range req.IDs {
+		if _, dup := position[id]; dup {
+			respondWithError(w, fmt.Sprintf("Group ID '%s' is listed more than once", id), http.StatusBadRequest)
+			return
+		}
+		position[id] = i
+	}
+	for id := range position {
+		found := false
+		for _, group := range data.Groups {
+			if group.ID == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			respondWithError(w, fmt.Sprintf("Group not found: %s", id), http.StatusNotFound)
+			return
 		}
 	}
 
-	if !found {
-		respondWithError(w, "Environment not found", http.StatusNotFound)
-		return
+	// data.Groups is already sorted, so a stable sort keeps unlisted groups in place
+	sort.SliceStable(data.Groups, func(i, j int) bool {
+		pi, iListed := position[data.Groups[i].ID]
+		pj, jListed := position[data.Groups[j].ID]
+		if iListed && jListed {
+			return pi < pj
+		}
+		return iListed && !jListed
+	})
+	now := time.Now().Format(time.RFC3339)
+	for i := range data.Groups {
+		if data.Groups[i].Order != i {
+			data.Groups[i].Order = i
+			data.Groups[i].UpdatedAt = now
+		}
 	}
 
-	// Set as current environment
-	data.CurrentEnvironment = envID
-
 	// Save to file
-	if err := saveSavedRequests(data); err != nil {
-		log.Printf("❌ Failed to save current environment: %v", err)
-		respondWithError(w, "Failed to save current environment", http.StatusInternalServerError)
+	if err := api.store.Save(data); err != nil {
+		slog.Error("failed to save group order", "error", err)
+		respondWithError(w, "Failed to reorder groups", http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("✅ Activated environment: %s", envID)
+	slog.Info("reordered groups", "count", len(data.Groups))
+	recordAudit(r, "reorder", "group", "", "")
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string]string{"status": "activated"}); err != nil {
-		log.Printf("❌ Failed to encode activation response: %v", err)
+	if err := json.NewEncoder(w).Encode(map[string][]Group{"groups": data.Groups}); err != nil {
+		slog.Error("failed to encode groups", "error", err)
 	}
 }
 
-// groups handles GET requests to get all groups
-func groups(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+// updateGroup handles PUT requests to rename a group, move it under another parent, or set
+// its description, color or auth
+//
+// Requests reference their group by name, so a rename is applied to every request
+// in the group (including trashed ones) in the same write. Subgroups reference their
+// parent by ID and are unaffected by a rename.
+func (api *API) updateGroup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	data, err := loadRequests()
-	if err != nil {
-		log.Printf("❌ Failed to load saved requests: %v", err)
-		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
+	groupID := chi.URLParam(r, "id")
+	if groupID == "" {
+		respondWithError(w, "Group ID is required", http.StatusBadRequest)
 		return
 	}
 
-	// Ensure default group exists
-	ensureDefaultGroup(data)
-
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string][]Group{"groups": data.Groups}); err != nil {
-		log.Printf("❌ Failed to encode groups: %v", err)
+	var req struct {
+		Name        *string     `json:"name,omitempty"`
+		Description *string     `json:"description,omitempty"`
+		Color       *string     `json:"color,omitempty"`    // "" removes the color
+		ParentID    *string     `json:"parentId,omitempty"` // "" moves the group to the top level
+		Auth        *AuthConfig `json:"auth,omitempty"`     // An empty type removes the group's auth
 	}
-}
-
-// createGroup handles POST requests to create a new group
-func createGroup(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if !decodeJSONRequest(w, r, &req) {
 		return
 	}
-
-	var req struct {
-		Name string `json:"name"`
+	if req.Name == nil && req.Description == nil && req.Color == nil && req.ParentID == nil && req.Auth == nil {
+		respondWithError(w, "Nothing to update: provide name, description, color, parentId and/or auth", http.StatusBadRequest)
+		return
+	}
+	if req.Color != nil {
+		color, err := normalizeGroupColor(*req.Color)
+		if err != nil {
+			respondWithError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Color = &color
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("❌ Invalid request body for create group: %v", err)
-		respondWithError(w, "Invalid request body", http.StatusBadRequest)
+	// Load existing data
+	data, err := api.store.Load()
+	if err != nil {
+		slog.Error("failed to load saved requests", "error", err)
+		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
 		return
 	}
 
-	if req.Name == "" {
-		respondWithError(w, "Group name is required", http.StatusBadRequest)
+	group := findGroup(data, groupID)
+	if group == nil {
+		respondWithError(w, "Group not found", http.StatusNotFound)
 		return
 	}
 
-	// Load existing data
-	data, err := loadRequests()
-	if err != nil {
-		log.Printf("❌ Failed to load saved requests: %v", err)
-		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
+	// New requests fall back to the default group, so it must keep its name and stay at the top
+	if group.Name == "default" && (req.Name != nil || req.ParentID != nil) {
+		respondWithError(w, "Cannot rename or move default group", http.StatusBadRequest)
 		return
 	}
 
-	// Check if group already exists
-	for _, group := range data.Groups {
-		if group.Name == req.Name {
-			respondWithError(w, "Group already exists", http.StatusConflict)
+	name := group.Name
+	if req.Name != nil {
+		name, err = normalizeName("group", *req.Name)
+		if err != nil {
+			respondWithError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, existing := range data.Groups {
+			if existing.ID != groupID && existing.Name == name {
+				respondWithError(w, "Group already exists", http.StatusConflict)
+				return
+			}
+		}
+	}
+	if req.ParentID != nil {
+		if err := checkGroupParent(data, groupID, *req.ParentID); err != nil {
+			respondWithError(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 	}
 
-	// Create new group
+	oldName := group.Name
 	now := time.Now().Format(time.RFC3339)
-	newGroup := Group{
-		ID:        generateID(),
-		Name:      req.Name,
-		CreatedAt: now,
-		UpdatedAt: now,
+	moved := 0
+	if name != oldName {
+		for i := range data.Requests {
+			if data.Requests[i].Group == oldName {
+				data.Requests[i].Group = name
+				data.Requests[i].UpdatedAt = now
+				data.Requests[i].Version++
+				moved++
+			}
+		}
+		for i := range data.Trash {
+			if data.Trash[i].Group == oldName {
+				data.Trash[i].Group = name
+			}
+		}
+		group.Name = name
+		group.UpdatedAt = now
+	}
+	if req.ParentID != nil && *req.ParentID != group.ParentID {
+		group.ParentID = *req.ParentID
+		group.UpdatedAt = now
+	}
+	if req.Description != nil {
+		group.Description = strings.TrimSpace(*req.Description)
+		group.UpdatedAt = now
+	}
+	if req.Color != nil {
+		group.Color = *req.Color
+		group.UpdatedAt = now
+	}
+	if req.Auth != nil {
+		if req.Auth.Type == "" {
+			group.Auth = nil
+		} else {
+			group.Auth = req.Auth
+		}
+		group.UpdatedAt = now
 	}
-
-	data.Groups = append(data.Groups, newGroup)
 
 	// Save to file
-	if err := saveSavedRequests(data); err != nil {
-		log.Printf("❌ Failed to save group: %v", err)
-		respondWithError(w, "Failed to save group", http.StatusInternalServerError)
+	if err := api.store.Save(data); err != nil {
+		slog.Error("failed to save after group update", "error", err)
+		respondWithError(w, "Failed to update group", http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("✅ Created group: %s", newGroup.Name)
+	slog.Info("updated group", "from", oldName, "name", name, "parent", group.ParentID, "requests", moved)
+	recordAudit(r, "update", "group", group.ID, name)
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(newGroup); err != nil {
-		log.Printf("❌ Failed to encode group response: %v", err)
+	if err := json.NewEncoder(w).Encode(map[string]any{
+		"group":           group,
+		"requestsUpdated": moved,
+	}); err != nil {
+		slog.Error("failed to encode group response", "error", err)
 	}
 }
 
 // deleteGroup handles DELETE requests to delete a group
-func deleteGroup(w http.ResponseWriter, r *http.Request) {
+func (api *API) deleteGroup(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -2185,9 +13436,9 @@ func deleteGroup(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Load existing data
-	data, err := loadRequests()
+	data, err := api.store.Load()
 	if err != nil {
-		log.Printf("❌ Failed to load saved requests: %v", err)
+		slog.Error("failed to load saved requests", "error", err)
 		respondWithError(w, "Failed to load saved requests", http.StatusInternalServerError)
 		return
 	}
@@ -2214,45 +13465,104 @@ func deleteGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if group has any requests
-	hasRequests := false
-	for _, req := range data.Requests {
-		if req.Group == groupName {
-			hasRequests = true
-			break
+	// Without a strategy, refuse to orphan the group's requests
+	strategy := r.URL.Query().Get("strategy")
+	var targetName string
+	switch strategy {
+	case "":
+		for _, req := range data.Requests {
+			if req.Group == groupName {
+				respondWithError(w, "Cannot delete group with requests; use strategy=reassign or strategy=cascade", http.StatusBadRequest)
+				return
+			}
 		}
+	case "reassign":
+		target := r.URL.Query().Get("target")
+		if target == "" {
+			target = "default"
+		}
+		for _, group := range data.Groups {
+			if group.Name == target || group.ID == target {
+				targetName = group.Name
+				break
+			}
+		}
+		if targetName == "" {
+			respondWithError(w, fmt.Sprintf("Target group not found: %s", target), http.StatusNotFound)
+			return
+		}
+		if targetName == groupName {
+			respondWithError(w, "Target group must differ from the group being deleted", http.StatusBadRequest)
+			return
+		}
+	case "cascade":
+		// Requests are moved to the trash below
+	default:
+		respondWithError(w, fmt.Sprintf("Invalid strategy '%s': use reassign or cascade", strategy), http.StatusBadRequest)
+		return
 	}
 
-	if hasRequests {
-		respondWithError(w, "Cannot delete group with requests", http.StatusBadRequest)
-		return
+	// Move or trash the group's requests
+	now := time.Now().Format(time.RFC3339)
+	moved, removed := 0, 0
+	kept := data.Requests[:0]
+	for _, req := range data.Requests {
+		if req.Group != groupName {
+			kept = append(kept, req)
+			continue
+		}
+		if strategy == "cascade" {
+			req.DeletedAt = now
+			data.Trash = append(data.Trash, req)
+			removed++
+			continue
+		}
+		req.Group = targetName
+		req.UpdatedAt = now
+		req.Version++
+		kept = append(kept, req)
+		moved++
 	}
+	data.Requests = kept
 
-	// Remove the group
+	// Remove the group; its subgroups move up to its parent
+	parentID := ""
 	for i, group := range data.Groups {
 		if group.ID == groupID {
+			parentID = group.ParentID
 			data.Groups = append(data.Groups[:i], data.Groups[i+1:]...)
 			break
 		}
 	}
+	for i := range data.Groups {
+		if data.Groups[i].ParentID == groupID {
+			data.Groups[i].ParentID = parentID
+			data.Groups[i].UpdatedAt = now
+		}
+	}
 
 	// Save to file
-	if err := saveSavedRequests(data); err != nil {
-		log.Printf("❌ Failed to save after group deletion: %v", err)
+	if err := api.store.Save(data); err != nil {
+		slog.Error("failed to save after group deletion", "error", err)
 		respondWithError(w, "Failed to delete group", http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("✅ Deleted group: %s", groupName)
+	slog.Info("deleted group", "name", groupName, "moved", moved, "trashed", removed)
+	recordAudit(r, "delete", "group", groupID, groupName)
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string]string{"status": "deleted"}); err != nil {
-		log.Printf("❌ Failed to encode delete response: %v", err)
+	if err := json.NewEncoder(w).Encode(map[string]any{
+		"status":  "deleted",
+		"moved":   moved,
+		"removed": removed,
+	}); err != nil {
+		slog.Error("failed to encode delete response", "error", err)
 	}
 }
 
 // handleSaveWordWrap saves the word wrap setting
-func handleSaveWordWrap(w http.ResponseWriter, r *http.Request) {
+func (api *API) handleSaveWordWrap(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -2263,34 +13573,149 @@ func handleSaveWordWrap(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("❌ Invalid word wrap request body: %v", err)
+		slog.Error("invalid word wrap request body", "error", err)
 		respondWithError(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	// Load current data
-	data, err := loadRequests()
+	err := api.store.UpdateSettings(func(settings *Settings) error {
+		settings.WordWrap = req.WordWrap
+		return nil
+	})
 	if err != nil {
-		log.Printf("❌ Failed to load data for word wrap update: %v", err)
-		respondWithError(w, "Failed to load data", http.StatusInternalServerError)
+		slog.Error("failed to save word wrap setting", "error", err)
+		respondWithError(w, "Failed to save word wrap setting", http.StatusInternalServerError)
 		return
 	}
 
-	// Update word wrap setting
-	data.WordWrap = req.WordWrap
+	slog.Info("updated word wrap setting", "wordWrap", req.WordWrap)
 
-	// Save to file
-	if err := saveSavedRequests(data); err != nil {
-		log.Printf("❌ Failed to save word wrap setting: %v", err)
-		respondWithError(w, "Failed to save word wrap setting", http.StatusInternalServerError)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]bool{"wordWrap": req.WordWrap}); err != nil {
+		slog.Error("failed to encode word wrap response", "error", err)
+	}
+}
+
+// handleSaveStrictTemplates saves the global strict template mode setting
+func (api *API) handleSaveStrictTemplates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	log.Printf("✅ Updated word wrap setting to: %t", req.WordWrap)
+	var req struct {
+		StrictTemplates bool `json:"strictTemplates"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("invalid strict templates request body", "error", err)
+		respondWithError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	err := api.store.UpdateSettings(func(settings *Settings) error {
+		settings.StrictTemplates = req.StrictTemplates
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to save strict templates setting", "error", err)
+		respondWithError(w, "Failed to save strict templates setting", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("updated strict templates setting", "strictTemplates", req.StrictTemplates)
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string]bool{"wordWrap": req.WordWrap}); err != nil {
-		log.Printf("❌ Failed to encode word wrap response: %v", err)
+	if err := json.NewEncoder(w).Encode(map[string]bool{"strictTemplates": req.StrictTemplates}); err != nil {
+		slog.Error("failed to encode strict templates response", "error", err)
+	}
+}
+
+// handleSaveDraftIdle saves how long the UI waits after the last edit before saving a draft
+func (api *API) handleSaveDraftIdle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		DraftIdleSeconds int `json:"draftIdleSeconds"`
+	}
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+	if req.DraftIdleSeconds < 1 || req.DraftIdleSeconds > maxDraftIdleSeconds {
+		respondWithError(w, fmt.Sprintf("draftIdleSeconds must be between 1 and %d", maxDraftIdleSeconds), http.StatusBadRequest)
+		return
+	}
+
+	err := api.store.UpdateSettings(func(settings *Settings) error {
+		settings.DraftIdleSeconds = req.DraftIdleSeconds
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to save draft idle setting", "error", err)
+		respondWithError(w, "Failed to save draft idle setting", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("updated draft idle time", "seconds", req.DraftIdleSeconds)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]int{"draftIdleSeconds": req.DraftIdleSeconds}); err != nil {
+		slog.Error("failed to encode draft idle response", "error", err)
+	}
+}
+
+// maxUserAgentLength caps the userAgent setting (in characters)
+const maxUserAgentLength = 256
+
+// handleSaveUserAgent saves the default User-Agent for proxied requests; an empty value
+// goes back to go-rest/<version>
+func (api *API) handleSaveUserAgent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		UserAgent string `json:"userAgent"`
+	}
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+	userAgent := strings.TrimSpace(req.UserAgent)
+	if n := utf8.RuneCountInString(userAgent); n > maxUserAgentLength {
+		respondWithError(w, fmt.Sprintf("userAgent is too long (%d characters, max %d)", n, maxUserAgentLength), http.StatusBadRequest)
+		return
+	}
+	for _, c := range userAgent {
+		if unicode.IsControl(c) {
+			respondWithError(w, "userAgent must not contain control characters", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var updated Settings
+	err := api.store.UpdateSettings(func(settings *Settings) error {
+		settings.UserAgent = userAgent
+		updated = *settings
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to save user agent setting", "error", err)
+		respondWithError(w, "Failed to save user agent setting", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("updated default User-Agent", "userAgent", defaultUserAgent(updated))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{
+		"userAgent": updated.UserAgent,
+		"effective": defaultUserAgent(updated),
+	}); err != nil {
+		slog.Error("failed to encode user agent response", "error", err)
 	}
 }
 
@@ -2308,9 +13733,218 @@ func ensureDefaultGroup(data *SavedRequestsData) {
 	defaultGroup := Group{
 		ID:        generateID(),
 		Name:      "default",
+		Order:     nextGroupOrder(data.Groups),
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
 
 	data.Groups = append(data.Groups, defaultGroup)
+	noteRepair(data, repairCreatedGroup, defaultGroup.ID, defaultGroup.Name, "the default group was missing")
+}
+
+// reassignOrphanedRequests moves requests whose group doesn't exist to the default group
+//
+// Deleting a group through the API moves or deletes its requests, but a group removed or
+// renamed by hand in the data file leaves them pointing at nothing, hidden from the tree.
+func reassignOrphanedRequests(data *SavedRequestsData) {
+	groupNames := make(map[string]bool, len(data.Groups))
+	for _, group := range data.Groups {
+		groupNames[group.Name] = true
+	}
+	for i := range data.Requests {
+		req := &data.Requests[i]
+		if groupNames[req.Group] {
+			continue
+		}
+		slog.Debug("moving request to the default group because its group doesn't exist", "request", req.Name, "group", req.Group)
+		noteRepair(data, repairReassignedRequest, req.ID, req.Name, fmt.Sprintf("group %q doesn't exist; moved to default", req.Group))
+		req.Group = "default"
+	}
+}
+
+// nextGroupOrder returns the order value that places a new group last
+func nextGroupOrder(groups []Group) int {
+	next := 0
+	for _, group := range groups {
+		if group.Order >= next {
+			next = group.Order + 1
+		}
+	}
+	return next
+}
+
+// sortGroups orders groups by their Order field
+//
+// Files written before groups could be reordered have no orders at all; those
+// groups are numbered by creation time.
+func sortGroups(data *SavedRequestsData) {
+	unordered := len(data.Groups) > 1
+	for _, group := range data.Groups {
+		if group.Order != 0 {
+			unordered = false
+			break
+		}
+	}
+	if unordered {
+		sort.SliceStable(data.Groups, func(i, j int) bool {
+			return data.Groups[i].CreatedAt < data.Groups[j].CreatedAt
+		})
+		for i := range data.Groups {
+			data.Groups[i].Order = i
+		}
+		return
+	}
+	sort.SliceStable(data.Groups, func(i, j int) bool {
+		return data.Groups[i].Order < data.Groups[j].Order
+	})
+}
+
+// findGroup returns a pointer to the group with the given ID, or nil
+func findGroup(data *SavedRequestsData, groupID string) *Group {
+	for i := range data.Groups {
+		if data.Groups[i].ID == groupID {
+			return &data.Groups[i]
+		}
+	}
+	return nil
+}
+
+// checkGroupParent validates moving a group under parentID; an empty parentID means
+// the top level
+func checkGroupParent(data *SavedRequestsData, groupID, parentID string) error {
+	if parentID == "" {
+		return nil
+	}
+	if parentID == groupID {
+		return fmt.Errorf("a group can't be its own parent")
+	}
+	// Walk up from the new parent; reaching the group itself means a cycle
+	for id, depth := parentID, 0; id != ""; depth++ {
+		parent := findGroup(data, id)
+		if parent == nil {
+			return fmt.Errorf("parent group not found: %s", id)
+		}
+		if parent.ID == groupID {
+			return fmt.Errorf("can't move a group into one of its own subgroups")
+		}
+		if depth > len(data.Groups) {
+			break
+		}
+		id = parent.ParentID
+	}
+	return nil
+}
+
+// groupColorPattern matches #rgb and #rrggbb hex colors
+var groupColorPattern = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// normalizeGroupColor trims and lowercases a group color; an empty color means none
+func normalizeGroupColor(color string) (string, error) {
+	color = strings.TrimSpace(color)
+	if color == "" {
+		return "", nil
+	}
+	if !groupColorPattern.MatchString(color) {
+		return "", fmt.Errorf("group color must be a hex color like #3b82f6, got %q", color)
+	}
+	return strings.ToLower(color), nil
+}
+
+// normalizeGroupMetadata tidies group descriptions and colors
+//
+// Files written before groups had these fields load with both empty. Colors that
+// were edited by hand into something invalid are cleared rather than rejected.
+func normalizeGroupMetadata(data *SavedRequestsData) {
+	for i := range data.Groups {
+		group := &data.Groups[i]
+		group.Description = strings.TrimSpace(group.Description)
+		color, err := normalizeGroupColor(group.Color)
+		if err != nil {
+			slog.Info("clearing invalid group color", "color", group.Color, "group", group.Name)
+			noteRepair(data, repairClearedColor, group.ID, group.Name, fmt.Sprintf("cleared invalid color %q", group.Color))
+		}
+		group.Color = color
+	}
+}
+
+// repairGroupTree moves groups with a missing parent, or caught in a parent cycle, to
+// the top level
+func repairGroupTree(data *SavedRequestsData) {
+	for i := range data.Groups {
+		group := &data.Groups[i]
+		if group.ParentID == "" {
+			continue
+		}
+		if group.Name == "default" {
+			noteRepair(data, repairMovedGroup, group.ID, group.Name, "the default group always stays at the top level")
+			group.ParentID = ""
+			continue
+		}
+		seen := map[string]bool{group.ID: true}
+		for id := group.ParentID; id != ""; {
+			parent := findGroup(data, id)
+			if parent == nil || seen[id] {
+				slog.Info("moving group to the top level because its parent is broken", "group", group.Name, "parent", group.ParentID)
+				reason := "its parent " + group.ParentID + " doesn't exist"
+				if parent != nil {
+					reason = "its parents form a cycle"
+				}
+				noteRepair(data, repairMovedGroup, group.ID, group.Name, "moved to the top level: "+reason)
+				group.ParentID = ""
+				break
+			}
+			seen[id] = true
+			id = parent.ParentID
+		}
+	}
+}
+
+// GroupNode is a group with its subgroups, used for the tree view of groups
+type GroupNode struct {
+	Group
+	Children []GroupNode `json:"children"`
+}
+
+// buildGroupTree nests groups under their parents, keeping the order of the slice
+func buildGroupTree(groups []Group) []GroupNode {
+	children := make(map[string][]Group)
+	for _, group := range groups {
+		children[group.ParentID] = append(children[group.ParentID], group)
+	}
+	var build func(parentID string) []GroupNode
+	build = func(parentID string) []GroupNode {
+		nodes := make([]GroupNode, 0, len(children[parentID]))
+		for _, group := range children[parentID] {
+			nodes = append(nodes, GroupNode{Group: group, Children: build(group.ID)})
+		}
+		return nodes
+	}
+	return build("")
+}
+
+// orderByGroupTree sorts requests by their group's place in the tree, depth first: a
+// group's own requests, then each subgroup in order. Requests keep their saved order
+// within a group, and those in unknown groups come last.
+func orderByGroupTree(data *SavedRequestsData, requests []SavedRequest) []SavedRequest {
+	position := make(map[string]int, len(data.Groups))
+	var walk func(nodes []GroupNode)
+	walk = func(nodes []GroupNode) {
+		for _, node := range nodes {
+			position[node.Name] = len(position)
+			walk(node.Children)
+		}
+	}
+	walk(buildGroupTree(data.Groups))
+
+	rank := func(groupName string) int {
+		if p, ok := position[groupName]; ok {
+			return p
+		}
+		return len(position)
+	}
+	ordered := slices.Clone(requests)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return rank(ordered[i].Group) < rank(ordered[j].Group)
+	})
+	return ordered
 }
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProxyReturnsTheExecutionID(t *testing.T) {
+	t.Chdir(t.TempDir())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	api := newAPI(newMemStore(t, nil))
+	body := `{"method":"GET","url":"` + server.URL + `"}`
+
+	for _, chosen := range []string{"", "client-chosen.1"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/proxy", strings.NewReader(body))
+		if chosen != "" {
+			req.Header.Set(executionIDHeader, chosen)
+		}
+		rec := httptest.NewRecorder()
+		api.proxy(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+		}
+
+		var response ProxyResponse
+		if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		header := rec.Header().Get(executionIDHeader)
+		if header == "" || header != response.ExecutionID {
+			t.Errorf("header ID %q doesn't match body ID %q", header, response.ExecutionID)
+		}
+		if chosen != "" && header != chosen {
+			t.Errorf("ID = %q, want the client's %q", header, chosen)
+		}
+	}
+}
+
+func TestConcurrentSendsRecordEveryStatus(t *testing.T) {
+	t.Chdir(t.TempDir())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(server.Close)
+	store := newFileStore()
+	if err := store.Save(&SavedRequestsData{Requests: []SavedRequest{
+		{ID: "r1", Name: "Accepted", Method: "GET", URL: server.URL},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	api := newAPI(store)
+
+	const sends = 8
+	var wg sync.WaitGroup
+	for range sends {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			callHandler(api.proxy, http.MethodPost, "/api/proxy", `{"method":"GET","url":"`+server.URL+`","requestId":"r1"}`)
+		}()
+	}
+	wg.Wait()
+
+	req, err := store.GetRequest("r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(req.StatusHistory) != sends {
+		t.Errorf("status history = %v, want %d entries", req.StatusHistory, sends)
+	}
+}
+
+// pagedServer serves a JSON array over pages pages linked by rel="next", recording the
+// headers each page was requested with. delay holds up every page after the first.
+func pagedServer(t *testing.T, pages int, delay time.Duration) (*httptest.Server, *[]http.Header) {
+	t.Helper()
+	var mu sync.Mutex
+	var got []http.Header
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		got = append(got, r.Header.Clone())
+		mu.Unlock()
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page > 1 {
+			time.Sleep(delay)
+		} else {
+			page = 1
+		}
+		if page < pages {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/?page=%d>; rel="next"`, server.URL, page+1))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, "[%d]", page)
+	}))
+	t.Cleanup(server.Close)
+	return server, &got
+}
+
+func TestPaginationKeepsImplicitHeaderSwitches(t *testing.T) {
+	server, got := pagedServer(t, 3, 0)
+	req := ProxyRequest{Method: http.MethodGet, URL: server.URL, Headers: map[string]string{},
+		FollowPages: true, NoCompression: true, NoUserAgent: true}
+
+	response := makeHTTPRequest(context.Background(), req)
+	followPagination(context.Background(), req, &response)
+	if response.Pages != 3 {
+		t.Fatalf("followed %d pages (%s), want 3", response.Pages, response.PaginationNote)
+	}
+	for i, header := range *got {
+		if values := header.Values("Accept-Encoding"); len(values) != 0 {
+			t.Errorf("page %d: Accept-Encoding = %q, want none", i+1, values)
+		}
+		if values := header.Values("User-Agent"); len(values) != 0 {
+			t.Errorf("page %d: User-Agent = %q, want none", i+1, values)
+		}
+	}
+}
+
+func TestPaginationKeepsTheRequestTimeout(t *testing.T) {
+	server, _ := pagedServer(t, 2, 300*time.Millisecond)
+	req := ProxyRequest{Method: http.MethodGet, URL: server.URL, Headers: map[string]string{},
+		FollowPages: true, TimeoutMs: 100}
+
+	response := makeHTTPRequest(context.Background(), req)
+	followPagination(context.Background(), req, &response)
+	if response.Pages != 1 || !strings.Contains(response.PaginationNote, "page 2 failed") {
+		t.Errorf("pages = %d, note = %q; want page 2 to time out", response.Pages, response.PaginationNote)
+	}
+}
+
+func TestCompareCanBeCancelled(t *testing.T) {
+	t.Chdir(t.TempDir())
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(func() {
+		close(release)
+		server.Close()
+	})
+	api := newAPI(newMemStore(t, nil))
+
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		req := httptest.NewRequest(http.MethodPost, "/api/proxy/compare", strings.NewReader(`{"method":"GET","url":"`+server.URL+`"}`))
+		req.Header.Set(executionIDHeader, "hung-compare")
+		rec := httptest.NewRecorder()
+		api.compareProxy(rec, req)
+		done <- rec
+	}()
+
+	// Cancel once the execution is registered
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rec := callHandler(api.cancelProxyRequest, http.MethodDelete, "/api/proxy/hung-compare", "", "executionId", "hung-compare")
+		if rec.Code == http.StatusOK {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("compare never registered an execution: %d %s", rec.Code, rec.Body)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case rec := <-done:
+		var result struct{ Response ProxyResponse }
+		if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		if result.Response.ErrorKind != errorKindCancelled || result.Response.ExecutionID != "hung-compare" {
+			t.Errorf("response = %+v, want it cancelled under hung-compare", result.Response)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelled compare did not return")
+	}
+}
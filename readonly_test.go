@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"testing"
+)
+
+// withReadOnly turns read-only mode on for the duration of a test
+func withReadOnly(t *testing.T) {
+	t.Helper()
+	previous := readOnly
+	readOnly = true
+	t.Cleanup(func() { readOnly = previous })
+}
+
+func TestReadOnlyWritesNothing(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	withReadOnly(t)
+
+	if err := (fileStore{}).Save(&SavedRequestsData{}); !errors.Is(err, errReadOnly) {
+		t.Errorf("save: got %v, want %v", err, errReadOnly)
+	}
+	recordAudit(httptest.NewRequest(http.MethodPost, "/api/requests/save", nil), "create", "request", "r1", "Read only")
+	if err := newAPI(newFileStore()).recordStatusHistory("r1", http.StatusOK); err != nil {
+		t.Errorf("status history: %v", err)
+	}
+	sent := ProxyRequest{Method: http.MethodGet, URL: "http://example.test/read-only"}
+	recordExecution(sent, ProxyResponse{StatusCode: http.StatusOK}, "r1", "Read only")
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		t.Errorf("read-only server wrote %s", entry.Name())
+	}
+
+	historyMutex.Lock()
+	recorded := slices.ContainsFunc(executionHistory, func(record ExecutionRecord) bool { return record.URL == sent.URL })
+	historyMutex.Unlock()
+	if !recorded {
+		t.Error("the execution isn't in the in-memory history")
+	}
+}
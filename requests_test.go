@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// callHandler sends body to handler as the router would, with urlParams given as
+// name, value pairs
+func callHandler(handler http.HandlerFunc, method, target, body string, urlParams ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	routeCtx := chi.NewRouteContext()
+	for i := 0; i+1 < len(urlParams); i += 2 {
+		routeCtx.URLParams.Add(urlParams[i], urlParams[i+1])
+	}
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx))
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+// savedRequestNamed loads the store's data and returns the request with the given name
+func savedRequestNamed(t *testing.T, store Store, name string) SavedRequest {
+	t.Helper()
+	data, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, saved := range data.Requests {
+		if saved.Name == name {
+			return saved
+		}
+	}
+	t.Fatalf("no saved request named %q", name)
+	return SavedRequest{}
+}
+
+func TestPrettifyJSONBody(t *testing.T) {
+	jsonHeaders := map[string]string{"content-type": "application/vnd.api+json; charset=utf-8"}
+	tests := []struct {
+		name     string
+		bodyType string
+		headers  map[string]string
+		in, want string
+	}{
+		{"compact object", "json", nil, `{"b":1,"a":[true,null]}`, "{\n  \"b\": 1,\n  \"a\": [\n    true,\n    null\n  ]\n}"},
+		{"literals kept", "json", nil, `{"n":1.50,"big":12345678901234567890,"s":"\u00e9\n"}`, "{\n  \"n\": 1.50,\n  \"big\": 12345678901234567890,\n  \"s\": \"\\u00e9\\n\"\n}"},
+		{"surrounding space", "json", nil, "  [1, 2]\n", "[\n  1,\n  2\n]"},
+		{"text with JSON Content-Type", "text", jsonHeaders, `{"a":1}`, "{\n  \"a\": 1\n}"},
+		{"invalid JSON", "json", nil, `{"a": 1,`, `{"a": 1,`},
+		{"unquoted placeholder", "json", nil, `{"id": {{id}}}`, `{"id": {{id}}}`},
+		{"empty", "json", nil, "", ""},
+		{"text without JSON Content-Type", "text", map[string]string{"Content-Type": "text/plain"}, `{"a":1}`, `{"a":1}`},
+		{"form body", "form", jsonHeaders, `{"a":1}`, `{"a":1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := prettifyJSONBody(tt.bodyType, tt.headers, tt.in)
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+			if again := prettifyJSONBody(tt.bodyType, tt.headers, got); again != got {
+				t.Errorf("not idempotent: %q became %q", got, again)
+			}
+		})
+	}
+}
+
+func TestSaveAndUpdateRoundTripJSONBody(t *testing.T) {
+	t.Chdir(t.TempDir())
+	store := newMemStore(t, nil)
+	api := newAPI(store)
+	body := `{"user":{"name":"Ada","tags":["a","b"]},"count":3}`
+
+	rec := callHandler(api.saveRequest, http.MethodPost, "/api/requests/save",
+		`{"name":"Create user","method":"POST","url":"http://example.test/users","bodyType":"json","bodyText":`+jsonString(body)+`}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("save: status = %d: %s", rec.Code, rec.Body)
+	}
+	saved := savedRequestNamed(t, store, "Create user")
+	if !strings.Contains(saved.BodyText, "\n  \"user\": {") {
+		t.Errorf("saved body isn't indented: %q", saved.BodyText)
+	}
+	assertSameJSON(t, saved.BodyText, body)
+
+	// Saving the loaded body again leaves it as it is
+	if again := prettifyJSONBody(saved.BodyType, saved.Headers, saved.BodyText); again != saved.BodyText {
+		t.Errorf("saved body changed on a second pass: %q", again)
+	}
+
+	draft := `{"user": {{user}}}`
+	rec = callHandler(api.updateRequest, http.MethodPut, "/api/requests/update",
+		`{"id":"`+saved.ID+`","bodyText":`+jsonString(draft)+`}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update: status = %d: %s", rec.Code, rec.Body)
+	}
+	if got := savedRequestNamed(t, store, "Create user").BodyText; got != draft {
+		t.Errorf("work-in-progress body = %q, want it saved as typed", got)
+	}
+
+	rec = callHandler(api.updateRequest, http.MethodPut, "/api/requests/update",
+		`{"id":"`+saved.ID+`","bodyText":"[1,2]"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update: status = %d: %s", rec.Code, rec.Body)
+	}
+	if got := savedRequestNamed(t, store, "Create user").BodyText; got != "[\n  1,\n  2\n]" {
+		t.Errorf("updated body = %q, want it indented", got)
+	}
+}
+
+// jsonString quotes s as a JSON string
+func jsonString(s string) string {
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
+
+// assertSameJSON fails unless both texts decode to the same value
+func assertSameJSON(t *testing.T, got, want string) {
+	t.Helper()
+	var gotValue, wantValue any
+	if err := json.Unmarshal([]byte(got), &gotValue); err != nil {
+		t.Fatalf("%q: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatalf("%q: %v", want, err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("got %s, want the same value as %s", got, want)
+	}
+}
+
+func TestDuplicateRequestCopiesTheMatchedRequest(t *testing.T) {
+	t.Chdir(t.TempDir())
+	store := newMemStore(t, &SavedRequestsData{Requests: []SavedRequest{
+		{ID: "first", Name: "First", Method: "GET", URL: "http://example.test/first"},
+		{ID: "middle", Name: "Middle", Method: "POST", URL: "http://example.test/middle", BodyType: "text", BodyText: "middle body",
+			Headers: map[string]string{"X-Source": "middle"}},
+		{ID: "last", Name: "Last", Method: "DELETE", URL: "http://example.test/last"},
+	}})
+	api := newAPI(store)
+
+	rec := callHandler(api.duplicateRequest, http.MethodPost, "/api/requests/duplicate", `{"id":"middle"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	copied := savedRequestNamed(t, store, "Middle (Copy)")
+	if copied.ID == "middle" || copied.Method != "POST" || copied.URL != "http://example.test/middle" ||
+		copied.BodyText != "middle body" || copied.Headers["X-Source"] != "middle" {
+		t.Errorf("duplicate doesn't match the middle request: %+v", copied)
+	}
+	if last := savedRequestNamed(t, store, "Last"); last.URL != "http://example.test/last" {
+		t.Errorf("last request changed: %+v", last)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runSummary is the body of a group run response
+type runSummary struct {
+	Total, Passed, Failed, Cancelled int
+	Results                          []RunResult
+}
+
+// runGroupRequest posts body to POST /api/groups/{id}/run
+func runGroupRequest(t *testing.T, groupID, body string) (*httptest.ResponseRecorder, runSummary) {
+	t.Helper()
+	rec := callHandler(newAPI(newFileStore()).runGroup, http.MethodPost, "/api/groups/"+groupID+"/run", body, "id", groupID)
+
+	var summary runSummary
+	if rec.Code == http.StatusOK {
+		if err := json.NewDecoder(rec.Body).Decode(&summary); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rec, summary
+}
+
+// saveRunFixture saves a parent group with a subgroup and the given requests
+func saveRunFixture(t *testing.T, requests []SavedRequest) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	data := &SavedRequestsData{
+		Requests: requests,
+		Groups: []Group{
+			{ID: "g-api", Name: "api"},
+			{ID: "g-slow", Name: "slow", ParentID: "g-api"},
+			{ID: "g-other", Name: "other"},
+		},
+		Environments:       []Environment{{ID: "env", Name: "Test"}},
+		CurrentEnvironment: "env",
+	}
+	if err := (fileStore{}).Save(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunGroupIncludesSubgroups(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	saveRunFixture(t, []SavedRequest{
+		{ID: "1", Name: "sub", Group: "slow", Method: "GET", URL: server.URL},
+		{ID: "2", Name: "other", Group: "other", Method: "GET", URL: server.URL},
+		{ID: "3", Name: "top", Group: "api", Method: "GET", URL: server.URL},
+		{ID: "4", Name: "template", Group: "api", Method: "GET", URL: server.URL, Template: true},
+	})
+
+	rec, summary := runGroupRequest(t, "g-api", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var names []string
+	for _, result := range summary.Results {
+		names = append(names, result.Name)
+		if result.Status != runPassed {
+			t.Errorf("%s: status %q, want %q", result.Name, result.Status, runPassed)
+		}
+	}
+	if strings.Join(names, ",") != "top,sub" {
+		t.Errorf("ran %v, want [top sub]", names)
+	}
+}
+
+func TestRunGroupUnknownGroup(t *testing.T) {
+	saveRunFixture(t, nil)
+	if rec, _ := runGroupRequest(t, "missing", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if rec, _ := runGroupRequest(t, "g-api", `{"runTimeoutMs": -1}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("negative runTimeoutMs: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestRunGroupTimeoutBudget(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/hang" {
+			select {
+			case <-r.Context().Done():
+			case <-release:
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	saveRunFixture(t, []SavedRequest{
+		{ID: "1", Name: "fast", Group: "api", Method: "GET", URL: server.URL + "/fast"},
+		{ID: "2", Name: "hung", Group: "api", Method: "GET", URL: server.URL + "/hang"},
+		{ID: "3", Name: "after", Group: "slow", Method: "GET", URL: server.URL + "/fast"},
+	})
+
+	start := time.Now()
+	rec, summary := runGroupRequest(t, "g-api", `{"runTimeoutMs": 200}`)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("run took %v despite a 200ms budget", elapsed)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+
+	want := map[string]string{"fast": runPassed, "hung": runCancelled, "after": runCancelled}
+	if len(summary.Results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(summary.Results), len(want))
+	}
+	for _, result := range summary.Results {
+		if result.Status != want[result.Name] {
+			t.Errorf("%s: status %q, want %q", result.Name, result.Status, want[result.Name])
+		}
+	}
+	if summary.Passed != 1 || summary.Cancelled != 2 || summary.Failed != 0 {
+		t.Errorf("passed %d, cancelled %d, failed %d; want 1, 2, 0", summary.Passed, summary.Cancelled, summary.Failed)
+	}
+}
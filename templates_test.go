@@ -0,0 +1,164 @@
+package main
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var uuidV4Pattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestDynamicUUID(t *testing.T) {
+	out, err := processDynamicVariables("{{$uuid}} {{$uuid}}", map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Fields(out)
+	if len(parts) != 2 {
+		t.Fatalf("got %q", out)
+	}
+	for _, part := range parts {
+		if !uuidV4Pattern.MatchString(part) {
+			t.Errorf("%q is not a version 4 UUID", part)
+		}
+	}
+	if parts[0] == parts[1] {
+		t.Errorf("each occurrence should get its own value, got %q twice", parts[0])
+	}
+}
+
+func TestDynamicAliasReusesValue(t *testing.T) {
+	aliases := map[string]string{}
+	first, err := processDynamicVariables("{{$uuid:id}}/{{$uuid}}", aliases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := processDynamicVariables("{{$uuid:id}}", aliases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(first, "/")
+	if parts[0] != second {
+		t.Errorf("alias gave %q and then %q", parts[0], second)
+	}
+	if parts[0] == parts[1] {
+		t.Errorf("unaliased $uuid reused the aliased value %q", parts[0])
+	}
+}
+
+func TestDynamicTimestamp(t *testing.T) {
+	before := time.Now().Unix()
+	out, err := processDynamicVariables("{{$timestamp}}", map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := strconv.ParseInt(out, 10, 64)
+	if err != nil {
+		t.Fatalf("%q is not unix seconds", out)
+	}
+	if got < before || got > time.Now().Unix() {
+		t.Errorf("timestamp %d is not the current time", got)
+	}
+}
+
+func TestDynamicISOTimestamp(t *testing.T) {
+	out, err := processDynamicVariables("{{$isoTimestamp}}", map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := time.Parse(time.RFC3339, out)
+	if err != nil {
+		t.Fatalf("%q is not RFC 3339: %v", out, err)
+	}
+	if parsed.Location() != time.UTC {
+		t.Errorf("%q is not in UTC", out)
+	}
+	if d := time.Since(parsed); d < 0 || d > time.Minute {
+		t.Errorf("%q is not the current time", out)
+	}
+}
+
+func TestDynamicRandomInt(t *testing.T) {
+	tests := []struct {
+		template string
+		min, max int64
+	}{
+		{"{{$randomInt}}", 0, 1000},
+		{"{{$randomInt 5 5}}", 5, 5},
+		{"{{$randomInt 1 10}}", 1, 10},
+		{"{{$randomInt 10 1}}", 1, 10},
+		{"{{$randomInt -20 -10}}", -20, -10},
+		{"{{$randomInt -9223372036854775808 9223372036854775807}}", math.MinInt64, math.MaxInt64},
+		{"{{$randomInt 0 9223372036854775807}}", 0, math.MaxInt64},
+	}
+	for _, tt := range tests {
+		for range 20 {
+			out, err := processDynamicVariables(tt.template, map[string]string{})
+			if err != nil {
+				t.Fatalf("%s: %v", tt.template, err)
+			}
+			n, err := strconv.ParseInt(out, 10, 64)
+			if err != nil {
+				t.Fatalf("%s: %q is not an integer", tt.template, out)
+			}
+			if n < tt.min || n > tt.max {
+				t.Fatalf("%s: %d is outside [%d, %d]", tt.template, n, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestDynamicRandomIntInvalidArguments(t *testing.T) {
+	for _, template := range []string{
+		"{{$randomInt 1}}",
+		"{{$randomInt 1 2 3}}",
+		"{{$randomInt 0 99999999999999999999}}",
+		"{{$randomInt -99999999999999999999 0}}",
+	} {
+		if out, err := processDynamicVariables(template, map[string]string{}); err == nil {
+			t.Errorf("%s: expected an error, got %q", template, out)
+		}
+	}
+}
+
+func TestProcessTemplatesReportsDynamicErrors(t *testing.T) {
+	_, err := newAPI(newMemStore(t, nil)).processTemplates(ProxyRequest{
+		Method:  "GET",
+		URL:     "http://example.test/items",
+		Headers: map[string]string{"X-Seed": "{{$randomInt 1}}"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "$randomInt") {
+		t.Fatalf("expected a $randomInt error, got %v", err)
+	}
+}
+
+func TestResponseVariableCycle(t *testing.T) {
+	api := newAPI(newMemStore(t, &SavedRequestsData{Requests: []SavedRequest{
+		{ID: "a", Name: "A", Method: "GET", URL: "http://example.test/a",
+			LastResponse: &ProxyResponse{StatusCode: 200, Body: map[string]any{"next": `{{"B".next}}`}}},
+		{ID: "b", Name: "B", Method: "GET", URL: "http://example.test/b",
+			LastResponse: &ProxyResponse{StatusCode: 200, Body: map[string]any{"next": `{{"A".next}}`}}},
+	}}))
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := api.processTemplates(ProxyRequest{
+			Method:  "GET",
+			URL:     "http://example.test/items",
+			Headers: map[string]string{"X-Next": `{{"A".next}}`},
+		})
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "cycle: A → B → A") {
+			t.Fatalf("expected a cycle error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("resolving a response variable cycle did not finish")
+	}
+}